@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// aclView audits who can read the bucket and its objects and applies canned
+// ACLs to an object or a prefix
+type aclView struct {
+	ai  aclInspector
+	w   fyne.Window
+	pab PublicAccessBlock
+	// objects is the last scan, shown are the ones passing the filter
+	objects []ObjectACL
+	shown   []ObjectACL
+
+	target     *widget.Entry
+	publicOnly *widget.Check
+	canned     *widget.Select
+	status     *widget.Label
+	table      *widget.Table
+	cancel     context.CancelFunc
+}
+
+// showACL opens the inspector on the selected object, or the current
+// directory if none is selected
+func (sc *Fone) showACL(ai aclInspector) {
+	av := &aclView{ai: ai}
+	av.w = sc.a.NewWindow("Public access")
+
+	target := sc.pathLabel.Text
+	if f := sc.selectFile; f.Name != "" {
+		if f.IsDir() {
+			target += f.Name
+		} else {
+			target = path.Join(target, f.Name)
+		}
+	}
+	pabLabel := widget.NewLabel("loading...")
+	bucketLabel := widget.NewLabel("loading...")
+	bucketLabel.Wrapping = fyne.TextWrapWord
+	header := widget.NewForm(
+		widget.NewFormItem("Block public access", pabLabel),
+		widget.NewFormItem("Bucket ACL", bucketLabel),
+	)
+
+	av.target = widget.NewEntry()
+	av.target.SetText(target)
+	av.target.SetPlaceHolder("key, or prefix ending in / for everything below it")
+	av.publicOnly = widget.NewCheck("Public only", func(bool) {
+		av.filter()
+	})
+	scan := widget.NewButtonWithIcon("Scan", theme.SearchIcon(), av.scan)
+	av.canned = widget.NewSelect(cannedACLs, nil)
+	av.canned.SetSelected(cannedACLs[0])
+	apply := widget.NewButton("Apply", av.apply)
+	av.status = widget.NewLabel("")
+
+	av.table = widget.NewTable(
+		func() (int, int) {
+			return len(av.shown) + 1, 2
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			l := o.(*widget.Label)
+			l.TextStyle.Bold = id.Row == 0
+			l.Importance = widget.MediumImportance
+			if id.Row == 0 {
+				l.SetText([]string{"Key", "ACL"}[id.Col])
+				return
+			}
+			obj := av.shown[id.Row-1]
+			if id.Col == 0 {
+				l.SetText(obj.Key)
+				return
+			}
+			switch {
+			case obj.Err != nil:
+				l.Importance = widget.WarningImportance
+				l.SetText("unavailable: " + unwrapError(obj.Err).Error())
+			case len(obj.ACL.Public()) > 0:
+				l.Importance = widget.DangerImportance
+				l.SetText(obj.ACL.String())
+			default:
+				l.SetText(obj.ACL.String())
+			}
+		},
+	)
+	av.table.SetColumnWidth(0, 420)
+	av.table.SetColumnWidth(1, 360)
+
+	toolbar := container.NewBorder(nil, nil, widget.NewLabel("Target"),
+		container.NewHBox(av.publicOnly, scan, av.canned, apply), av.target)
+	av.w.SetContent(container.NewBorder(
+		container.NewVBox(header, toolbar), av.status, nil, nil, av.table,
+	))
+	av.w.SetOnClosed(func() {
+		if av.cancel != nil {
+			av.cancel()
+		}
+	})
+	av.w.Resize(fyne.NewSize(860, 520))
+	av.w.Show()
+
+	go func() {
+		ctx := context.Background()
+		pab, err := ai.PublicAccessBlock(ctx)
+		if err != nil {
+			slog.Warn("get public access block failed",
+				slog.String("error", err.Error()),
+			)
+			pabLabel.SetText("unavailable: " + unwrapError(err).Error())
+		} else {
+			av.pab = pab
+			pabLabel.SetText(pab.String())
+		}
+		acl, err := ai.BucketACL(ctx)
+		switch {
+		case err != nil:
+			slog.Warn("get bucket acl failed",
+				slog.String("error", err.Error()),
+			)
+			bucketLabel.SetText("unavailable: " + unwrapError(err).Error())
+		case len(acl.Public()) > 0 && !pab.IgnorePublicACLs:
+			bucketLabel.Importance = widget.DangerImportance
+			bucketLabel.SetText(acl.String() + ", owner " + acl.Owner)
+		default:
+			bucketLabel.SetText(acl.String() + ", owner " + acl.Owner)
+		}
+	}()
+	av.scan()
+}
+
+// filter picks the scanned objects to show
+func (av *aclView) filter() {
+	av.shown = av.shown[:0]
+	for _, o := range av.objects {
+		if !av.publicOnly.Checked || len(o.ACL.Public()) > 0 {
+			av.shown = append(av.shown, o)
+		}
+	}
+	av.table.Refresh()
+}
+
+// scan reads the ACL of the target object, or of everything under a target
+// prefix
+func (av *aclView) scan() {
+	if av.cancel != nil {
+		av.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	av.cancel = cancel
+	target := av.target.Text
+	av.status.SetText("Scanning " + target)
+	go func() {
+		var objects []ObjectACL
+		var err error
+		if target == "" || strings.HasSuffix(target, "/") {
+			objects, err = av.ai.ScanACL(ctx, target, func(n int) {
+				av.status.SetText(fmt.Sprintf("Scanning %s, %d objects read", target, n))
+			})
+		} else {
+			var acl ACL
+			acl, err = av.ai.ObjectACL(ctx, target)
+			objects = []ObjectACL{{Key: target, ACL: acl, Err: err}}
+			err = nil
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("scan acl failed",
+				slog.String("target", target),
+				slog.String("error", err.Error()),
+			)
+			av.status.SetText("")
+			dialog.ShowError(unwrapError(err), av.w)
+			return
+		}
+		public := 0
+		for _, o := range objects {
+			if len(o.ACL.Public()) > 0 {
+				public++
+			}
+		}
+		av.objects = objects
+		av.filter()
+		av.status.SetText(fmt.Sprintf("%d objects, %d public", len(objects), public))
+	}()
+}
+
+// apply shows a dry run of setting the chosen ACL on the scanned objects
+// and applies it once confirmed
+func (av *aclView) apply() {
+	if len(av.objects) == 0 {
+		av.status.SetText("Warn: scan a target first")
+		return
+	}
+	canned := av.canned.Selected
+	plan := aclPlan(av.objects, canned)
+	if cannedPublic(canned) && av.pab.BlockPublicACLs {
+		plan += "\n\nBlock public ACLs is on, S3 will reject this"
+	}
+	objects := av.objects
+	dialog.ShowConfirm("Apply "+canned, plan, func(ok bool) {
+		if !ok {
+			return
+		}
+		keys := make([]string, len(objects))
+		for i, o := range objects {
+			keys[i] = o.Key
+		}
+		go func() {
+			err := av.ai.ApplyACL(context.Background(), keys, canned, func(n int) {
+				av.status.SetText(fmt.Sprintf("Applied %s to %d of %d objects", canned, n, len(keys)))
+			})
+			if err != nil {
+				slog.Warn("apply acl failed",
+					slog.String("acl", canned),
+					slog.String("error", err.Error()),
+				)
+				dialog.ShowError(unwrapError(err), av.w)
+			} else {
+				slog.Info("apply acl success",
+					slog.String("acl", canned),
+					slog.Int("objects", len(keys)),
+				)
+			}
+			av.scan()
+		}()
+	}, av.w)
+}
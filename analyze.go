@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	analyzeTopN = 100
+	// treemapCells are the largest sub-prefixes drawn, the rest is one cell
+	treemapCells = 40
+)
+
+var treemapColors = []color.NRGBA{
+	{0x42, 0x85, 0xf4, 0xff},
+	{0x34, 0xa8, 0x53, 0xff},
+	{0xfb, 0xbc, 0x05, 0xff},
+	{0xea, 0x43, 0x35, 0xff},
+	{0x8e, 0x44, 0xad, 0xff},
+	{0x16, 0xa0, 0x85, 0xff},
+	{0xe6, 0x7e, 0x22, 0xff},
+	{0x7f, 0x8c, 0x8d, 0xff},
+}
+
+// analyzer walks a prefix and shows its size broken down, the tables read
+// the rows built from stats on each update
+type analyzer struct {
+	prefix string
+	w      fyne.Window
+
+	mu      sync.Mutex
+	stats   *PrefixStats
+	subs    []Total
+	classes []Total
+	exts    []Total
+	largest []File
+
+	total   *widget.Label
+	status  *widget.Label
+	tables  []*widget.Table
+	treemap *fyne.Container
+	stop    *widget.Button
+	cancel  context.CancelFunc
+}
+
+// analyzePrefix opens an analyzer on the directory prefix
+func (sc *Fone) analyzePrefix(prefix string) {
+	az := &analyzer{prefix: prefix, stats: newPrefixStats(analyzeTopN)}
+	name := prefix
+	if name == "" {
+		name = "/"
+	}
+	az.w = sc.a.NewWindow("Analyze " + name)
+	az.total = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	az.status = widget.NewLabel("")
+	az.stop = widget.NewButtonWithIcon("Stop", theme.MediaStopIcon(), func() {
+		az.cancel()
+	})
+	export := widget.NewButtonWithIcon("Export CSV", theme.DocumentSaveIcon(), az.export)
+	az.treemap = container.New(&treemapLayout{})
+
+	tabs := container.NewAppTabs(
+		container.NewTabItem("Sub-prefixes", az.totalsTable(func() []Total { return az.subs })),
+		container.NewTabItem("Treemap", az.treemap),
+		container.NewTabItem("Storage classes", az.totalsTable(func() []Total { return az.classes })),
+		container.NewTabItem("Extensions", az.totalsTable(func() []Total { return az.exts })),
+		container.NewTabItem("Largest", az.largestTable()),
+	)
+	top := container.NewBorder(nil, nil, nil, container.NewHBox(az.stop, export), container.NewVBox(az.total, az.status))
+	az.w.SetContent(container.NewBorder(top, nil, nil, nil, tabs))
+	az.w.SetOnClosed(func() {
+		az.cancel()
+	})
+	az.w.Resize(fyne.NewSize(820, 560))
+	az.w.Show()
+	az.run(sc.client)
+}
+
+// run walks the prefix, refreshing the view at most four times a second
+func (az *analyzer) run(c provider) {
+	ctx, cancel := context.WithCancel(context.Background())
+	az.cancel = cancel
+	start := time.Now()
+	az.status.SetText("Walking...")
+	go func() {
+		last := time.Now()
+		err := walkPrefix(ctx, c, az.prefix, func(data []File) error {
+			az.mu.Lock()
+			for _, f := range data {
+				az.stats.Add(f)
+			}
+			az.mu.Unlock()
+			if time.Since(last) > 250*time.Millisecond {
+				last = time.Now()
+				az.update()
+			}
+			return ctx.Err()
+		})
+		az.update()
+		az.stop.Disable()
+		elapsed := time.Since(start).Round(time.Millisecond)
+		switch {
+		case ctx.Err() != nil:
+			az.status.SetText(fmt.Sprintf("Stopped after %s, totals are partial", elapsed))
+		case err != nil:
+			slog.Warn("analyze failed",
+				slog.String("prefix", az.prefix),
+				slog.String("error", err.Error()),
+			)
+			az.status.SetText("Failed, totals are partial: " + unwrapError(err).Error())
+		default:
+			slog.Info("analyze success",
+				slog.String("prefix", az.prefix),
+				slog.Int64("count", az.stats.Count),
+				slog.Int64("bytes", az.stats.Bytes),
+			)
+			az.status.SetText(fmt.Sprintf("Done in %s", elapsed))
+		}
+	}()
+}
+
+// update rebuilds the rows and the treemap from the running totals
+func (az *analyzer) update() {
+	az.mu.Lock()
+	s := az.stats
+	az.subs = sortedTotals(s.BySub)
+	az.classes = sortedTotals(s.ByClass)
+	az.exts = sortedTotals(s.ByExt)
+	az.largest = append(az.largest[:0], s.Largest...)
+	total := fmt.Sprintf("%d objects, %s (%d bytes)", s.Count, bytefmt.ByteSize(uint64(s.Bytes)), s.Bytes)
+	subs := az.subs
+	az.mu.Unlock()
+
+	az.total.SetText(total)
+	for _, t := range az.tables {
+		t.Refresh()
+	}
+	az.updateTreemap(subs)
+}
+
+func (az *analyzer) updateTreemap(subs []Total) {
+	cells := subs
+	if len(subs) > treemapCells {
+		other := Total{Name: fmt.Sprintf("%d more", len(subs)-treemapCells+1)}
+		for _, t := range subs[treemapCells-1:] {
+			other.Count += t.Count
+			other.Bytes += t.Bytes
+		}
+		cells = append(subs[:treemapCells-1:treemapCells-1], other)
+	}
+	var weights []float64
+	var objects []fyne.CanvasObject
+	for i, t := range cells {
+		if t.Bytes <= 0 {
+			break
+		}
+		weights = append(weights, float64(t.Bytes))
+		bg := canvas.NewRectangle(treemapColors[i%len(treemapColors)])
+		bg.StrokeColor = theme.Color(theme.ColorNameBackground)
+		bg.StrokeWidth = 1
+		l := widget.NewLabel(t.Name + "\n" + bytefmt.ByteSize(uint64(t.Bytes)))
+		l.Truncation = fyne.TextTruncateClip
+		objects = append(objects, container.NewStack(bg, l))
+	}
+	az.treemap.Layout = &treemapLayout{weights: weights}
+	az.treemap.Objects = objects
+	az.treemap.Refresh()
+}
+
+func (az *analyzer) totalsTable(rows func() []Total) *widget.Table {
+	header := []string{"Name", "Objects", "Size", "Share"}
+	t := widget.NewTable(
+		func() (int, int) {
+			az.mu.Lock()
+			defer az.mu.Unlock()
+			return len(rows()) + 1, len(header)
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			l := o.(*widget.Label)
+			l.TextStyle.Bold = id.Row == 0
+			if id.Row == 0 {
+				l.SetText(header[id.Col])
+				return
+			}
+			az.mu.Lock()
+			r := rows()
+			if id.Row > len(r) {
+				az.mu.Unlock()
+				return
+			}
+			t, all := r[id.Row-1], az.stats.Bytes
+			az.mu.Unlock()
+			switch id.Col {
+			case 0:
+				l.SetText(t.Name)
+			case 1:
+				l.SetText(fmt.Sprint(t.Count))
+			case 2:
+				l.SetText(bytefmt.ByteSize(uint64(t.Bytes)))
+			case 3:
+				share := 0.0
+				if all > 0 {
+					share = float64(t.Bytes) / float64(all) * 100
+				}
+				l.SetText(fmt.Sprintf("%.1f%%", share))
+			}
+		},
+	)
+	t.SetColumnWidth(0, 360)
+	t.SetColumnWidth(1, 110)
+	t.SetColumnWidth(2, 110)
+	t.SetColumnWidth(3, 80)
+	az.tables = append(az.tables, t)
+	return t
+}
+
+func (az *analyzer) largestTable() *widget.Table {
+	header := []string{"Name", "Size", "Class", "Modified"}
+	t := widget.NewTable(
+		func() (int, int) {
+			az.mu.Lock()
+			defer az.mu.Unlock()
+			return len(az.largest) + 1, len(header)
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			l := o.(*widget.Label)
+			l.TextStyle.Bold = id.Row == 0
+			if id.Row == 0 {
+				l.SetText(header[id.Col])
+				return
+			}
+			az.mu.Lock()
+			if id.Row > len(az.largest) {
+				az.mu.Unlock()
+				return
+			}
+			f := az.largest[id.Row-1]
+			az.mu.Unlock()
+			switch id.Col {
+			case 0:
+				l.SetText(f.Name)
+			case 1:
+				l.SetText(bytefmt.ByteSize(uint64(f.Size)))
+			case 2:
+				l.SetText(f.StorageClass)
+			case 3:
+				l.SetText("")
+				if !f.Time.IsZero() {
+					l.SetText(f.Time.Local().Format("2006-01-02 15:04"))
+				}
+			}
+		},
+	)
+	t.SetColumnWidth(0, 420)
+	t.SetColumnWidth(1, 100)
+	t.SetColumnWidth(2, 120)
+	t.SetColumnWidth(3, 140)
+	az.tables = append(az.tables, t)
+	return t
+}
+
+// export saves the totals so far as CSV
+func (az *analyzer) export() {
+	d := dialog.NewFileSave(func(uc fyne.URIWriteCloser, e error) {
+		if e != nil || uc == nil {
+			return
+		}
+		defer uc.Close()
+		az.mu.Lock()
+		err := az.stats.WriteCSV(uc)
+		az.mu.Unlock()
+		if err != nil {
+			dialog.ShowError(err, az.w)
+			return
+		}
+		slog.Info("export analysis success",
+			slog.String("file", uc.URI().String()),
+		)
+	}, az.w)
+	name := strings.Trim(strings.ReplaceAll(az.prefix, "/", "-"), "-")
+	if name == "" {
+		name = "root"
+	}
+	d.SetFileName("fone-" + name + ".csv")
+	d.Show()
+}
+
+// treemapLayout places the cells of a treemap by weight
+type treemapLayout struct {
+	weights []float64
+}
+
+func (l *treemapLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	rects := squarify(l.weights, float64(size.Width), float64(size.Height))
+	for i, o := range objects {
+		if i >= len(rects) {
+			o.Hide()
+			continue
+		}
+		r := rects[i]
+		o.Move(fyne.NewPos(float32(r.X), float32(r.Y)))
+		o.Resize(fyne.NewSize(float32(r.W), float32(r.H)))
+	}
+}
+
+func (l *treemapLayout) MinSize([]fyne.CanvasObject) fyne.Size {
+	return fyne.NewSize(200, 150)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// bucketManager lists the buckets of an account, creates and deletes them
+type bucketManager struct {
+	sc      *Fone
+	admin   bucketAdmin
+	w       fyne.Window
+	buckets []BucketInfo
+	table   *widget.Table
+	// selected is the index into buckets, -1 for none
+	selected int
+	summary  map[string]*widget.Label
+	cancel   context.CancelFunc
+}
+
+var summaryFields = []string{"Objects", "Total size", "Versioning", "Encryption", "Public access"}
+
+func (sc *Fone) showBuckets(admin bucketAdmin) {
+	bm := &bucketManager{sc: sc, admin: admin, selected: -1, summary: map[string]*widget.Label{}}
+	bm.w = sc.a.NewWindow("Buckets")
+
+	header := []string{"Name", "Created", "Region"}
+	bm.table = widget.NewTable(
+		func() (int, int) {
+			return len(bm.buckets) + 1, len(header)
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			l := o.(*widget.Label)
+			l.TextStyle.Bold = id.Row == 0
+			if id.Row == 0 {
+				l.SetText(header[id.Col])
+				return
+			}
+			b := bm.buckets[id.Row-1]
+			switch id.Col {
+			case 0:
+				l.SetText(b.Name)
+			case 1:
+				l.SetText(b.Created.Local().Format("2006-01-02 15:04"))
+			case 2:
+				l.SetText(b.Region)
+			}
+		},
+	)
+	bm.table.SetColumnWidth(0, 220)
+	bm.table.SetColumnWidth(1, 140)
+	bm.table.SetColumnWidth(2, 120)
+	bm.table.OnSelected = func(id widget.TableCellID) {
+		if id.Row == 0 {
+			bm.table.UnselectAll()
+			return
+		}
+		if bm.selected != id.Row-1 {
+			bm.selected = id.Row - 1
+			bm.loadSummary(bm.buckets[bm.selected])
+		}
+	}
+
+	form := widget.NewForm()
+	for _, name := range summaryFields {
+		l := widget.NewLabel("")
+		l.Wrapping = fyne.TextWrapWord
+		bm.summary[name] = l
+		form.Append(name, l)
+	}
+
+	toolbar := widget.NewToolbar(
+		widget.NewToolbarAction(theme.ContentAddIcon(), bm.create),
+		widget.NewToolbarAction(theme.DeleteIcon(), bm.remove),
+		widget.NewToolbarSpacer(),
+		widget.NewToolbarAction(theme.ViewRefreshIcon(), bm.refresh),
+	)
+	split := container.NewHSplit(bm.table, container.NewVScroll(form))
+	split.SetOffset(0.55)
+	bm.w.SetContent(container.NewBorder(toolbar, nil, nil, nil, split))
+	bm.w.SetOnClosed(func() {
+		if bm.cancel != nil {
+			bm.cancel()
+		}
+	})
+	bm.w.Resize(fyne.NewSize(900, 420))
+	bm.w.Show()
+	bm.refresh()
+}
+
+func (bm *bucketManager) refresh() {
+	go func() {
+		data, err := bm.admin.ListBucketInfo(context.Background())
+		if err != nil {
+			slog.Warn("list buckets failed",
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), bm.w)
+			return
+		}
+		slices.SortFunc(data, func(a, b BucketInfo) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		bm.buckets = data
+		bm.selected = -1
+		bm.table.UnselectAll()
+		bm.table.Refresh()
+		bm.setSummary("", BucketSummary{})
+	}()
+}
+
+// loadSummary reads the summary of b, cancelling one still loading since
+// counting a large bucket takes a while
+func (bm *bucketManager) loadSummary(b BucketInfo) {
+	if bm.cancel != nil {
+		bm.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	bm.cancel = cancel
+	bm.setSummary("loading...", BucketSummary{})
+	go func() {
+		s := bm.admin.Summary(ctx, b.Name, b.Region)
+		if ctx.Err() != nil {
+			return
+		}
+		bm.setSummary("", s)
+	}()
+}
+
+func (bm *bucketManager) setSummary(text string, s BucketSummary) {
+	if text != "" || s.Errs == nil {
+		for _, l := range bm.summary {
+			l.SetText(text)
+		}
+		return
+	}
+	values := map[string]string{
+		"Objects":       fmt.Sprint(s.Objects),
+		"Total size":    bytefmt.ByteSize(uint64(s.Size)),
+		"Versioning":    s.Versioning,
+		"Encryption":    s.Encryption,
+		"Public access": s.PublicAccess,
+	}
+	errs := map[string]error{
+		"Objects":       s.Errs["objects"],
+		"Total size":    s.Errs["objects"],
+		"Versioning":    s.Errs["versioning"],
+		"Encryption":    s.Errs["encryption"],
+		"Public access": s.Errs["public access"],
+	}
+	for _, name := range summaryFields {
+		if err := errs[name]; err != nil {
+			bm.summary[name].SetText("unavailable: " + cmp.Or(apiErrorCode(err), unwrapError(err).Error()))
+			continue
+		}
+		bm.summary[name].SetText(values[name])
+	}
+}
+
+func (bm *bucketManager) create() {
+	name := widget.NewEntry()
+	name.Validator = validateBucketName
+	region := widget.NewEntry()
+	region.SetText(bm.admin.Region())
+	versioning := widget.NewCheck("Versioning", nil)
+	objectLock := widget.NewCheck("Object lock, enables versioning", func(on bool) {
+		if on {
+			versioning.SetChecked(true)
+			versioning.Disable()
+		} else {
+			versioning.Enable()
+		}
+	})
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", name),
+		widget.NewFormItem("Region", region),
+		widget.NewFormItem("", versioning),
+		widget.NewFormItem("", objectLock),
+	}
+	d := dialog.NewForm("New bucket", "Create", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		o := BucketOptions{
+			Region:     strings.TrimSpace(region.Text),
+			ObjectLock: objectLock.Checked,
+			Versioning: versioning.Checked,
+		}
+		go func() {
+			if err := bm.admin.MakeBucket(context.Background(), name.Text, o); err != nil {
+				slog.Warn("create bucket failed",
+					slog.String("bucket", name.Text),
+					slog.String("error", err.Error()),
+				)
+				dialog.ShowError(unwrapError(err), bm.w)
+				return
+			}
+			slog.Info("create bucket success",
+				slog.String("bucket", name.Text),
+				slog.String("region", o.Region),
+			)
+			bm.refresh()
+		}()
+	}, bm.w)
+	d.Resize(fyne.NewSize(420, 0))
+	d.Show()
+}
+
+func (bm *bucketManager) remove() {
+	if bm.selected < 0 || bm.selected >= len(bm.buckets) {
+		return
+	}
+	b := bm.buckets[bm.selected]
+	empty := widget.NewCheck("Empty first, deleting all objects and versions", nil)
+	confirm := widget.NewEntry()
+	confirm.SetPlaceHolder(b.Name)
+	confirm.Validator = func(s string) error {
+		if s != b.Name {
+			return errors.New("type the bucket name to confirm")
+		}
+		return nil
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("", empty),
+		widget.NewFormItem("Bucket name", confirm),
+	}
+	d := dialog.NewForm("Delete bucket "+b.Name, "Delete", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		status := widget.NewLabel("Deleting " + b.Name)
+		progress := dialog.NewCustomWithoutButtons("Delete bucket", container.NewVBox(widget.NewProgressBarInfinite(), status), bm.w)
+		progress.Show()
+		go func() {
+			err := bm.admin.RemoveBucket(context.Background(), b.Name, b.Region, empty.Checked, func(deleted int) {
+				status.SetText(fmt.Sprintf("Deleted %d objects and versions", deleted))
+			})
+			progress.Hide()
+			if err != nil {
+				slog.Warn("delete bucket failed",
+					slog.String("bucket", b.Name),
+					slog.String("error", err.Error()),
+				)
+				if !errors.Is(err, errLocked) {
+					// keep the count of locked versions
+					err = unwrapError(err)
+				}
+				dialog.ShowError(err, bm.w)
+				return
+			}
+			slog.Info("delete bucket success",
+				slog.String("bucket", b.Name),
+			)
+			bm.refresh()
+		}()
+	}, bm.w)
+	d.Resize(fyne.NewSize(420, 0))
+	d.Show()
+}
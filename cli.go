@@ -0,0 +1,813 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+	exitDenied   = 4
+)
+
+const cliUsage = `Usage: fone [-log file] [-debug] <command> [flags] [args]
+
+Commands:
+  ls    [REMOTE]             list a directory or prefix
+  stat  REMOTE               show file info
+  get   REMOTE [LOCAL|-]     download a file
+  put   LOCAL|- [REMOTE]     upload a file
+  rm    REMOTE...            delete files
+  cp    SRC DST              copy a file, prefix remote paths with ':'
+  sync  SRC DST              copy new and changed files between a local
+                             directory and a remote one, prefix the remote
+                             side with ':'
+
+Run 'fone <command> -h' for the connection flags. Saved secrets are kept
+in an encrypted vault, set FONE_VAULT_PASSWORD to use them with -saved or
+-profile.
+Without a command fone starts the GUI.
+`
+
+var cliCommands = map[string]func(ctx context.Context, cc *cliContext, args []string) error{
+	"ls":   cliList,
+	"stat": cliStat,
+	"get":  cliGet,
+	"put":  cliPut,
+	"rm":   cliRemove,
+	"cp":   cliCopy,
+	"sync": cliSync,
+}
+
+// fyneConfigDir returns the directory fyne stores app preferences in
+func fyneConfigDir() string {
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, "Library", "Preferences", "fyne")
+	case "windows":
+		return filepath.Join(home, "AppData", "Roaming", "fyne")
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "fyne")
+	}
+	return filepath.Join(home, ".config", "fyne")
+}
+
+// readPreferences reads the preferences saved by the GUI
+func readPreferences() (map[string]any, error) {
+	data, err := os.ReadFile(filepath.Join(fyneConfigDir(), "cc.shvc.fone", "preferences.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read preferences error %w", err)
+	}
+	prefs := map[string]any{}
+	if err = json.Unmarshal(data, &prefs); err != nil {
+		return nil, fmt.Errorf("parse preferences error %w", err)
+	}
+	return prefs, nil
+}
+
+// openSavedVault unlocks the vault saved by the GUI with the password from
+// FONE_VAULT_PASSWORD, v is nil if there is no vault
+func openSavedVault(prefs map[string]any) (v *Vault, err error) {
+	s, _ := prefs[vaultKey].(string)
+	if s == "" {
+		return nil, nil
+	}
+	password := os.Getenv("FONE_VAULT_PASSWORD")
+	if password == "" {
+		return nil, errors.New("vault is locked, set FONE_VAULT_PASSWORD")
+	}
+	if v, err = newVault(s, nil); err != nil {
+		return nil, err
+	}
+	if err = v.Unlock(password); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// loadSavedConfig reads the last S3 or sftp login saved by the GUI
+func loadSavedConfig(typ string) (cfg connConfig, err error) {
+	prefs, err := readPreferences()
+	if err != nil {
+		return cfg, err
+	}
+	v, err := openSavedVault(prefs)
+	if err != nil {
+		return cfg, err
+	}
+	get := func(key string) string {
+		s, _ := prefs[key].(string)
+		if v != nil && s == "" {
+			s = v.Get(key)
+		}
+		return s
+	}
+
+	cfg.Type = typ
+	switch typ {
+	case "s3":
+		cfg.Endpoint = get("cred.s3_endpoint")
+		cfg.Region = get("cred.s3_region")
+		cfg.Bucket = get("cred.s3_bucket")
+		cfg.Addressing = get("cred.s3_addressing")
+		cfg.MaxAttempts, _ = strconv.Atoi(get("cred.s3_max_attempts"))
+		cfg.ConnectTimeout, _ = strconv.Atoi(get("cred.s3_connect_timeout"))
+		cfg.ReadTimeout, _ = strconv.Atoi(get("cred.s3_read_timeout"))
+		cfg.MaxConns, _ = strconv.Atoi(get("cred.s3_max_conns"))
+		cfg.CAFile = get("cred.s3_ca_file")
+		cfg.ClientCert = get("cred.s3_client_cert")
+		cfg.ClientKey = get("cred.s3_client_key")
+		cfg.MinTLS = get("cred.s3_min_tls")
+		cfg.Pins = parseTags(get("cred.s3_pins"))
+		cfg.SkipVerify, _ = prefs["cred.s3_skip_verify"].(bool)
+		cfg.Proxy = get("cred.s3_proxy")
+		cfg.ProxyUser = get("cred.s3_proxy_user")
+		cfg.ProxyPassword = get("cred.s3_proxy_password")
+		cfg.SSE = get("cred.s3_sse")
+		cfg.SSEKMSKeyID = get("cred.s3_sse_kms_key_id")
+		cfg.SSEBucketKey, _ = prefs["cred.s3_sse_bucket_key"].(bool)
+		cfg.SSECKeyFile = get("cred.s3_sse_c_key_file")
+		cfg.Checksum = get("cred.s3_checksum")
+		cfg.CryptPassphrase = get("cred.s3_crypt_passphrase")
+		cfg.CryptKeyFile = get("cred.s3_crypt_key_file")
+		cfg.CryptPlainSizes, _ = prefs["cred.s3_crypt_plain_sizes"].(bool)
+		cfg.AccessKey = get("cred.s3_user")
+		cfg.SecretKey = get("cred.s3_pass")
+	case "sftp":
+		cfg.Server = get("cred.sftp_server")
+		cfg.Dir = get("cred.sftp_dir")
+		cfg.User = get("cred.sftp_user")
+		cfg.Password = get("cred.sftp_password")
+		cfg.Proxy = get("cred.sftp_proxy")
+		cfg.ProxyUser = get("cred.sftp_proxy_user")
+		cfg.ProxyPassword = get("cred.sftp_proxy_password")
+		if off, _ := prefs["cred.sftp_no_checksum"].(bool); off {
+			cfg.Checksum = checksumOff
+		}
+		cfg.CryptPassphrase = get("cred.sftp_crypt_passphrase")
+		cfg.CryptKeyFile = get("cred.sftp_crypt_key_file")
+		cfg.CryptPlainSizes, _ = prefs["cred.sftp_crypt_plain_sizes"].(bool)
+	default:
+		return cfg, fmt.Errorf("unknown connection type %q", typ)
+	}
+	return cfg, nil
+}
+
+// loadSavedProfile reads a named profile saved by the site manager
+func loadSavedProfile(name string) (cfg connConfig, err error) {
+	prefs, err := readPreferences()
+	if err != nil {
+		return cfg, err
+	}
+	s, _ := prefs[profilesKey].(string)
+	profiles, err := decodeProfiles([]byte(s), false)
+	if err != nil {
+		return cfg, err
+	}
+	p := findProfile(profiles, name)
+	if p == nil {
+		return cfg, fmt.Errorf("profile %q not found", name)
+	}
+	if p.SecretKey == "" && p.Password == "" {
+		v, err := openSavedVault(prefs)
+		if err != nil {
+			return cfg, err
+		}
+		if v != nil {
+			for field, s := range p.secrets() {
+				*s = v.Get(profileSecretKey(p.Name, field))
+			}
+		}
+	}
+	return p.connConfig, nil
+}
+
+type cliContext struct {
+	client     provider
+	pwd        string
+	isSftp     bool
+	json       bool
+	syncDelete bool
+	dryRun     bool
+	out        io.Writer
+}
+
+// cliFile is the machine-readable form of File
+type cliFile struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	ContentType string    `json:"content_type,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+	Mode        string    `json:"mode,omitempty"`
+	Owner       string    `json:"owner,omitempty"`
+	Link        string    `json:"link,omitempty"`
+	Class       string    `json:"storage_class,omitempty"`
+}
+
+func newCliFile(name string, f File) cliFile {
+	typ := "file"
+	if f.IsDir() {
+		typ = "dir"
+	}
+	cf := cliFile{
+		Name:        name,
+		Type:        typ,
+		Size:        f.Size,
+		Modified:    f.Time,
+		ContentType: f.ContentType,
+		ETag:        f.ETag,
+		Mode:        f.Perm(),
+		Link:        f.Link,
+		Class:       f.StorageClass,
+	}
+	if f.Mode != 0 {
+		cf.Owner = fmt.Sprintf("%d:%d", f.Uid, f.Gid)
+	}
+	return cf
+}
+
+// print writes v as a JSON line with -json, otherwise the text line
+func (cc *cliContext) print(v any, text string) {
+	if cc.json {
+		data, _ := json.Marshal(v)
+		fmt.Fprintln(cc.out, string(data))
+		return
+	}
+	fmt.Fprintln(cc.out, text)
+}
+
+// remote resolves a remote argument, relative sftp paths are joined to the
+// working directory
+func (cc *cliContext) remote(arg string) string {
+	arg = strings.TrimPrefix(arg, ":")
+	if cc.isSftp && !strings.HasPrefix(arg, "/") {
+		dir := strings.HasSuffix(arg, "/") || arg == ""
+		arg = path.Join(cc.pwd, arg)
+		if dir && !strings.HasSuffix(arg, "/") {
+			arg += "/"
+		}
+	}
+	return arg
+}
+
+// dirPrefix returns the List prefix of a remote directory argument
+func (cc *cliContext) dirPrefix(arg string) string {
+	arg = cc.remote(arg)
+	if arg != "" && !strings.HasSuffix(arg, "/") {
+		arg += "/"
+	}
+	return arg
+}
+
+// exitCode maps an error to the process exit code
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		switch status.HTTPStatusCode() {
+		case 404:
+			return exitNotFound
+		case 401, 403:
+			return exitDenied
+		}
+	}
+	switch {
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, fs.ErrNotExist):
+		return exitNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return exitDenied
+	}
+	return exitError
+}
+
+// runCLI runs a headless command and returns the exit code
+func runCLI(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return exitUsage
+	}
+	cmd, ok := cliCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fone: unknown command %q\n\n%s", args[0], cliUsage)
+		return exitUsage
+	}
+
+	var cfg connConfig
+	var saved, profile, storageClass string
+	var jsonOutput bool
+	fset := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fset.StringVar(&cfg.Type, "type", "s3", "connection type: s3 or sftp")
+	fset.StringVar(&saved, "saved", "", "use the s3 or sftp login saved by the GUI")
+	fset.StringVar(&profile, "profile", "", "use a profile saved in the site manager")
+	fset.StringVar(&cfg.Endpoint, "endpoint", os.Getenv("AWS_ENDPOINT_URL"), "s3 endpoint")
+	fset.StringVar(&cfg.Region, "region", os.Getenv("AWS_REGION"), "s3 region")
+	fset.StringVar(&cfg.Bucket, "bucket", "", "s3 bucket[/prefix]")
+	fset.StringVar(&cfg.Addressing, "addressing", addressingAuto, "s3 addressing, auto, path or virtual")
+	fset.IntVar(&cfg.MaxAttempts, "max-attempts", defaultMaxAttempts, "s3 tries per request, 1 disables retries")
+	fset.IntVar(&cfg.ConnectTimeout, "connect-timeout", 0, "s3 connect timeout in seconds, 0 for the default")
+	fset.IntVar(&cfg.ReadTimeout, "read-timeout", 0, "s3 read timeout in seconds, 0 for no limit")
+	fset.IntVar(&cfg.MaxConns, "max-conns", 0, "s3 connections per host, 0 for no limit")
+	fset.StringVar(&cfg.CAFile, "ca-file", "", "s3 CA bundle, a PEM file or a directory")
+	fset.StringVar(&cfg.ClientCert, "client-cert", "", "s3 client certificate PEM file")
+	fset.StringVar(&cfg.ClientKey, "client-key", "", "s3 client key PEM file, defaults to -client-cert")
+	fset.StringVar(&cfg.MinTLS, "min-tls", "", "s3 minimum TLS version, 1.2 or 1.3")
+	fset.Func("pin", "s3 trusted public key, base64 SHA-256 of the SPKI, may be repeated", func(s string) error {
+		cfg.Pins = append(cfg.Pins, s)
+		return nil
+	})
+	fset.BoolVar(&cfg.SkipVerify, "insecure", false, "s3 skip TLS certificate verification")
+	fset.StringVar(&cfg.AccessKey, "access-key", os.Getenv("AWS_ACCESS_KEY_ID"), "s3 access key")
+	fset.StringVar(&cfg.SecretKey, "secret-key", os.Getenv("AWS_SECRET_ACCESS_KEY"), "s3 secret key")
+	fset.StringVar(&cfg.Server, "server", "", "sftp server host[:port]")
+	fset.StringVar(&cfg.User, "user", "", "sftp user")
+	fset.StringVar(&cfg.Password, "password", os.Getenv("FONE_SFTP_PASSWORD"), "sftp password")
+	fset.StringVar(&cfg.Dir, "dir", "", "sftp working directory")
+	fset.StringVar(&cfg.KeyFile, "key-file", "", "sftp private key file, -password is its passphrase")
+	fset.StringVar(&cfg.Proxy, "proxy", "", "proxy URL, http://, https:// or socks5://, or direct")
+	fset.StringVar(&cfg.ProxyUser, "proxy-user", "", "proxy user")
+	fset.StringVar(&cfg.ProxyPassword, "proxy-password", os.Getenv("FONE_PROXY_PASSWORD"), "proxy password")
+	fset.StringVar(&storageClass, "storage-class", "", "s3 storage class of uploaded objects, like STANDARD_IA or GLACIER")
+	fset.StringVar(&cfg.SSE, "sse", "", "s3 server-side encryption, AES256, aws:kms or SSE-C")
+	fset.StringVar(&cfg.SSEKMSKeyID, "sse-kms-key-id", "", "s3 KMS key for aws:kms, empty for the AWS managed key")
+	fset.BoolVar(&cfg.SSEBucketKey, "sse-bucket-key", false, "s3 use a bucket key with aws:kms")
+	fset.StringVar(&cfg.SSECKeyFile, "sse-c-key-file", "", "s3 SSE-C key file, 32 bytes raw or base64")
+	fset.StringVar(&cfg.Checksum, "checksum", "", "verify transfers, md5, crc32c or sha256 sent with s3 uploads, or off")
+	fset.StringVar(&cfg.CryptPassphrase, "crypt-passphrase", os.Getenv("FONE_CRYPT_PASSPHRASE"), "encrypt uploads and decrypt downloads with this passphrase")
+	fset.StringVar(&cfg.CryptKeyFile, "crypt-key-file", "", "encrypt uploads and decrypt downloads with a 32 byte key file")
+	fset.BoolVar(&cfg.CryptPlainSizes, "crypt-plain-sizes", false, "list the content sizes of encrypted files")
+	fset.BoolVar(&jsonOutput, "json", false, "print JSON lines")
+	cc := &cliContext{
+		out: os.Stdout,
+	}
+	if args[0] == "sync" {
+		fset.BoolVar(&cc.syncDelete, "delete", false, "delete destination files missing from the source")
+		fset.BoolVar(&cc.dryRun, "dry-run", false, "print actions without doing them")
+	}
+	if err := fset.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if cfg.KeyFile != "" {
+		cfg.Auth = authKey
+	}
+
+	if saved != "" || profile != "" {
+		var err error
+		explicit := cfg
+		if profile != "" {
+			cfg, err = loadSavedProfile(profile)
+		} else {
+			cfg, err = loadSavedConfig(saved)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "fone:", err)
+			return exitUsage
+		}
+		// explicit flags override the saved values
+		fset.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "endpoint":
+				cfg.Endpoint = explicit.Endpoint
+			case "region":
+				cfg.Region = explicit.Region
+			case "bucket":
+				cfg.Bucket = explicit.Bucket
+			case "addressing":
+				cfg.Addressing = explicit.Addressing
+			case "max-attempts":
+				cfg.MaxAttempts = explicit.MaxAttempts
+			case "connect-timeout":
+				cfg.ConnectTimeout = explicit.ConnectTimeout
+			case "read-timeout":
+				cfg.ReadTimeout = explicit.ReadTimeout
+			case "max-conns":
+				cfg.MaxConns = explicit.MaxConns
+			case "ca-file":
+				cfg.CAFile = explicit.CAFile
+			case "client-cert":
+				cfg.ClientCert = explicit.ClientCert
+			case "client-key":
+				cfg.ClientKey = explicit.ClientKey
+			case "min-tls":
+				cfg.MinTLS = explicit.MinTLS
+			case "pin":
+				cfg.Pins = explicit.Pins
+			case "insecure":
+				cfg.SkipVerify = explicit.SkipVerify
+			case "proxy":
+				cfg.Proxy = explicit.Proxy
+			case "proxy-user":
+				cfg.ProxyUser = explicit.ProxyUser
+			case "proxy-password":
+				cfg.ProxyPassword = explicit.ProxyPassword
+			case "sse":
+				cfg.SSE = explicit.SSE
+			case "sse-kms-key-id":
+				cfg.SSEKMSKeyID = explicit.SSEKMSKeyID
+			case "sse-bucket-key":
+				cfg.SSEBucketKey = explicit.SSEBucketKey
+			case "sse-c-key-file":
+				cfg.SSECKeyFile = explicit.SSECKeyFile
+			case "checksum":
+				cfg.Checksum = explicit.Checksum
+			case "crypt-passphrase":
+				cfg.CryptPassphrase = explicit.CryptPassphrase
+			case "crypt-key-file":
+				cfg.CryptKeyFile = explicit.CryptKeyFile
+			case "crypt-plain-sizes":
+				cfg.CryptPlainSizes = explicit.CryptPlainSizes
+			case "access-key":
+				cfg.AccessKey = explicit.AccessKey
+			case "secret-key":
+				cfg.SecretKey = explicit.SecretKey
+			case "server":
+				cfg.Server = explicit.Server
+			case "user":
+				cfg.User = explicit.User
+			case "password":
+				cfg.Password = explicit.Password
+			case "dir":
+				cfg.Dir = explicit.Dir
+			case "key-file":
+				cfg.Auth = authKey
+				cfg.KeyFile = explicit.KeyFile
+			}
+		})
+	}
+
+	client, pwd, err := cfg.open()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fone:", err)
+		return exitCode(err)
+	}
+	ctx := context.Background()
+	defer client.Close(ctx)
+	if ce, ok := unwrap(client).(classEditor); ok && storageClass != "" {
+		ce.SetUploadClass(storageClass)
+	}
+
+	cc.client = client
+	cc.pwd = pwd
+	cc.isSftp = cfg.Type == "sftp"
+	cc.json = jsonOutput
+	if err = cmd(ctx, cc, fset.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "fone:", err)
+	}
+	return exitCode(err)
+}
+
+var errUsage = errors.New("invalid arguments")
+
+func cliList(ctx context.Context, cc *cliContext, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%w: ls [REMOTE]", errUsage)
+	}
+	prefix := ""
+	if len(args) == 1 {
+		prefix = args[0]
+	}
+	if prefix != "" || cc.isSftp {
+		prefix = cc.dirPrefix(prefix)
+	}
+
+	marker := ""
+	for {
+		data, next, err := cc.client.List(ctx, prefix, marker)
+		if err != nil {
+			return err
+		}
+		for _, f := range data {
+			cc.print(newCliFile(f.Name, f), f.String())
+		}
+		if next == "" {
+			return nil
+		}
+		marker = next
+	}
+}
+
+func cliStat(ctx context.Context, cc *cliContext, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: stat REMOTE", errUsage)
+	}
+	key := cc.remote(args[0])
+	f, err := cc.client.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+	text := fmt.Sprintf("%s %8s %s", f.Time.Format("2006-01-02 15:04:05"), bytefmt.ByteSize(uint64(f.Size)), key)
+	if f.ContentType != "" {
+		text += " " + f.ContentType
+	}
+	cc.print(newCliFile(key, f), text)
+	return nil
+}
+
+func cliGet(ctx context.Context, cc *cliContext, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("%w: get REMOTE [LOCAL|-]", errUsage)
+	}
+	local := path.Base(args[0])
+	if len(args) == 2 {
+		local = args[1]
+	}
+	key := cc.remote(args[0])
+	if err := cc.download(ctx, key, local); err != nil {
+		return err
+	}
+	if local != "-" {
+		cc.print(map[string]string{"action": "get", "key": key, "file": local}, "downloaded "+key+" to "+local)
+	}
+	return nil
+}
+
+func cliPut(ctx context.Context, cc *cliContext, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("%w: put LOCAL|- [REMOTE]", errUsage)
+	}
+	remote := filepath.Base(args[0])
+	if len(args) == 2 {
+		remote = args[1]
+		if strings.HasSuffix(remote, "/") {
+			remote += filepath.Base(args[0])
+		}
+	}
+	key := cc.remote(remote)
+	if err := cc.upload(ctx, args[0], key); err != nil {
+		return err
+	}
+	cc.print(map[string]string{"action": "put", "key": key, "file": args[0]}, "uploaded "+args[0]+" to "+key)
+	return nil
+}
+
+func cliRemove(ctx context.Context, cc *cliContext, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: rm REMOTE...", errUsage)
+	}
+	var firstErr error
+	for _, arg := range args {
+		key := cc.remote(arg)
+		if err := cc.client.Delete(ctx, key); err != nil {
+			fmt.Fprintf(os.Stderr, "fone: rm %s: %v\n", key, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		cc.print(map[string]string{"action": "rm", "key": key}, "removed "+key)
+	}
+	return firstErr
+}
+
+func cliCopy(ctx context.Context, cc *cliContext, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w: cp SRC DST", errUsage)
+	}
+	src, dst := args[0], args[1]
+	srcRemote, dstRemote := strings.HasPrefix(src, ":"), strings.HasPrefix(dst, ":")
+	if dstRemote && strings.HasSuffix(dst, "/") {
+		dst += path.Base(strings.TrimPrefix(src, ":"))
+	}
+	switch {
+	case srcRemote && dstRemote:
+		tmp, err := os.CreateTemp("", "fone-cp-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if err = cc.client.Download(ctx, tmp, cc.remote(src)); err != nil {
+			return err
+		}
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		key := cc.remote(dst)
+		if err = cc.client.Upload(ctx, tmp, key, mime.TypeByExtension(path.Ext(key))); err != nil {
+			return err
+		}
+		cc.print(map[string]string{"action": "cp", "src": cc.remote(src), "key": key}, "copied "+cc.remote(src)+" to "+key)
+		return nil
+	case srcRemote:
+		return cliGet(ctx, cc, []string{src, dst})
+	case dstRemote:
+		return cliPut(ctx, cc, []string{src, dst})
+	}
+	return fmt.Errorf("%w: cp needs at least one remote path prefixed with ':'", errUsage)
+}
+
+func (cc *cliContext) download(ctx context.Context, key, local string) (err error) {
+	var w io.Writer = os.Stdout
+	if local != "-" {
+		if fi, e := os.Stat(local); e == nil && fi.IsDir() {
+			local = filepath.Join(local, path.Base(key))
+		}
+		var fd *os.File
+		if fd, err = os.Create(local); err != nil {
+			return err
+		}
+		defer func() {
+			if e := fd.Close(); err == nil {
+				err = e
+			}
+			// a corrupted copy is worse than none
+			if errors.Is(err, errChecksumMismatch) {
+				os.Remove(local)
+			}
+		}()
+		w = fd
+	}
+	return cc.client.Download(ctx, w, key)
+}
+
+func (cc *cliContext) upload(ctx context.Context, local, key string) error {
+	var rs io.ReadSeeker
+	if local == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		rs = bytes.NewReader(data)
+	} else {
+		fd, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		rs = fd
+	}
+	return cc.client.Upload(ctx, rs, key, mime.TypeByExtension(path.Ext(key)))
+}
+
+// walkRemote calls fn for every file below the remote directory prefix,
+// rel is the path relative to prefix. Symlinked directories are skipped
+func walkRemote(ctx context.Context, c provider, prefix string, fn func(rel string, f File) error) error {
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		marker := ""
+		for {
+			data, next, err := c.List(ctx, prefix+dir, marker)
+			if err != nil {
+				return err
+			}
+			for _, f := range data {
+				if f.IsDir() {
+					// links to directories can loop, like ln -s .. up
+					if f.IsLink() {
+						continue
+					}
+					if err = walk(dir + f.Name); err != nil {
+						return err
+					}
+					continue
+				}
+				if err = fn(dir+f.Name, f); err != nil {
+					return err
+				}
+			}
+			if next == "" {
+				return nil
+			}
+			marker = next
+		}
+	}
+	return walk("")
+}
+
+// syncNeeded reports whether src must be copied over dst
+func syncNeeded(src File, dst File, dstExists bool) bool {
+	return !dstExists || src.Size != dst.Size || src.Time.After(dst.Time)
+}
+
+func cliSync(ctx context.Context, cc *cliContext, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w: sync SRC DST", errUsage)
+	}
+	src, dst := args[0], args[1]
+	upload := strings.HasPrefix(dst, ":")
+	if upload == strings.HasPrefix(src, ":") {
+		return fmt.Errorf("%w: sync needs one local and one remote path prefixed with ':'", errUsage)
+	}
+
+	localDir, remoteArg := src, dst
+	if !upload {
+		localDir, remoteArg = dst, src
+	}
+	prefix := ""
+	if strings.TrimPrefix(remoteArg, ":") != "" || cc.isSftp {
+		prefix = cc.dirPrefix(remoteArg)
+	}
+
+	local := map[string]File{}
+	err := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == localDir && !upload {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(localDir, p)
+		local[filepath.ToSlash(rel)] = File{Name: rel, Size: fi.Size(), Time: fi.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	remote := map[string]File{}
+	err = walkRemote(ctx, cc.client, prefix, func(rel string, f File) error {
+		remote[rel] = f
+		return nil
+	})
+	if err != nil && !(upload && exitCode(err) == exitNotFound) {
+		return err
+	}
+
+	from, to := local, remote
+	if !upload {
+		from, to = remote, local
+	}
+	action := func(act, rel string) {
+		text := act + " " + rel
+		if cc.dryRun {
+			text = "(dry-run) " + text
+		}
+		cc.print(map[string]any{"action": act, "path": rel, "dry_run": cc.dryRun}, text)
+	}
+
+	for rel, f := range from {
+		d, ok := to[rel]
+		if !syncNeeded(f, d, ok) {
+			continue
+		}
+		action("copy", rel)
+		if cc.dryRun {
+			continue
+		}
+		localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+		if upload {
+			err = cc.upload(ctx, localPath, prefix+rel)
+		} else {
+			if err = os.MkdirAll(filepath.Dir(localPath), 0755); err == nil {
+				err = cc.download(ctx, prefix+rel, localPath)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("sync %s error %w", rel, err)
+		}
+	}
+
+	if !cc.syncDelete {
+		return nil
+	}
+	for rel := range to {
+		if _, ok := from[rel]; ok {
+			continue
+		}
+		action("delete", rel)
+		if cc.dryRun {
+			continue
+		}
+		if upload {
+			err = cc.client.Delete(ctx, prefix+rel)
+		} else {
+			err = os.Remove(filepath.Join(localDir, filepath.FromSlash(rel)))
+		}
+		if err != nil {
+			return fmt.Errorf("sync delete %s error %w", rel, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testStatusError struct {
+	code int
+}
+
+func (e *testStatusError) Error() string {
+	return fmt.Sprintf("status %d", e.code)
+}
+
+func (e *testStatusError) HTTPStatusCode() int {
+	return e.code
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: exitOK},
+		{name: "not exist", err: fmt.Errorf("open a error %w", fs.ErrNotExist), want: exitNotFound},
+		{name: "permission", err: fmt.Errorf("open a error %w", fs.ErrPermission), want: exitDenied},
+		{name: "http 404", err: fmt.Errorf("head error %w", &testStatusError{code: 404}), want: exitNotFound},
+		{name: "http 403", err: &testStatusError{code: 403}, want: exitDenied},
+		{name: "http 500", err: &testStatusError{code: 500}, want: exitError},
+		{name: "usage", err: fmt.Errorf("%w: ls [REMOTE]", errUsage), want: exitUsage},
+		{name: "tls", err: fmt.Errorf("list error %w", x509.UnknownAuthorityError{}), want: exitError},
+		{name: "other", err: &testError{msg: "boom"}, want: exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCLI_ServerDown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	if got := runCLI([]string{"ls", "-type", "sftp", "-server", addr, "-user", "test"}); got != exitError {
+		t.Errorf("runCLI() with the server down = %v, want %v", got, exitError)
+	}
+	if got := runCLI([]string{"ls", "-bogus"}); got != exitUsage {
+		t.Errorf("runCLI() with a bad flag = %v, want %v", got, exitUsage)
+	}
+}
+
+func TestCliContext_Remote(t *testing.T) {
+	s3 := &cliContext{}
+	if got := s3.remote(":a/b.txt"); got != "a/b.txt" {
+		t.Errorf("remote() = %v, want a/b.txt", got)
+	}
+	if got := s3.dirPrefix("a"); got != "a/" {
+		t.Errorf("dirPrefix() = %v, want a/", got)
+	}
+
+	sftp := &cliContext{isSftp: true, pwd: "/home/test/"}
+	if got := sftp.remote("a/b.txt"); got != "/home/test/a/b.txt" {
+		t.Errorf("remote() = %v, want /home/test/a/b.txt", got)
+	}
+	if got := sftp.remote("/etc/hosts"); got != "/etc/hosts" {
+		t.Errorf("remote() = %v, want /etc/hosts", got)
+	}
+	if got := sftp.dirPrefix(""); got != "/home/test/" {
+		t.Errorf("dirPrefix() = %v, want /home/test/", got)
+	}
+}
+
+func TestWalkRemote(t *testing.T) {
+	m := &memProvider{files: map[string][]byte{
+		"p/a.txt":     []byte("a"),
+		"p/d/b.txt":   []byte("bb"),
+		"p/d/e/c.txt": []byte("ccc"),
+		"q/x.txt":     []byte("x"),
+	}}
+	var got []string
+	err := walkRemote(context.Background(), m, "p/", func(rel string, f File) error {
+		got = append(got, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walkRemote() error = %v", err)
+	}
+	sort.Strings(got)
+	want := []string{"a.txt", "d/b.txt", "d/e/c.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("walkRemote() = %v, want %v", got, want)
+	}
+}
+
+func TestWalkRemote_LinkLoop(t *testing.T) {
+	srv := newSftpTestServer(t)
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "d", "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(dir, "d", "up")); err != nil {
+		t.Fatal(err)
+	}
+	c, pwd, err := NewSftpClient(srv.addr, "test", "pass", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var got []string
+	err = walkRemote(ctx, c, pwd+"/", func(rel string, f File) error {
+		got = append(got, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walkRemote() error = %v", err)
+	}
+	if strings.Join(got, ",") != "d/a.txt" {
+		t.Errorf("walkRemote() = %v, want [d/a.txt]", got)
+	}
+}
+
+func TestSyncNeeded(t *testing.T) {
+	now := time.Now()
+	if !syncNeeded(File{Size: 1}, File{}, false) {
+		t.Errorf("syncNeeded() missing destination = false, want true")
+	}
+	if !syncNeeded(File{Size: 1, Time: now}, File{Size: 2, Time: now}, true) {
+		t.Errorf("syncNeeded() size differs = false, want true")
+	}
+	if !syncNeeded(File{Size: 1, Time: now}, File{Size: 1, Time: now.Add(-time.Hour)}, true) {
+		t.Errorf("syncNeeded() newer source = false, want true")
+	}
+	if syncNeeded(File{Size: 1, Time: now.Add(-time.Hour)}, File{Size: 1, Time: now}, true) {
+		t.Errorf("syncNeeded() up to date = true, want false")
+	}
+}
+
+func TestCliSyncUpload(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b"), 0644)
+
+	m := &memProvider{files: map[string][]byte{"dst/old.txt": []byte("old")}}
+	var out bytes.Buffer
+	cc := &cliContext{client: m, out: &out, syncDelete: true}
+	if err := cliSync(context.Background(), cc, []string{dir, ":dst"}); err != nil {
+		t.Fatalf("cliSync() error = %v", err)
+	}
+	if string(m.files["dst/a.txt"]) != "a" || string(m.files["dst/sub/b.txt"]) != "b" {
+		t.Errorf("cliSync() files = %v", m.files)
+	}
+	if _, ok := m.files["dst/old.txt"]; ok {
+		t.Errorf("cliSync() -delete kept dst/old.txt")
+	}
+
+	// dry run changes nothing
+	os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c"), 0644)
+	cc.dryRun = true
+	out.Reset()
+	if err := cliSync(context.Background(), cc, []string{dir, ":dst"}); err != nil {
+		t.Fatalf("cliSync(dry-run) error = %v", err)
+	}
+	if _, ok := m.files["dst/c.txt"]; ok {
+		t.Errorf("cliSync(dry-run) uploaded dst/c.txt")
+	}
+	if !strings.Contains(out.String(), "c.txt") {
+		t.Errorf("cliSync(dry-run) output = %q, want c.txt", out.String())
+	}
+}
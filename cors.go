@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// corsView edits the CORS rules of the current bucket, changes stay local
+// until saved
+type corsView struct {
+	ce    corsEditor
+	w     fyne.Window
+	rules []CORSRule
+	list  *widget.List
+	// cur is the rule in the form, -1 for none
+	cur int
+
+	id      *widget.Entry
+	origins *widget.Entry
+	methods *widget.CheckGroup
+	headers *widget.Entry
+	expose  *widget.Entry
+	maxAge  *widget.Entry
+	form    *widget.Form
+}
+
+func (sc *Fone) showCORS(ce corsEditor) {
+	go func() {
+		rules, err := ce.CORS(context.Background())
+		if err != nil {
+			slog.Warn("get cors failed",
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), sc.w)
+			return
+		}
+		cv := &corsView{ce: ce, rules: rules, cur: -1}
+		cv.w = sc.a.NewWindow("CORS rules")
+		cv.build()
+		cv.w.Resize(fyne.NewSize(820, 480))
+		cv.w.Show()
+	}()
+}
+
+func (cv *corsView) build() {
+	cv.list = widget.NewList(
+		func() int {
+			return len(cv.rules)
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			r := cv.rules[id]
+			text := r.ID
+			if text == "" {
+				text = strings.Join(r.AllowedOrigins, ", ")
+			}
+			o.(*widget.Label).SetText(text)
+		},
+	)
+	cv.list.OnSelected = func(id widget.ListItemID) {
+		if id == cv.cur {
+			return
+		}
+		if err := cv.commit(); err != nil {
+			dialog.ShowError(err, cv.w)
+			cv.list.Select(cv.cur)
+			return
+		}
+		cv.load(id)
+	}
+
+	cv.id = widget.NewEntry()
+	cv.id.SetPlaceHolder("optional")
+	cv.origins = widget.NewEntry()
+	cv.origins.SetPlaceHolder("https://example.com, https://*.example.com")
+	cv.methods = widget.NewCheckGroup(corsMethods, nil)
+	cv.methods.Horizontal = true
+	cv.headers = widget.NewEntry()
+	cv.headers.SetPlaceHolder("*")
+	cv.expose = widget.NewEntry()
+	cv.expose.SetPlaceHolder("ETag, x-amz-meta-custom")
+	cv.maxAge = widget.NewEntry()
+	cv.maxAge.SetPlaceHolder("seconds, empty for unset")
+	cv.maxAge.Validator = func(s string) error {
+		_, err := parseMaxAge(s)
+		return err
+	}
+	cv.form = widget.NewForm(
+		widget.NewFormItem("ID", cv.id),
+		widget.NewFormItem("Allowed origins", cv.origins),
+		widget.NewFormItem("Allowed methods", cv.methods),
+		widget.NewFormItem("Allowed headers", cv.headers),
+		widget.NewFormItem("Expose headers", cv.expose),
+		widget.NewFormItem("Max age", cv.maxAge),
+	)
+	cv.form.Hide()
+
+	toolbar := widget.NewToolbar(
+		widget.NewToolbarAction(theme.ContentAddIcon(), cv.add),
+		widget.NewToolbarAction(theme.DeleteIcon(), cv.remove),
+	)
+	test := widget.NewButtonWithIcon("Test CORS", theme.SearchIcon(), cv.test)
+	save := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), cv.save)
+	save.Importance = widget.HighImportance
+	split := container.NewHSplit(
+		container.NewBorder(toolbar, nil, nil, nil, cv.list),
+		container.NewVScroll(cv.form),
+	)
+	split.SetOffset(0.3)
+	cv.w.SetContent(container.NewBorder(nil, container.NewHBox(test, layout.NewSpacer(), save), nil, nil, split))
+}
+
+// load shows rule i in the form
+func (cv *corsView) load(i int) {
+	cv.cur = i
+	if i < 0 || i >= len(cv.rules) {
+		cv.cur = -1
+		cv.form.Hide()
+		return
+	}
+	r := cv.rules[i]
+	cv.id.SetText(r.ID)
+	cv.origins.SetText(strings.Join(r.AllowedOrigins, ", "))
+	cv.methods.SetSelected(r.AllowedMethods)
+	cv.headers.SetText(strings.Join(r.AllowedHeaders, ", "))
+	cv.expose.SetText(strings.Join(r.ExposeHeaders, ", "))
+	cv.maxAge.SetText("")
+	if r.MaxAgeSeconds > 0 {
+		cv.maxAge.SetText(strconv.Itoa(r.MaxAgeSeconds))
+	}
+	cv.form.Show()
+}
+
+// commit stores the form into the current rule
+func (cv *corsView) commit() (err error) {
+	if cv.cur < 0 {
+		return nil
+	}
+	maxAge, err := parseMaxAge(cv.maxAge.Text)
+	if err != nil {
+		return
+	}
+	r := &cv.rules[cv.cur]
+	r.ID = strings.TrimSpace(cv.id.Text)
+	r.AllowedOrigins = parseTags(cv.origins.Text)
+	// keep the methods in the order S3 lists them
+	r.AllowedMethods = slices.DeleteFunc(slices.Clone(corsMethods), func(m string) bool {
+		return !slices.Contains(cv.methods.Selected, m)
+	})
+	r.AllowedHeaders = parseTags(cv.headers.Text)
+	r.ExposeHeaders = parseTags(cv.expose.Text)
+	r.MaxAgeSeconds = maxAge
+	cv.list.RefreshItem(cv.cur)
+	return nil
+}
+
+// parseMaxAge reads seconds, 0 for empty
+func parseMaxAge(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	secs, err := strconv.Atoi(s)
+	if err != nil || secs < 0 {
+		return 0, fmt.Errorf("invalid max age %q", s)
+	}
+	return secs, nil
+}
+
+func (cv *corsView) add() {
+	if err := cv.commit(); err != nil {
+		dialog.ShowError(err, cv.w)
+		return
+	}
+	cv.rules = append(cv.rules, CORSRule{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodGet, http.MethodHead},
+	})
+	cv.list.Refresh()
+	cv.list.Select(len(cv.rules) - 1)
+}
+
+func (cv *corsView) remove() {
+	if cv.cur < 0 {
+		return
+	}
+	cv.rules = append(cv.rules[:cv.cur], cv.rules[cv.cur+1:]...)
+	cv.cur = -1
+	cv.list.UnselectAll()
+	cv.list.Refresh()
+	cv.load(-1)
+}
+
+func (cv *corsView) save() {
+	if err := cv.commit(); err != nil {
+		dialog.ShowError(err, cv.w)
+		return
+	}
+	if err := validateCORS(cv.rules); err != nil {
+		dialog.ShowError(err, cv.w)
+		return
+	}
+	go func() {
+		if err := cv.ce.SetCORS(context.Background(), cv.rules); err != nil {
+			slog.Warn("set cors failed",
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), cv.w)
+			return
+		}
+		slog.Info("set cors success",
+			slog.Int("rules", len(cv.rules)),
+		)
+		dialog.ShowInformation("CORS", fmt.Sprintf("Saved %d rules", len(cv.rules)), cv.w)
+	}()
+}
+
+// test simulates a preflight against the rules in the editor and, if asked,
+// sends it to the bucket which answers with the saved rules
+func (cv *corsView) test() {
+	if err := cv.commit(); err != nil {
+		dialog.ShowError(err, cv.w)
+		return
+	}
+	origin := widget.NewEntry()
+	origin.SetPlaceHolder("https://example.com")
+	origin.Validator = func(s string) error {
+		if s == "" {
+			return errors.New("origin required")
+		}
+		return nil
+	}
+	method := widget.NewSelect(corsMethods, nil)
+	method.SetSelected(http.MethodGet)
+	headers := widget.NewEntry()
+	headers.SetPlaceHolder("content-type, x-amz-date")
+	key := widget.NewEntry()
+	key.SetPlaceHolder("object key, any will do")
+	key.SetText("index.html")
+	server := widget.NewCheck("Also send to the bucket, which uses the saved rules", nil)
+	items := []*widget.FormItem{
+		widget.NewFormItem("Origin", origin),
+		widget.NewFormItem("Method", method),
+		widget.NewFormItem("Request headers", headers),
+		widget.NewFormItem("Key", key),
+		widget.NewFormItem("", server),
+	}
+	d := dialog.NewForm("Test CORS", "Test", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		hs := parseTags(headers.Text)
+		var b strings.Builder
+		if i := matchCORS(cv.rules, origin.Text, method.Selected, hs); i < 0 {
+			b.WriteString("Editor rules: denied, no rule matches\n")
+		} else {
+			r := cv.rules[i]
+			fmt.Fprintf(&b, "Editor rules: allowed by rule %d %s\n", i+1, r.ID)
+			fmt.Fprintf(&b, "Access-Control-Allow-Methods: %s\n", strings.Join(r.AllowedMethods, ", "))
+			if len(r.ExposeHeaders) > 0 {
+				fmt.Fprintf(&b, "Access-Control-Expose-Headers: %s\n", strings.Join(r.ExposeHeaders, ", "))
+			}
+			if r.MaxAgeSeconds > 0 {
+				fmt.Fprintf(&b, "Access-Control-Max-Age: %d\n", r.MaxAgeSeconds)
+			}
+		}
+		if !server.Checked {
+			dialog.ShowInformation("Test CORS", b.String(), cv.w)
+			return
+		}
+		go func() {
+			res, err := cv.ce.PreflightCORS(context.Background(), key.Text, origin.Text, method.Selected, hs)
+			if err != nil {
+				slog.Warn("cors preflight failed",
+					slog.String("origin", origin.Text),
+					slog.String("error", err.Error()),
+				)
+				fmt.Fprintf(&b, "\nBucket: %s\n", unwrapError(err))
+			} else {
+				fmt.Fprintf(&b, "\nBucket: %d %s\n", res.Status, http.StatusText(res.Status))
+				var names []string
+				for k := range res.Headers {
+					names = append(names, k)
+				}
+				slices.Sort(names)
+				for _, k := range names {
+					fmt.Fprintf(&b, "%s: %s\n", k, strings.Join(res.Headers[k], ", "))
+				}
+			}
+			dialog.ShowInformation("Test CORS", b.String(), cv.w)
+		}()
+	}, cv.w)
+	d.Resize(fyne.NewSize(460, 0))
+	d.Show()
+}
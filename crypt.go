@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/widget"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// client-side encrypted files start with a header of
+//
+//	magic | kdf | salt | wrap nonce | wrapped data key | stream nonce prefix
+//
+// followed by the content in ChaCha20-Poly1305 chunks, each with the chunk
+// number and a last flag in its nonce so chunks can't be reordered or cut off
+const (
+	cryptMagic     = "FONECSE1"
+	cryptChunkSize = 64 * 1024
+
+	cryptKDFKeyFile = 1
+	cryptKDFArgon2  = 2
+
+	cryptKDFOff       = 8
+	cryptSaltOff      = cryptKDFOff + 1
+	cryptWrapNonceOff = cryptSaltOff + 16
+	cryptWrappedOff   = cryptWrapNonceOff + chacha20poly1305.NonceSizeX
+	cryptPrefixOff    = cryptWrappedOff + chacha20poly1305.KeySize + chacha20poly1305.Overhead
+	cryptHeaderSize   = cryptPrefixOff + 7
+	cryptSealedChunk  = cryptChunkSize + chacha20poly1305.Overhead
+)
+
+var (
+	errCryptKey     = errors.New("wrong encryption passphrase or key")
+	errCryptCorrupt = errors.New("encrypted file is corrupt or truncated")
+	errCryptPlain   = errors.New("file is not encrypted")
+)
+
+// cryptSize is the encrypted size of plain bytes
+func cryptSize(plain int64) int64 {
+	chunks := max(1, (plain+cryptChunkSize-1)/cryptChunkSize)
+	return cryptHeaderSize + plain + chunks*chacha20poly1305.Overhead
+}
+
+// plainSize is the content size of an encrypted file of size bytes
+func plainSize(size int64) int64 {
+	sealed := size - cryptHeaderSize
+	if sealed < chacha20poly1305.Overhead {
+		return 0
+	}
+	chunks := (sealed + cryptSealedChunk - 1) / cryptSealedChunk
+	return sealed - chunks*chacha20poly1305.Overhead
+}
+
+// cryptMaster wraps the random data key of every file with a key from a
+// key file or derived from a passphrase with Argon2id
+type cryptMaster struct {
+	passphrase string
+	fileKey    []byte
+
+	mu sync.Mutex
+	// salt is used for all uploads of a session, so the slow derivation
+	// runs once
+	salt []byte
+	// keys are the derived keys by salt
+	keys map[string][]byte
+}
+
+func newCryptMaster(passphrase, keyFile string) (m *cryptMaster, err error) {
+	m = &cryptMaster{passphrase: passphrase, keys: map[string][]byte{}}
+	switch {
+	case passphrase != "" && keyFile != "":
+		return nil, errors.New("use an encryption passphrase or a key file, not both")
+	case keyFile != "":
+		if m.fileKey, err = loadKeyFile(keyFile); err != nil {
+			return nil, err
+		}
+	case passphrase == "":
+		return nil, errors.New("encryption needs a passphrase or a key file")
+	}
+	return
+}
+
+// kek returns the key that wraps data keys for kdf and salt
+func (m *cryptMaster) kek(kdf byte, salt []byte) ([]byte, error) {
+	switch kdf {
+	case cryptKDFKeyFile:
+		if m.fileKey == nil {
+			return nil, fmt.Errorf("%w: the file was encrypted with a key file", errCryptKey)
+		}
+		return m.fileKey, nil
+	case cryptKDFArgon2:
+		if m.passphrase == "" {
+			return nil, fmt.Errorf("%w: the file was encrypted with a passphrase", errCryptKey)
+		}
+	default:
+		return nil, fmt.Errorf("unknown encryption kdf %d", kdf)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if key, ok := m.keys[string(salt)]; ok {
+		return key, nil
+	}
+	key := argon2.IDKey([]byte(m.passphrase), salt, 3, 64*1024, 4, chacha20poly1305.KeySize)
+	m.keys[string(salt)] = key
+	return key, nil
+}
+
+// newHeader creates a random data key and the header holding it
+func (m *cryptMaster) newHeader() (header, dataKey []byte, err error) {
+	header = make([]byte, cryptHeaderSize)
+	copy(header, cryptMagic)
+	kdf := byte(cryptKDFKeyFile)
+	if m.fileKey == nil {
+		kdf = cryptKDFArgon2
+		m.mu.Lock()
+		if m.salt == nil {
+			m.salt = make([]byte, 16)
+			_, err = rand.Read(m.salt)
+		}
+		copy(header[cryptSaltOff:], m.salt)
+		m.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+	header[cryptKDFOff] = kdf
+	dataKey = make([]byte, chacha20poly1305.KeySize)
+	if _, err = rand.Read(dataKey); err != nil {
+		return
+	}
+	if _, err = rand.Read(header[cryptWrapNonceOff:cryptWrappedOff]); err != nil {
+		return
+	}
+	if _, err = rand.Read(header[cryptPrefixOff:]); err != nil {
+		return
+	}
+	kek, err := m.kek(kdf, header[cryptSaltOff:cryptWrapNonceOff])
+	if err != nil {
+		return
+	}
+	aead, err := chacha20poly1305.NewX(kek)
+	if err != nil {
+		return
+	}
+	wrapped := aead.Seal(nil, header[cryptWrapNonceOff:cryptWrappedOff], dataKey, header[:cryptWrapNonceOff])
+	copy(header[cryptWrappedOff:cryptPrefixOff], wrapped)
+	return
+}
+
+// openHeader unwraps the data key of header
+func (m *cryptMaster) openHeader(header []byte) (dataKey []byte, err error) {
+	if len(header) != cryptHeaderSize || !bytes.HasPrefix(header, []byte(cryptMagic)) {
+		return nil, errCryptCorrupt
+	}
+	kek, err := m.kek(header[cryptKDFOff], header[cryptSaltOff:cryptWrapNonceOff])
+	if err != nil {
+		return
+	}
+	aead, err := chacha20poly1305.NewX(kek)
+	if err != nil {
+		return
+	}
+	dataKey, err = aead.Open(nil, header[cryptWrapNonceOff:cryptWrappedOff], header[cryptWrappedOff:cryptPrefixOff], header[:cryptWrapNonceOff])
+	if err != nil {
+		return nil, errCryptKey
+	}
+	return
+}
+
+func chunkNonce(header []byte, chunk int64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	n := copy(nonce, header[cryptPrefixOff:])
+	binary.BigEndian.PutUint32(nonce[n:], uint32(chunk))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptReader is the encrypted form of a plaintext io.ReadSeeker, chunks
+// are sealed on demand so it can seek like its source
+type encryptReader struct {
+	src    io.ReadSeeker
+	base   int64
+	size   int64
+	header []byte
+	aead   cipher.AEAD
+	off    int64
+	// chunk is the index of the sealed chunk in buf, -1 for none
+	chunk int64
+	buf   []byte
+	plain []byte
+}
+
+func (m *cryptMaster) encryptReader(src io.ReadSeeker) (r *encryptReader, err error) {
+	r = &encryptReader{src: src, chunk: -1}
+	if r.base, err = src.Seek(0, io.SeekCurrent); err != nil {
+		return
+	}
+	end, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	r.size = end - r.base
+	header, dataKey, err := m.newHeader()
+	if err != nil {
+		return
+	}
+	r.header = header
+	r.aead, err = chacha20poly1305.New(dataKey)
+	return
+}
+
+func (r *encryptReader) Read(p []byte) (n int, err error) {
+	total := cryptSize(r.size)
+	for n < len(p) && r.off < total {
+		var src []byte
+		if r.off < cryptHeaderSize {
+			src = r.header[r.off:]
+		} else {
+			i := (r.off - cryptHeaderSize) / cryptSealedChunk
+			if i != r.chunk {
+				if err = r.seal(i); err != nil {
+					return
+				}
+			}
+			src = r.buf[r.off-cryptHeaderSize-i*cryptSealedChunk:]
+		}
+		c := copy(p[n:], src)
+		n += c
+		r.off += int64(c)
+	}
+	if n == 0 && r.off >= total {
+		return 0, io.EOF
+	}
+	return
+}
+
+func (r *encryptReader) seal(i int64) error {
+	start := i * cryptChunkSize
+	if r.plain == nil {
+		r.plain = make([]byte, cryptChunkSize)
+	}
+	plain := r.plain[:min(cryptChunkSize, r.size-start)]
+	if _, err := r.src.Seek(r.base+start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r.src, plain); err != nil {
+		return fmt.Errorf("read chunk %d error %w", i, err)
+	}
+	last := start+int64(len(plain)) >= r.size
+	r.buf = r.aead.Seal(r.buf[:0], chunkNonce(r.header, i, last), plain, r.header)
+	r.chunk = i
+	return nil
+}
+
+func (r *encryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += cryptSize(r.size)
+	default:
+		return 0, errors.New("encrypt seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("encrypt seek: negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// decryptWriter writes the content of an encrypted file to w, files without
+// the header are refused since nothing authenticates them. Close must be
+// called after the last write to check the final chunk.
+type decryptWriter struct {
+	w      io.Writer
+	m      *cryptMaster
+	header []byte
+	aead   cipher.AEAD
+	buf    []byte
+	chunk  int64
+}
+
+func (d *decryptWriter) Write(p []byte) (n int, err error) {
+	n = len(p)
+	if d.aead == nil {
+		take := min(cryptHeaderSize-len(d.header), len(p))
+		d.header = append(d.header, p[:take]...)
+		p = p[take:]
+		if len(d.header) < cryptHeaderSize {
+			return
+		}
+		if !bytes.HasPrefix(d.header, []byte(cryptMagic)) {
+			return 0, errCryptPlain
+		}
+		dataKey, err := d.m.openHeader(d.header)
+		if err != nil {
+			return 0, err
+		}
+		if d.aead, err = chacha20poly1305.New(dataKey); err != nil {
+			return 0, err
+		}
+	}
+	d.buf = append(d.buf, p...)
+	// the last chunk is only known at Close
+	for len(d.buf) > cryptSealedChunk {
+		if err = d.open(d.buf[:cryptSealedChunk], false); err != nil {
+			return 0, err
+		}
+		d.buf = append(d.buf[:0], d.buf[cryptSealedChunk:]...)
+	}
+	return
+}
+
+func (d *decryptWriter) open(sealed []byte, last bool) error {
+	plain, err := d.aead.Open(sealed[:0], chunkNonce(d.header, d.chunk, last), sealed, d.header)
+	if err != nil {
+		return fmt.Errorf("chunk %d: %w", d.chunk, errCryptCorrupt)
+	}
+	d.chunk++
+	_, err = d.w.Write(plain)
+	return err
+}
+
+func (d *decryptWriter) Close() error {
+	if d.aead == nil {
+		if bytes.HasPrefix(d.header, []byte(cryptMagic)) {
+			return errCryptCorrupt
+		}
+		// shorter than a header, so never encrypted
+		return errCryptPlain
+	}
+	return d.open(d.buf, true)
+}
+
+// cryptProvider encrypts uploads before they reach the wrapped provider and
+// decrypts downloads, file names are kept
+type cryptProvider struct {
+	provider
+	m *cryptMaster
+	// plainSizes reads the header of every listed file to show the size
+	// of its content
+	plainSizes bool
+}
+
+// Unwrap returns the wrapped provider
+func (c *cryptProvider) Unwrap() provider {
+	return c.provider
+}
+
+func (c *cryptProvider) Upload(ctx context.Context, rs io.ReadSeeker, key, contentType string) (err error) {
+	r, err := c.m.encryptReader(rs)
+	if err != nil {
+		return fmt.Errorf("encrypt %s error %w", key, err)
+	}
+	return c.provider.Upload(ctx, r, key, contentType)
+}
+
+func (c *cryptProvider) Download(ctx context.Context, w io.Writer, key string) (err error) {
+	d := &decryptWriter{w: w, m: c.m}
+	if err = c.provider.Download(ctx, d, key); err != nil {
+		return
+	}
+	if err = d.Close(); err != nil {
+		err = fmt.Errorf("decrypt %s error %w", key, err)
+	}
+	return
+}
+
+func (c *cryptProvider) List(ctx context.Context, prefix, marker string) (data []File, nextMarker string, err error) {
+	data, nextMarker, err = c.provider.List(ctx, prefix, marker)
+	if err == nil && c.plainSizes {
+		c.fixSizes(ctx, prefix, data)
+	}
+	return
+}
+
+// Search runs the server-side search of the wrapped provider, sizes are
+// fixed up like in List
+func (c *cryptProvider) Search(ctx context.Context, prefix, pattern string, fn func(data []File) error) (err error) {
+	s, ok := c.provider.(searcher)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return s.Search(ctx, prefix, pattern, func(data []File) error {
+		if c.plainSizes {
+			c.fixSizes(ctx, prefix, data)
+		}
+		return fn(data)
+	})
+}
+
+// Walk runs the flat listing of the wrapped provider, sizes are fixed up
+// like in List
+func (c *cryptProvider) Walk(ctx context.Context, prefix string, fn func(data []File) error) (err error) {
+	w, ok := c.provider.(prefixWalker)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return w.Walk(ctx, prefix, func(data []File) error {
+		if c.plainSizes {
+			c.fixSizes(ctx, prefix, data)
+		}
+		return fn(data)
+	})
+}
+
+// fixSizes replaces the size of every encrypted file below prefix with the
+// size of its content
+func (c *cryptProvider) fixSizes(ctx context.Context, prefix string, data []File) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 8)
+	for i := range data {
+		f := &data[i]
+		if f.IsDir() || f.Size < cryptSize(0) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if c.encrypted(ctx, prefix+f.Name) {
+				f.Size = plainSize(f.Size)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *cryptProvider) Stat(ctx context.Context, key string) (f File, err error) {
+	f, err = c.provider.Stat(ctx, key)
+	if err == nil && c.plainSizes && !f.IsDir() && f.Size >= cryptSize(0) && c.encrypted(ctx, key) {
+		f.Size = plainSize(f.Size)
+	}
+	return
+}
+
+// encrypted reports whether key starts with the header magic
+func (c *cryptProvider) encrypted(ctx context.Context, key string) bool {
+	magic, err := readRange(ctx, c.provider, key, 0, int64(len(cryptMagic)))
+	return err == nil && string(magic) == cryptMagic
+}
+
+// unwrap returns the provider behind an encryption wrapper, for the optional
+// interfaces that don't touch file contents
+func unwrap(c provider) provider {
+	if u, ok := c.(interface{ Unwrap() provider }); ok {
+		return u.Unwrap()
+	}
+	return c
+}
+
+// cryptSettings edits the client-side encryption of a connection
+type cryptSettings struct {
+	passphrase *widget.Entry
+	keyFile    *widget.Entry
+	plainSizes *widget.Check
+}
+
+// newCryptSettings creates the widgets, the key file and sizes are bound to
+// the cred.<prefix>_crypt preferences if prefs is set
+func newCryptSettings(prefs fyne.Preferences, prefix string) *cryptSettings {
+	s := &cryptSettings{
+		passphrase: widget.NewPasswordEntry(),
+		keyFile:    widget.NewEntry(),
+		plainSizes: widget.NewCheck("Show content sizes, reads every file header", nil),
+	}
+	if prefs != nil {
+		s.keyFile.Bind(binding.BindPreferenceString("cred."+prefix+"_crypt_key_file", prefs))
+		s.plainSizes.Bind(binding.BindPreferenceBool("cred."+prefix+"_crypt_plain_sizes", prefs))
+	}
+	s.passphrase.SetPlaceHolder("empty for no encryption")
+	s.keyFile.SetPlaceHolder("file with a 32 byte key, raw or base64")
+	return s
+}
+
+func (s *cryptSettings) items() []*widget.FormItem {
+	return []*widget.FormItem{
+		widget.NewFormItem("Passphrase", s.passphrase),
+		widget.NewFormItem("Key file", s.keyFile),
+		widget.NewFormItem("", s.plainSizes),
+	}
+}
+
+func (s *cryptSettings) set(cfg connConfig) {
+	s.passphrase.SetText(cfg.CryptPassphrase)
+	s.keyFile.SetText(cfg.CryptKeyFile)
+	s.plainSizes.SetChecked(cfg.CryptPlainSizes)
+}
+
+func (s *cryptSettings) apply(cfg *connConfig) {
+	cfg.CryptPassphrase = s.passphrase.Text
+	cfg.CryptKeyFile = strings.TrimSpace(s.keyFile.Text)
+	cfg.CryptPlainSizes = s.plainSizes.Checked
+}
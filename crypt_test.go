@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testCryptMaster(t *testing.T) *cryptMaster {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(file, bytes.Repeat([]byte{1}, 32), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := newCryptMaster("", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCryptProvider_RoundTrip(t *testing.T) {
+	c := &cryptProvider{provider: &memProvider{}, m: testCryptMaster(t), plainSizes: true}
+	ctx := context.Background()
+	for _, size := range []int{0, 1, cryptChunkSize - 1, cryptChunkSize, cryptChunkSize + 1, 3 * cryptChunkSize} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		if err := c.Upload(ctx, bytes.NewReader(plain), "f", ""); err != nil {
+			t.Fatal(err)
+		}
+		stored := c.provider.(*memProvider).files["f"]
+		if int64(len(stored)) != cryptSize(int64(size)) {
+			t.Errorf("size %d: stored %d bytes, want %d", size, len(stored), cryptSize(int64(size)))
+		}
+		if got := plainSize(int64(len(stored))); got != int64(size) {
+			t.Errorf("plainSize(%d) = %d, want %d", len(stored), got, size)
+		}
+		// a few random bytes can show up in any ciphertext by chance
+		if bytes.Contains(stored, plain) && size > 16 {
+			t.Errorf("size %d: stored plaintext", size)
+		}
+		var buf bytes.Buffer
+		if err := c.Download(ctx, &buf, "f"); err != nil {
+			t.Fatalf("size %d: Download() error = %v", size, err)
+		}
+		if !bytes.Equal(buf.Bytes(), plain) {
+			t.Errorf("size %d: Download() content differs", size)
+		}
+		data, _, err := c.List(ctx, "", "")
+		if err != nil || len(data) != 1 || data[0].Size != int64(size) {
+			t.Errorf("size %d: List() = %+v, %v", size, data, err)
+		}
+	}
+}
+
+func TestEncryptReader_Seek(t *testing.T) {
+	m := testCryptMaster(t)
+	plain := make([]byte, 2*cryptChunkSize+100)
+	rand.Read(plain)
+	r, err := m.encryptReader(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil || end != int64(len(first)) {
+		t.Errorf("Seek(0, end) = %d, %v, want %d", end, err, len(first))
+	}
+	// a retried request reads the same bytes again
+	mid := int64(cryptHeaderSize + cryptSealedChunk + 10)
+	if _, err = r.Seek(mid, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rest, first[mid:]) {
+		t.Error("read after Seek differs")
+	}
+}
+
+func TestDecryptWriter(t *testing.T) {
+	m := testCryptMaster(t)
+	plain := make([]byte, cryptChunkSize+10)
+	rand.Read(plain)
+	r, err := m.encryptReader(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := io.ReadAll(r)
+
+	other, err := newCryptMaster("passphrase", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		m       *cryptMaster
+		data    []byte
+		want    []byte
+		wantErr error
+	}{
+		{"encrypted", m, sealed, plain, nil},
+		{"plaintext", m, bytes.Repeat([]byte("not encrypted "), 10), nil, errCryptPlain},
+		{"short plaintext", m, []byte("not encrypted"), nil, errCryptPlain},
+		{"empty", m, nil, nil, errCryptPlain},
+		{"truncated at chunk", m, sealed[:cryptHeaderSize+cryptSealedChunk], nil, errCryptCorrupt},
+		{"truncated header", m, sealed[:20], nil, errCryptCorrupt},
+		{"appended", m, append(bytes.Clone(sealed), 0), nil, errCryptCorrupt},
+		{"wrong key", other, sealed, nil, errCryptKey},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		d := &decryptWriter{w: &buf, m: tt.m}
+		_, err := d.Write(tt.data)
+		if err == nil {
+			err = d.Close()
+		}
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr == errCryptPlain && buf.Len() > 0 {
+			t.Errorf("%s: wrote %d plaintext bytes", tt.name, buf.Len())
+		}
+		if tt.wantErr == nil && !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("%s: content differs", tt.name)
+		}
+	}
+}
+
+func TestCryptProvider_Plaintext(t *testing.T) {
+	mem := &memProvider{}
+	c := &cryptProvider{provider: mem, m: testCryptMaster(t)}
+	ctx := context.Background()
+	// swapped in by someone without the key
+	if err := mem.Upload(ctx, bytes.NewReader([]byte("#!/bin/sh\nrm -rf ~\n")), "run.sh", ""); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.Download(ctx, &buf, "run.sh"); !errors.Is(err, errCryptPlain) || buf.Len() > 0 {
+		t.Errorf("Download() = %q, %v, want errCryptPlain", buf.String(), err)
+	}
+}
+
+// memSearcher adds an S3 like prefix search to memProvider
+type memSearcher struct {
+	*memProvider
+}
+
+func (m memSearcher) Search(ctx context.Context, prefix, pattern string, fn func(data []File) error) (err error) {
+	data, _, err := m.List(ctx, prefix, "")
+	if err != nil {
+		return
+	}
+	found := []File{}
+	for _, f := range data {
+		if strings.HasPrefix(f.Name, pattern) {
+			found = append(found, f)
+		}
+	}
+	return fn(found)
+}
+
+func TestCryptProvider_Search(t *testing.T) {
+	c := &cryptProvider{provider: memSearcher{&memProvider{}}, m: testCryptMaster(t), plainSizes: true}
+	ctx := context.Background()
+	for _, key := range []string{"d/report.csv", "d/notes.txt"} {
+		if err := c.Upload(ctx, bytes.NewReader([]byte("hello")), key, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := unwrap(c).(searcher); !ok {
+		t.Fatal("unwrap() lost the searcher")
+	}
+	var got []File
+	err := c.Search(ctx, "d/", "rep", func(data []File) error {
+		got = append(got, data...)
+		return nil
+	})
+	if err != nil || len(got) != 1 || got[0].Name != "report.csv" || got[0].Size != 5 {
+		t.Errorf("Search() = %+v, %v", got, err)
+	}
+
+	plain := &cryptProvider{provider: &memProvider{}, m: c.m}
+	if err = plain.Search(ctx, "", "x", nil); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Search() without a searcher error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestCryptMaster_Passphrase(t *testing.T) {
+	m, err := newCryptMaster("secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &cryptProvider{provider: &memProvider{}, m: m}
+	ctx := context.Background()
+	if err = c.Upload(ctx, bytes.NewReader([]byte("hello")), "a", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new session derives the key again from the stored salt
+	m2, _ := newCryptMaster("secret", "")
+	c2 := &cryptProvider{provider: c.provider, m: m2}
+	var buf bytes.Buffer
+	if err = c2.Download(ctx, &buf, "a"); err != nil || buf.String() != "hello" {
+		t.Errorf("Download() = %q, %v, want hello", buf.String(), err)
+	}
+
+	wrong, _ := newCryptMaster("wrong", "")
+	c3 := &cryptProvider{provider: c.provider, m: wrong}
+	if err = c3.Download(ctx, io.Discard, "a"); !errors.Is(err, errCryptKey) {
+		t.Errorf("Download() wrong passphrase error = %v, want errCryptKey", err)
+	}
+	if _, err = newCryptMaster("secret", "key"); err == nil {
+		t.Error("newCryptMaster() with passphrase and key file error = nil")
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	editMaxBytes = 4 * 1024 * 1024
+)
+
+var errRemoteChanged = errors.New("file changed remotely")
+
+// remoteChanged compares two Stat results of the same file, using the ETag
+// when both sides have one and size/mtime otherwise
+func remoteChanged(before, after File) bool {
+	if before.ETag != "" && after.ETag != "" {
+		return before.ETag != after.ETag
+	}
+	return before.Size != after.Size || !before.Time.Equal(after.Time)
+}
+
+// backupKey returns the key used to keep the previous version of key
+func backupKey(key string, now time.Time) string {
+	return key + "." + now.Format("20060102-150405") + ".bak"
+}
+
+// saveEdit uploads content to key. Unless force is set it fails with
+// errRemoteChanged if key no longer matches orig. If backup is set the
+// current remote version is copied aside first.
+func saveEdit(ctx context.Context, c provider, key string, orig File, content []byte, backup, force bool) (File, error) {
+	cur, err := c.Stat(ctx, key)
+	if err != nil {
+		return File{}, fmt.Errorf("stat %s error %w", key, err)
+	}
+	if !force && remoteChanged(orig, cur) {
+		return cur, errRemoteChanged
+	}
+
+	if backup {
+		var old bytes.Buffer
+		if err = c.Download(ctx, &old, key); err != nil {
+			return File{}, fmt.Errorf("backup %s error %w", key, err)
+		}
+		bak := backupKey(key, time.Now())
+		if err = c.Upload(ctx, bytes.NewReader(old.Bytes()), bak, cur.ContentType); err != nil {
+			return File{}, fmt.Errorf("backup %s error %w", bak, err)
+		}
+		slog.Info("backup success",
+			slog.String("key", key),
+			slog.String("backup", bak),
+		)
+	}
+
+	if err = c.Upload(ctx, bytes.NewReader(content), key, cur.ContentType); err != nil {
+		return File{}, fmt.Errorf("save %s error %w", key, err)
+	}
+
+	return c.Stat(ctx, key)
+}
+
+// editFile downloads the selected file and opens it in an editor window
+func (sc *Fone) editFile(f File) {
+	if f.Name == "" || f.IsDir() {
+		sc.infoLabel.SetText("Warn: No file chosen to edit!")
+		return
+	}
+	if f.Size > editMaxBytes {
+		showLabelMsg(sc.infoLabel, "Warn: file too large to edit ("+bytefmt.ByteSize(uint64(f.Size))+")")
+		return
+	}
+
+	key := sc.pathLabel.Text + f.Name
+	go func() {
+		ctx := context.Background()
+		orig, err := sc.client.Stat(ctx, key)
+		if err != nil {
+			showLabelMsg(sc.infoLabel, err.Error())
+			return
+		}
+		var buf bytes.Buffer
+		if err = sc.client.Download(ctx, &buf, key); err != nil {
+			slog.Warn("edit download failed",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+			showLabelMsg(sc.infoLabel, err.Error())
+			return
+		}
+		if isBinary(buf.Bytes()) {
+			sc.infoLabel.SetText("Warn: binary file can not be edited!")
+			return
+		}
+		sc.showEditor(key, orig, buf.String())
+	}()
+}
+
+func (sc *Fone) showEditor(key string, orig File, text string) {
+	w := sc.a.NewWindow("Edit " + path.Base(key))
+
+	entry := widget.NewMultiLineEntry()
+	entry.TextStyle = fyne.TextStyle{Monospace: true}
+	entry.Wrapping = fyne.TextWrapOff
+	entry.SetText(text)
+
+	status := widget.NewLabel(key)
+	status.Truncation = fyne.TextTruncateEllipsis
+	backup := widget.NewCheck("Backup", nil)
+
+	var btnSave *widget.Button
+	var save func(force bool)
+	save = func(force bool) {
+		btnSave.Disable()
+		go func() {
+			defer btnSave.Enable()
+			cur, err := saveEdit(context.Background(), sc.client, key, orig, []byte(entry.Text), backup.Checked, force)
+			if errors.Is(err, errRemoteChanged) {
+				msg := fmt.Sprintf("%s was modified remotely (%s %s) since it was opened.\nOverwrite it?",
+					path.Base(key), cur.Time.Format("2006-01-02 15:04:05"), bytefmt.ByteSize(uint64(cur.Size)))
+				dialog.NewConfirm("Changed remotely", msg, func(ok bool) {
+					if ok {
+						save(true)
+					}
+				}, w).Show()
+				return
+			}
+			if err != nil {
+				slog.Warn("save failed",
+					slog.String("key", key),
+					slog.String("error", err.Error()),
+				)
+				dialog.NewError(err, w).Show()
+				return
+			}
+			orig = cur
+			status.SetText("Saved " + time.Now().Format("15:04:05"))
+			slog.Info("save success",
+				slog.String("key", key),
+			)
+		}()
+	}
+	btnSave = widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), func() {
+		save(false)
+	})
+
+	w.SetContent(container.NewBorder(
+		nil,
+		container.NewBorder(nil, nil, nil, container.NewHBox(backup, btnSave), status),
+		nil,
+		nil,
+		entry,
+	))
+	w.Resize(fyne.NewSize(800, 600))
+	w.Show()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoteChanged(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		before File
+		after  File
+		want   bool
+	}{
+		{
+			name:   "same etag",
+			before: File{ETag: `"abc"`, Size: 1, Time: now},
+			after:  File{ETag: `"abc"`, Size: 1, Time: now.Add(time.Second)},
+			want:   false,
+		},
+		{
+			name:   "different etag",
+			before: File{ETag: `"abc"`, Size: 1, Time: now},
+			after:  File{ETag: `"def"`, Size: 1, Time: now},
+			want:   true,
+		},
+		{
+			name:   "same size and time",
+			before: File{Size: 10, Time: now},
+			after:  File{Size: 10, Time: now},
+			want:   false,
+		},
+		{
+			name:   "size changed",
+			before: File{Size: 10, Time: now},
+			after:  File{Size: 11, Time: now},
+			want:   true,
+		},
+		{
+			name:   "mtime changed",
+			before: File{Size: 10, Time: now},
+			after:  File{Size: 10, Time: now.Add(time.Minute)},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remoteChanged(tt.before, tt.after); got != tt.want {
+				t.Errorf("remoteChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveEdit(t *testing.T) {
+	ctx := context.Background()
+	m := &memProvider{files: map[string][]byte{"a.conf": []byte("old")}}
+	orig, _ := m.Stat(ctx, "a.conf")
+
+	// someone else changed the file
+	m.files["a.conf"] = []byte("other")
+	if _, err := saveEdit(ctx, m, "a.conf", orig, []byte("new"), false, false); !errors.Is(err, errRemoteChanged) {
+		t.Fatalf("saveEdit() error = %v, want errRemoteChanged", err)
+	}
+	if string(m.files["a.conf"]) != "other" {
+		t.Errorf("saveEdit() overwrote a changed file")
+	}
+
+	if _, err := saveEdit(ctx, m, "a.conf", orig, []byte("new"), true, true); err != nil {
+		t.Fatalf("saveEdit(force) error = %v", err)
+	}
+	if string(m.files["a.conf"]) != "new" {
+		t.Errorf("saveEdit() content = %q, want %q", m.files["a.conf"], "new")
+	}
+	backups := 0
+	for k, v := range m.files {
+		if strings.HasPrefix(k, "a.conf.") && strings.HasSuffix(k, ".bak") {
+			backups++
+			if string(v) != "other" {
+				t.Errorf("backup content = %q, want %q", v, "other")
+			}
+		}
+	}
+	if backups != 1 {
+		t.Errorf("backups = %v, want 1", backups)
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
 	"log/slog"
+	"os"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -24,7 +27,20 @@ type File struct {
 	Type        int
 	Size        int64
 	ContentType string
+	ETag        string
 	Time        time.Time
+	// StorageClass is only set by providers with storage tiers
+	StorageClass string
+	// Mode, Uid and Gid are only set by providers with unix permissions
+	Mode os.FileMode
+	Uid  int
+	Gid  int
+	// Link is the target of a symlink, Type is the type of the target so
+	// links to directories can be browsed
+	Link string
+	// LinkMode is the mode of the symlink itself, Size, Time and Mode are
+	// those of a file target
+	LinkMode os.FileMode
 }
 
 func (f *File) String() string {
@@ -36,33 +52,183 @@ func (f *File) String() string {
 }
 
 func (f *File) Info() string {
-	return fmt.Sprintf("%s %8s",
+	info := fmt.Sprintf("%s %8s",
 		f.Time.Format("2006-01-02 15:04:05"),
 		bytefmt.ByteSize(uint64(f.Size)),
 	)
+	if f.Mode != 0 {
+		info = fmt.Sprintf("%s %s %d:%d", f.Perm(), info, f.Uid, f.Gid)
+	}
+	if f.Link != "" {
+		info += " -> " + f.Link
+	}
+	if f.StorageClass != "" {
+		info += " " + f.StorageClass
+	}
+	return info
 }
 
 func (f *File) IsDir() bool {
 	return f.Type == FileDir
 }
 
+func (f *File) IsLink() bool {
+	return (f.Mode|f.LinkMode)&os.ModeSymlink != 0
+}
+
+// Perm returns the permissions in ls -l form, like lrwxr-xr-x
+func (f *File) Perm() string {
+	if f.Mode == 0 {
+		return ""
+	}
+	return f.Mode.String()
+}
+
+const (
+	SortName = iota
+	SortSize
+	SortTime
+	SortType
+	SortClass
+)
+
+var columnTitles = []string{"Name", "Size", "Modified", "Type", "Class"}
+
+func fileIcon(f File) fyne.Resource {
+	if f.IsDir() {
+		return theme.FolderIcon()
+	}
+	switch strings.ToLower(path.Ext(f.Name)) {
+	case ".mp4":
+		return theme.FileVideoIcon()
+	case ".mp3":
+		return theme.FileAudioIcon()
+	case ".png", ".jpg", ".jpeg":
+		return theme.FileImageIcon()
+	case ".txt":
+		return theme.FileTextIcon()
+	default:
+		return theme.FileIcon()
+	}
+}
+
+// TypeName returns the content type if known, otherwise a name derived from
+// the file extension
+func (f *File) TypeName() string {
+	if f.IsLink() {
+		if f.IsDir() {
+			return "Link to Folder"
+		}
+		return "Link"
+	}
+	if f.IsDir() {
+		return "Folder"
+	}
+	if f.ContentType != "" {
+		return f.ContentType
+	}
+	if ext := strings.TrimPrefix(path.Ext(f.Name), "."); ext != "" {
+		return strings.ToUpper(ext) + " File"
+	}
+	return "File"
+}
+
+// sortFiles sorts data in place by column, keeping directories first
+func sortFiles(data []File, by int, desc bool) {
+	sort.SliceStable(data, func(i, j int) bool {
+		a, b := &data[i], &data[j]
+		if a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		var c int
+		switch by {
+		case SortSize:
+			c = cmp.Compare(a.Size, b.Size)
+		case SortTime:
+			c = a.Time.Compare(b.Time)
+		case SortType:
+			c = strings.Compare(a.TypeName(), b.TypeName())
+		case SortClass:
+			c = strings.Compare(a.StorageClass, b.StorageClass)
+		}
+		if c == 0 {
+			c = strings.Compare(a.Name, b.Name)
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
 type FileList struct {
 	parent string
 	widget.List
-	data []File
+	data     []File
+	all      []File
+	filter   string
+	table    *widget.Table
+	sortBy   int
+	sortDesc bool
+	onSort   func(by int, desc bool)
+	onMenu   func(f File, e *fyne.PointEvent)
+}
+
+// fileLabel is the name of a row, it reports right clicks
+type fileLabel struct {
+	widget.Label
+	onTappedSecondary func(*fyne.PointEvent)
+}
+
+func newFileLabel() *fileLabel {
+	l := &fileLabel{}
+	l.ExtendBaseWidget(l)
+	return l
+}
+
+func (l *fileLabel) TappedSecondary(e *fyne.PointEvent) {
+	if l.onTappedSecondary != nil {
+		l.onTappedSecondary(e)
+	}
+}
+
+// menuFn returns the right click handler of row id
+func (fl *FileList) menuFn(id int) func(*fyne.PointEvent) {
+	return func(e *fyne.PointEvent) {
+		if fl.onMenu != nil && id < len(fl.data) {
+			fl.onMenu(fl.data[id], e)
+		}
+	}
+}
+
+// matchFilter reports whether name matches pattern, as a case-insensitive
+// glob if pattern has wildcards or as a substring otherwise
+func matchFilter(name, pattern string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "/"))
+	pattern = strings.ToLower(pattern)
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, err := path.Match(pattern, name)
+		if err == nil && !ok {
+			// also match the base name of nested search results
+			ok, _ = path.Match(pattern, path.Base(name))
+		}
+		return ok
+	}
+	return strings.Contains(name, pattern)
 }
 
 func NewFileList(vf []File, selectFn func(int, string), unSelectFn func()) *FileList {
 	fl := &FileList{
 		parent: "",
 		data:   vf,
+		all:    vf,
 		List:   widget.List{},
 	}
 	fl.Length = func() int {
 		return len(fl.data)
 	}
 	fl.CreateItem = func() fyne.CanvasObject {
-		return container.NewHBox(widget.NewIcon(nil), widget.NewLabel(""))
+		return container.NewHBox(widget.NewIcon(nil), newFileLabel())
 	}
 
 	fl.UpdateItem = func(id widget.ListItemID, item fyne.CanvasObject) {
@@ -70,24 +236,10 @@ func NewFileList(vf []File, selectFn func(int, string), unSelectFn func()) *File
 			return
 		}
 		f := fl.data[id]
-		if f.IsDir() {
-			item.(*fyne.Container).Objects[0].(*widget.Icon).SetResource(theme.FolderIcon())
-		} else {
-			switch strings.ToLower(path.Ext(f.Name)) {
-			case ".mp4":
-				item.(*fyne.Container).Objects[0].(*widget.Icon).SetResource(theme.FileVideoIcon())
-			case ".mp3":
-				item.(*fyne.Container).Objects[0].(*widget.Icon).SetResource(theme.FileAudioIcon())
-			case ".png", ".jpg", ".jpeg":
-				item.(*fyne.Container).Objects[0].(*widget.Icon).SetResource(theme.FileImageIcon())
-			case ".txt":
-				item.(*fyne.Container).Objects[0].(*widget.Icon).SetResource(theme.FileTextIcon())
-			default:
-				item.(*fyne.Container).Objects[0].(*widget.Icon).SetResource(theme.FileIcon())
-			}
-
-		}
-		item.(*fyne.Container).Objects[1].(*widget.Label).SetText(f.Name)
+		item.(*fyne.Container).Objects[0].(*widget.Icon).SetResource(fileIcon(f))
+		l := item.(*fyne.Container).Objects[1].(*fileLabel)
+		l.SetText(f.Name)
+		l.onTappedSecondary = fl.menuFn(id)
 	}
 
 	fl.OnSelected = func(id widget.ListItemID) {
@@ -106,25 +258,207 @@ func NewFileList(vf []File, selectFn func(int, string), unSelectFn func()) *File
 		}
 	}
 
+	fl.table = widget.NewTableWithHeaders(
+		func() (int, int) {
+			return len(fl.data), len(columnTitles)
+		},
+		func() fyne.CanvasObject {
+			l := newFileLabel()
+			l.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, widget.NewIcon(nil), nil, l)
+		},
+		fl.updateCell,
+	)
+	fl.table.ShowHeaderColumn = false
+	fl.table.CreateHeader = func() fyne.CanvasObject {
+		b := widget.NewButton("", nil)
+		b.Alignment = widget.ButtonAlignLeading
+		b.Importance = widget.LowImportance
+		return b
+	}
+	fl.table.UpdateHeader = func(id widget.TableCellID, o fyne.CanvasObject) {
+		if id.Col < 0 || id.Col >= len(columnTitles) {
+			return
+		}
+		b := o.(*widget.Button)
+		title := columnTitles[id.Col]
+		if id.Col == fl.sortBy {
+			if fl.sortDesc {
+				title += " ▼"
+			} else {
+				title += " ▲"
+			}
+		}
+		b.SetText(title)
+		b.OnTapped = func() {
+			desc := false
+			if id.Col == fl.sortBy {
+				desc = !fl.sortDesc
+			}
+			fl.SetSort(id.Col, desc)
+			if fl.onSort != nil {
+				fl.onSort(fl.sortBy, fl.sortDesc)
+			}
+		}
+	}
+	for i, w := range []float32{320, 90, 170, 160, 110} {
+		fl.table.SetColumnWidth(i, w)
+	}
+	fl.table.OnSelected = func(id widget.TableCellID) {
+		if id.Row < 0 || id.Row >= len(fl.data) {
+			return
+		}
+		if selectFn != nil {
+			selectFn(id.Row, fl.parent)
+		}
+	}
+	fl.table.OnUnselected = func(id widget.TableCellID) {
+		if unSelectFn != nil {
+			unSelectFn()
+		}
+	}
+
 	fl.ExtendBaseWidget(fl)
 	return fl
 }
 
+func (fl *FileList) updateCell(id widget.TableCellID, o fyne.CanvasObject) {
+	if id.Row < 0 || id.Row >= len(fl.data) {
+		return
+	}
+	f := fl.data[id.Row]
+	c := o.(*fyne.Container)
+	l := c.Objects[0].(*fileLabel)
+	l.onTappedSecondary = fl.menuFn(id.Row)
+	icon := c.Objects[1].(*widget.Icon)
+	icon.Hide()
+	l.Alignment = fyne.TextAlignLeading
+	switch id.Col {
+	case SortName:
+		icon.SetResource(fileIcon(f))
+		icon.Show()
+		l.SetText(f.Name)
+	case SortSize:
+		l.Alignment = fyne.TextAlignTrailing
+		if f.IsDir() {
+			l.SetText("")
+		} else {
+			l.SetText(bytefmt.ByteSize(uint64(f.Size)))
+		}
+	case SortTime:
+		if f.Time.IsZero() {
+			l.SetText("")
+		} else {
+			l.SetText(f.Time.Local().Format("2006-01-02 15:04:05"))
+		}
+	case SortType:
+		l.SetText(f.TypeName())
+	case SortClass:
+		l.SetText(f.StorageClass)
+	}
+}
+
+// Table returns the detail view of the list, sharing the same data
+func (fl *FileList) Table() *widget.Table {
+	return fl.table
+}
+
+// SetSort sorts the data by column, onSort is called when the user changes
+// the sort order from a column header
+func (fl *FileList) SetSort(by int, desc bool) {
+	if by < SortName || by > SortClass {
+		by = SortName
+	}
+	fl.sortBy = by
+	fl.sortDesc = desc
+	fl.applyFilter()
+	fl.Refresh()
+	fl.UnselectAll()
+}
+
+// SetFilter shows only the loaded files matching pattern, an empty pattern
+// shows all of them
+func (fl *FileList) SetFilter(pattern string) {
+	fl.filter = pattern
+	fl.applyFilter()
+	fl.Refresh()
+	fl.UnselectAll()
+}
+
+// applyFilter sorts all files and rebuilds the visible data from them
+func (fl *FileList) applyFilter() {
+	sortFiles(fl.all, fl.sortBy, fl.sortDesc)
+	if fl.filter == "" {
+		fl.data = fl.all
+		return
+	}
+	fl.data = make([]File, 0, len(fl.all))
+	for _, f := range fl.all {
+		if matchFilter(f.Name, fl.filter) {
+			fl.data = append(fl.data, f)
+		}
+	}
+}
+
+func (fl *FileList) OnSort(fn func(by int, desc bool)) {
+	fl.onSort = fn
+}
+
+// OnMenu sets the handler of right clicks on a row
+func (fl *FileList) OnMenu(fn func(f File, e *fyne.PointEvent)) {
+	fl.onMenu = fn
+}
+
+func (fl *FileList) Refresh() {
+	fl.List.Refresh()
+	if fl.table != nil {
+		fl.table.Refresh()
+	}
+}
+
+func (fl *FileList) UnselectAll() {
+	fl.List.UnselectAll()
+	if fl.table != nil {
+		fl.table.UnselectAll()
+	}
+}
+
 func (fl *FileList) Add(f File) error {
-	fl.data = append(fl.data, f)
+	fl.all = append(fl.all, f)
+	fl.applyFilter()
 	fl.Refresh()
 	return nil
 }
 
+// Set replaces the loaded file with the same name as f
+func (fl *FileList) Set(f File) {
+	for i := range fl.all {
+		if fl.all[i].Name == f.Name {
+			fl.all[i] = f
+			break
+		}
+	}
+	fl.applyFilter()
+	fl.Refresh()
+}
+
 func (fl *FileList) Clear() {
 	fl.parent = ""
 	fl.data = nil
+	fl.all = nil
 	fl.Refresh()
 }
 
 func (fl *FileList) Delete(id int) {
 	if id >= 0 && id < len(fl.data) && len(fl.data) > 0 {
-		fl.data = append(fl.data[0:id], fl.data[id+1:]...)
+		name := fl.data[id].Name
+		for i := range fl.all {
+			if fl.all[i].Name == name {
+				fl.all = append(fl.all[0:i], fl.all[i+1:]...)
+				break
+			}
+		}
+		fl.applyFilter()
 	}
 	fl.Refresh()
 	fl.UnselectAll()
@@ -132,14 +466,16 @@ func (fl *FileList) Delete(id int) {
 
 func (fl *FileList) Update(parent string, vv []File) error {
 	fl.parent = parent
-	fl.data = vv
+	fl.all = vv
+	fl.applyFilter()
 	fl.Refresh()
 	fl.UnselectAll()
 	return nil
 }
 
 func (fl *FileList) Append(vv []File) error {
-	fl.data = append(fl.data, vv...)
+	fl.all = append(fl.all, vv...)
+	fl.applyFilter()
 	fl.Refresh()
 	fl.UnselectAll()
 	return nil
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"testing"
 	"time"
 )
@@ -69,6 +70,20 @@ func TestFile_Info(t *testing.T) {
 	if len(got) == 0 {
 		t.Errorf("File.Info() returned empty string")
 	}
+
+	link := &File{
+		Name: "current/",
+		Type: FileDir,
+		Time: now,
+		Mode: os.ModeSymlink | 0o777,
+		Uid:  1000,
+		Gid:  100,
+		Link: "releases/v2",
+	}
+	got = link.Info()
+	if !contains(got, "Lrwxrwxrwx") || !contains(got, "1000:100") || !contains(got, "-> releases/v2") {
+		t.Errorf("File.Info() = %q", got)
+	}
 }
 
 func contains(s, substr string) bool {
@@ -412,3 +427,114 @@ func (w *testWrapper) Error() string {
 func (w *testWrapper) Unwrap() error {
 	return w.err
 }
+
+func TestSortFiles(t *testing.T) {
+	t1 := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	files := []File{
+		{Name: "b.txt", Size: 300, Time: t1},
+		{Name: "zdir/", Type: FileDir},
+		{Name: "a.json", Size: 100, Time: t2, ContentType: "application/json"},
+		{Name: "adir/", Type: FileDir},
+		{Name: "c.txt", Size: 200, Time: t1},
+	}
+	tests := []struct {
+		name string
+		by   int
+		desc bool
+		want []string
+	}{
+		{name: "name", by: SortName, want: []string{"adir/", "zdir/", "a.json", "b.txt", "c.txt"}},
+		{name: "name desc", by: SortName, desc: true, want: []string{"zdir/", "adir/", "c.txt", "b.txt", "a.json"}},
+		{name: "size", by: SortSize, want: []string{"adir/", "zdir/", "a.json", "c.txt", "b.txt"}},
+		{name: "time desc", by: SortTime, desc: true, want: []string{"zdir/", "adir/", "a.json", "c.txt", "b.txt"}},
+		{name: "type", by: SortType, want: []string{"adir/", "zdir/", "b.txt", "c.txt", "a.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]File(nil), files...)
+			sortFiles(data, tt.by, tt.desc)
+			for i, f := range data {
+				if f.Name != tt.want[i] {
+					t.Errorf("sortFiles() [%d] = %v, want %v", i, f.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFile_TypeName(t *testing.T) {
+	tests := []struct {
+		file File
+		want string
+	}{
+		{file: File{Name: "dir/", Type: FileDir}, want: "Folder"},
+		{file: File{Name: "a.json", ContentType: "application/json"}, want: "application/json"},
+		{file: File{Name: "a.txt"}, want: "TXT File"},
+		{file: File{Name: "Makefile"}, want: "File"},
+		{file: File{Name: "current/", Type: FileDir, Mode: os.ModeSymlink}, want: "Link to Folder"},
+		{file: File{Name: "latest.log", Mode: os.ModeSymlink}, want: "Link"},
+	}
+	for _, tt := range tests {
+		if got := tt.file.TypeName(); got != tt.want {
+			t.Errorf("File.TypeName(%v) = %v, want %v", tt.file.Name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    bool
+	}{
+		{name: "Report-2024.csv", pattern: "report", want: true},
+		{name: "Report-2024.csv", pattern: "2023", want: false},
+		{name: "Report-2024.csv", pattern: "*.CSV", want: true},
+		{name: "Report-2024.csv", pattern: "*.txt", want: false},
+		{name: "logs/", pattern: "log?", want: true},
+		{name: "a/b/app.log", pattern: "*.log", want: true},
+		{name: "a/b/app.log", pattern: "b/app", want: true},
+	}
+	for _, tt := range tests {
+		if got := matchFilter(tt.name, tt.pattern); got != tt.want {
+			t.Errorf("matchFilter(%v, %v) = %v, want %v", tt.name, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestFileList_Filter(t *testing.T) {
+	files := []File{
+		{Name: "a.txt", Type: FileRegular},
+		{Name: "b.csv", Type: FileRegular},
+		{Name: "c.txt", Type: FileRegular},
+	}
+	fl := NewFileList(files, nil, nil)
+
+	// Directly apply the filter without calling Refresh()
+	fl.filter = "*.txt"
+	fl.applyFilter()
+	if fl.Length() != 2 {
+		t.Errorf("FileList filter length = %v, want 2", fl.Length())
+	}
+	if f := fl.SelectFile(1); f.Name != "c.txt" {
+		t.Errorf("FileList filter file at 1 = %v, want c.txt", f.Name)
+	}
+
+	fl.filter = ""
+	fl.applyFilter()
+	if fl.Length() != 3 {
+		t.Errorf("FileList filter length = %v, want 3", fl.Length())
+	}
+}
+
+func TestFileList_Set(t *testing.T) {
+	fl := NewFileList([]File{{Name: "a.sh", Mode: 0o644}, {Name: "b.sh"}}, nil, nil)
+	fl.Set(File{Name: "a.sh", Mode: 0o755})
+	if got := fl.SelectFile(0); got.Mode != 0o755 {
+		t.Errorf("FileList.Set() mode = %v, want 0755", got.Mode)
+	}
+	if len(fl.data) != 2 {
+		t.Errorf("FileList.Set() len = %d, want 2", len(fl.data))
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	formatRclone = "rclone"
+	formatS3cmd  = "s3cmd"
+	formatAWS    = "aws"
+)
+
+// iniSection is a named group of key values, keys are lower case and nested
+// AWS values like "s3 =" followed by indented lines become "s3.key"
+type iniSection struct {
+	name string
+	keys []string
+	vals map[string]string
+}
+
+func (s *iniSection) get(key string) string {
+	return s.vals[key]
+}
+
+// parseINI reads the loose INI dialect shared by rclone, s3cmd and the AWS
+// CLI
+func parseINI(data []byte) []*iniSection {
+	var sections []*iniSection
+	var cur *iniSection
+	parent := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		raw := sc.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			cur = &iniSection{name: strings.TrimSpace(line[1 : len(line)-1]), vals: map[string]string{}}
+			sections = append(sections, cur)
+			parent = ""
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		indented := raw[0] == ' ' || raw[0] == '\t'
+		if indented && parent != "" {
+			key = parent + "." + key
+		} else if val == "" {
+			parent = key
+		} else {
+			parent = ""
+		}
+		if _, ok := cur.vals[key]; !ok {
+			cur.keys = append(cur.keys, key)
+		}
+		cur.vals[key] = val
+	}
+	return sections
+}
+
+// detectConfigFormat guesses which tool wrote a config file
+func detectConfigFormat(name string, data []byte) string {
+	base := strings.ToLower(path.Base(name))
+	switch {
+	case strings.HasPrefix(base, "rclone"):
+		return formatRclone
+	case strings.Contains(base, "s3cfg"):
+		return formatS3cmd
+	case base == "config" || base == "credentials":
+		return formatAWS
+	}
+	for _, s := range parseINI(data) {
+		switch {
+		case s.get("type") != "":
+			return formatRclone
+		case s.get("host_base") != "" || s.get("access_key") != "":
+			return formatS3cmd
+		case s.get("aws_access_key_id") != "" || strings.HasPrefix(s.name, "profile "):
+			return formatAWS
+		}
+	}
+	return ""
+}
+
+// importResult holds the converted profiles and what could not be converted
+type importResult struct {
+	profiles []Profile
+	notes    []string
+}
+
+func (r *importResult) notef(format string, a ...any) {
+	r.notes = append(r.notes, fmt.Sprintf(format, a...))
+}
+
+// unsupported reports the keys of s not in known
+func (r *importResult) unsupported(s *iniSection, known []string) {
+	for _, key := range s.keys {
+		if !slices.Contains(known, key) && s.get(key) != "" {
+			r.notef("%s: option %s not supported", s.name, key)
+		}
+	}
+}
+
+// rcloneCryptKey is the fixed key rclone uses to obscure passwords
+var rcloneCryptKey = []byte{
+	0x9c, 0x93, 0x5b, 0x48, 0x73, 0x0a, 0x55, 0x4d,
+	0x6b, 0xfd, 0x7c, 0x63, 0xc8, 0x86, 0xa9, 0x2b,
+	0xd3, 0x90, 0x19, 0x8e, 0xb8, 0x12, 0x8a, 0xfb,
+	0xf4, 0xde, 0x16, 0x2b, 0x8b, 0x95, 0xf6, 0x38,
+}
+
+// rcloneReveal decodes a password written by "rclone obscure"
+func rcloneReveal(s string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("reveal password error %w", err)
+	}
+	if len(data) < aes.BlockSize {
+		return "", errors.New("reveal password error: input too short")
+	}
+	block, err := aes.NewCipher(rcloneCryptKey)
+	if err != nil {
+		return "", err
+	}
+	buf := data[aes.BlockSize:]
+	cipher.NewCTR(block, data[:aes.BlockSize]).XORKeyStream(buf, buf)
+	return string(buf), nil
+}
+
+// importRclone converts the s3 and sftp remotes of rclone.conf
+func importRclone(data []byte) (r importResult) {
+	for _, s := range parseINI(data) {
+		p := Profile{Name: s.name, Folder: formatRclone}
+		switch typ := s.get("type"); typ {
+		case "s3":
+			p.Type = "s3"
+			p.Endpoint = s.get("endpoint")
+			p.Region = s.get("region")
+			p.AccessKey = s.get("access_key_id")
+			p.SecretKey = s.get("secret_access_key")
+			if p.Endpoint == "" {
+				p.Endpoint = awsEndpoint(p.Region)
+			} else if !strings.Contains(p.Endpoint, "://") {
+				p.Endpoint = "https://" + p.Endpoint
+			}
+			if s.get("env_auth") == "true" {
+				r.notef("%s: env_auth not supported, set the keys manually", s.name)
+			}
+			switch s.get("force_path_style") {
+			case "true":
+				p.Addressing = addressingPath
+			case "false":
+				p.Addressing = addressingVirtual
+			}
+			r.unsupported(s, []string{"type", "provider", "endpoint", "region", "access_key_id", "secret_access_key", "env_auth", "force_path_style"})
+			r.notef("%s: rclone remotes have no bucket, set one before connecting", s.name)
+		case "sftp":
+			p.Type = "sftp"
+			p.Server = s.get("host")
+			if port := s.get("port"); port != "" && port != "22" {
+				p.Server = net.JoinHostPort(p.Server, port)
+			}
+			p.User = s.get("user")
+			p.Auth = authPassword
+			secret := s.get("pass")
+			if keyFile := s.get("key_file"); keyFile != "" {
+				p.Auth = authKey
+				p.KeyFile = keyFile
+				secret = s.get("key_file_pass")
+			}
+			if secret != "" {
+				pass, err := rcloneReveal(secret)
+				if err != nil {
+					r.notef("%s: %v", s.name, err)
+				}
+				p.Password = pass
+			}
+			r.unsupported(s, []string{"type", "host", "port", "user", "pass", "key_file", "key_file_pass", "shell_type", "md5sum_command", "sha1sum_command"})
+		default:
+			r.notef("%s: remote type %q not supported", s.name, typ)
+			continue
+		}
+		r.profiles = append(r.profiles, p)
+	}
+	return
+}
+
+// importS3cmd converts the sections of ~/.s3cfg
+func importS3cmd(data []byte) (r importResult) {
+	for _, s := range parseINI(data) {
+		p := Profile{Name: formatS3cmd + "-" + s.name, Folder: formatS3cmd}
+		p.Type = "s3"
+		p.AccessKey = s.get("access_key")
+		p.SecretKey = s.get("secret_key")
+		p.Region = s.get("bucket_location")
+		if p.Region == "US" {
+			p.Region = "us-east-1"
+		}
+		scheme := "https://"
+		if strings.EqualFold(s.get("use_https"), "false") {
+			scheme = "http://"
+		}
+		if host := s.get("host_base"); host != "" {
+			p.Endpoint = scheme + host
+		} else {
+			p.Endpoint = awsEndpoint(p.Region)
+		}
+		p.Addressing = addressingPath
+		if strings.Contains(s.get("host_bucket"), "%(bucket)s") {
+			p.Addressing = addressingVirtual
+		}
+		p.CAFile = s.get("ca_certs_file")
+		p.SkipVerify = strings.EqualFold(s.get("check_ssl_certificate"), "false")
+		if host := s.get("proxy_host"); host != "" {
+			if !strings.Contains(host, "://") {
+				host = "http://" + host
+			}
+			if port := s.get("proxy_port"); port != "" && port != "0" {
+				host += ":" + port
+			}
+			p.Proxy = host
+		}
+		for _, key := range []string{"access_token"} {
+			if s.get(key) != "" {
+				r.notef("%s: option %s not supported", s.name, key)
+			}
+		}
+		for _, key := range []string{"encrypt", "signature_v2"} {
+			if strings.EqualFold(s.get(key), "true") {
+				r.notef("%s: option %s not supported", s.name, key)
+			}
+		}
+		r.profiles = append(r.profiles, p)
+	}
+	return
+}
+
+// awsKnownKeys are the AWS config and credentials keys a profile takes,
+// output only formats the AWS CLI and session tokens have their own note
+var awsKnownKeys = []string{"region", "output", "endpoint_url", "s3.endpoint_url", "s3.addressing_style", "ca_bundle",
+	"aws_access_key_id", "aws_secret_access_key", "aws_session_token"}
+
+// importAWS converts the profiles of ~/.aws/config, with the keys taken
+// from the matching sections of ~/.aws/credentials
+func importAWS(config, credentials []byte) (r importResult) {
+	creds := map[string]*iniSection{}
+	for _, s := range parseINI(credentials) {
+		creds[s.name] = s
+	}
+	seen := map[string]bool{}
+	add := func(name string, s *iniSection) {
+		seen[name] = true
+		cred := creds[name]
+		if cred == nil {
+			cred = s
+		}
+		p := Profile{Name: formatAWS + "-" + name, Folder: formatAWS}
+		p.Type = "s3"
+		p.Region = s.get("region")
+		p.Endpoint = s.get("s3.endpoint_url")
+		if p.Endpoint == "" {
+			p.Endpoint = s.get("endpoint_url")
+		}
+		if p.Endpoint == "" {
+			p.Endpoint = awsEndpoint(p.Region)
+		}
+		p.CAFile = s.get("ca_bundle")
+		p.AccessKey = cred.get("aws_access_key_id")
+		p.SecretKey = cred.get("aws_secret_access_key")
+		switch style := s.get("s3.addressing_style"); style {
+		case addressingPath, addressingVirtual:
+			p.Addressing = style
+		}
+		r.unsupported(s, awsKnownKeys)
+		if cred != s {
+			r.unsupported(cred, awsKnownKeys)
+		}
+		if cred.get("aws_session_token") != "" {
+			r.notef("%s: session tokens not supported", name)
+		}
+		r.profiles = append(r.profiles, p)
+	}
+	for _, s := range parseINI(config) {
+		// sso-session, services and other sections are not profiles
+		name, ok := strings.CutPrefix(s.name, "profile ")
+		if !ok && s.name != "default" {
+			continue
+		}
+		add(strings.TrimSpace(name), s)
+	}
+	// profiles defined only in the credentials file
+	for _, s := range parseINI(credentials) {
+		if !seen[s.name] {
+			add(s.name, s)
+		}
+	}
+	return
+}
+
+// awsEndpoint returns the regional AWS S3 endpoint
+func awsEndpoint(region string) string {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return "https://s3." + region + ".amazonaws.com"
+}
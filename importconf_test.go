@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// rcloneObscure is the inverse of rcloneReveal with a fixed IV
+func rcloneObscure(s string) string {
+	block, _ := aes.NewCipher(rcloneCryptKey)
+	data := make([]byte, aes.BlockSize+len(s))
+	copy(data, "0123456789abcdef")
+	cipher.NewCTR(block, data[:aes.BlockSize]).XORKeyStream(data[aes.BlockSize:], []byte(s))
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestParseINI(t *testing.T) {
+	data := `# comment
+[profile dev]
+region = eu-west-1
+s3 =
+    endpoint_url = http://minio:9000
+    addressing_style = path
+output=json
+`
+	sections := parseINI([]byte(data))
+	if len(sections) != 1 || sections[0].name != "profile dev" {
+		t.Fatalf("parseINI() = %+v", sections)
+	}
+	s := sections[0]
+	if s.get("s3.endpoint_url") != "http://minio:9000" || s.get("output") != "json" || s.get("region") != "eu-west-1" {
+		t.Errorf("parseINI() vals = %v", s.vals)
+	}
+}
+
+func TestDetectConfigFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "/home/a/.config/rclone/rclone.conf", want: formatRclone},
+		{name: "/home/a/.s3cfg", want: formatS3cmd},
+		{name: "/home/a/.aws/credentials", want: formatAWS},
+		{name: "remotes.ini", data: "[r]\ntype = s3\n", want: formatRclone},
+		{name: "backup.cfg", data: "[default]\nhost_base = s3.example.com\n", want: formatS3cmd},
+		{name: "aws.ini", data: "[profile x]\nregion = us-east-1\n", want: formatAWS},
+		{name: "other.ini", data: "[x]\nfoo = bar\n", want: ""},
+	}
+	for _, tt := range tests {
+		if got := detectConfigFormat(tt.name, []byte(tt.data)); got != tt.want {
+			t.Errorf("detectConfigFormat(%v) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImportRclone(t *testing.T) {
+	data := `[minio]
+type = s3
+provider = Minio
+access_key_id = ak
+secret_access_key = sk
+endpoint = http://192.168.0.8:9000
+force_path_style = false
+chunk_size = 64M
+
+[web]
+type = sftp
+host = example.com
+port = 2222
+user = root
+pass = ` + rcloneObscure("hunter2") + `
+
+[gdrive]
+type = drive
+`
+	r := importRclone([]byte(data))
+	if len(r.profiles) != 2 {
+		t.Fatalf("importRclone() profiles = %v, want 2", len(r.profiles))
+	}
+	s3p, sftpp := r.profiles[0], r.profiles[1]
+	if s3p.Endpoint != "http://192.168.0.8:9000" || s3p.AccessKey != "ak" || s3p.SecretKey != "sk" || s3p.Addressing != addressingVirtual {
+		t.Errorf("importRclone() s3 = %+v", s3p)
+	}
+	if sftpp.Server != "example.com:2222" || sftpp.User != "root" || sftpp.Password != "hunter2" {
+		t.Errorf("importRclone() sftp = %+v", sftpp)
+	}
+	notes := strings.Join(r.notes, "\n")
+	for _, want := range []string{"chunk_size", `"drive"`} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("importRclone() notes = %v, missing %v", notes, want)
+		}
+	}
+}
+
+func TestImportS3cmd(t *testing.T) {
+	data := `[default]
+access_key = ak
+secret_key = sk
+host_base = minio.local:9000
+host_bucket = minio.local:9000
+bucket_location = US
+use_https = False
+encrypt = True
+proxy_host = squid.local
+proxy_port = 3128
+`
+	r := importS3cmd([]byte(data))
+	if len(r.profiles) != 1 {
+		t.Fatalf("importS3cmd() profiles = %v, want 1", len(r.profiles))
+	}
+	p := r.profiles[0]
+	if p.Name != "s3cmd-default" || p.Endpoint != "http://minio.local:9000" || p.Region != "us-east-1" || p.SecretKey != "sk" || p.Addressing != addressingPath || p.Proxy != "http://squid.local:3128" {
+		t.Errorf("importS3cmd() = %+v", p)
+	}
+	if len(r.notes) != 1 || !strings.Contains(r.notes[0], "encrypt") {
+		t.Errorf("importS3cmd() notes = %v", r.notes)
+	}
+}
+
+func TestImportAWS(t *testing.T) {
+	config := `[default]
+region = eu-central-1
+output = json
+
+[profile minio]
+region = us-east-1
+s3 =
+    endpoint_url = http://192.168.0.8:9000
+    max_concurrent_requests = 20
+
+[profile admin]
+role_arn = arn:aws:iam::123:role/admin
+source_profile = default
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+
+[services local]
+s3 =
+    endpoint_url = http://localhost:9000
+`
+	credentials := `[default]
+aws_access_key_id = AKIA
+aws_secret_access_key = secret
+
+[minio]
+aws_access_key_id = minio
+aws_secret_access_key = minio123
+
+[ci]
+aws_access_key_id = ci
+aws_secret_access_key = ci123
+`
+	r := importAWS([]byte(config), []byte(credentials))
+	if len(r.profiles) != 4 {
+		t.Fatalf("importAWS() profiles = %v, want 4", len(r.profiles))
+	}
+	if p := r.profiles[0]; p.Endpoint != "https://s3.eu-central-1.amazonaws.com" || p.AccessKey != "AKIA" {
+		t.Errorf("importAWS() default = %+v", p)
+	}
+	if p := r.profiles[1]; p.Name != "aws-minio" || p.Endpoint != "http://192.168.0.8:9000" || p.SecretKey != "minio123" {
+		t.Errorf("importAWS() minio = %+v", p)
+	}
+	if p := r.profiles[3]; p.Name != "aws-ci" || p.AccessKey != "ci" {
+		t.Errorf("importAWS() ci = %+v", p)
+	}
+	want := []string{
+		"profile minio: option s3.max_concurrent_requests not supported",
+		"profile admin: option role_arn not supported",
+		"profile admin: option source_profile not supported",
+	}
+	if !slices.Equal(r.notes, want) {
+		t.Errorf("importAWS() notes = %q, want %q", r.notes, want)
+	}
+}
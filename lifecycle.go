@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// lifecycleView edits the lifecycle rules of the current bucket, changes
+// stay local until saved
+type lifecycleView struct {
+	le    lifecycleEditor
+	w     fyne.Window
+	rules []LifecycleRule
+	list  *widget.List
+	// cur is the rule in the form, -1 for none
+	cur int
+
+	id          *widget.Entry
+	enabled     *widget.Check
+	prefix      *widget.Entry
+	tags        *widget.Entry
+	transitions *widget.Entry
+	expiration  *widget.Entry
+	noncurrent  *widget.Entry
+	abort       *widget.Entry
+	form        *widget.Form
+}
+
+func (sc *Fone) showLifecycle(le lifecycleEditor) {
+	go func() {
+		rules, err := le.Lifecycle(context.Background())
+		if err != nil {
+			slog.Warn("get lifecycle failed",
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), sc.w)
+			return
+		}
+		lv := &lifecycleView{le: le, rules: rules, cur: -1}
+		lv.w = sc.a.NewWindow("Lifecycle rules")
+		lv.build()
+		lv.w.Resize(fyne.NewSize(820, 520))
+		lv.w.Show()
+	}()
+}
+
+func (lv *lifecycleView) build() {
+	lv.list = widget.NewList(
+		func() int {
+			return len(lv.rules)
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			r := lv.rules[id]
+			text := r.ID
+			if !r.Enabled {
+				text += " (disabled)"
+			}
+			o.(*widget.Label).SetText(text)
+		},
+	)
+	lv.list.OnSelected = func(id widget.ListItemID) {
+		if id == lv.cur {
+			return
+		}
+		if err := lv.commit(); err != nil {
+			dialog.ShowError(err, lv.w)
+			lv.list.Select(lv.cur)
+			return
+		}
+		lv.load(id)
+	}
+
+	lv.id = widget.NewEntry()
+	lv.enabled = widget.NewCheck("Enabled", nil)
+	lv.prefix = widget.NewEntry()
+	lv.prefix.SetPlaceHolder("empty for the whole bucket")
+	lv.tags = widget.NewMultiLineEntry()
+	lv.tags.SetPlaceHolder("key=value")
+	lv.tags.SetMinRowsVisible(2)
+	lv.tags.Validator = func(s string) error {
+		_, err := parseKeyValues(s)
+		return err
+	}
+	lv.transitions = widget.NewMultiLineEntry()
+	lv.transitions.SetPlaceHolder("30 STANDARD_IA\n90 GLACIER")
+	lv.transitions.SetMinRowsVisible(3)
+	lv.transitions.Validator = func(s string) error {
+		_, err := parseTransitions(s)
+		return err
+	}
+	daysEntry := func() *widget.Entry {
+		e := widget.NewEntry()
+		e.SetPlaceHolder("days, empty for never")
+		e.Validator = func(s string) error {
+			_, err := parseDays(s)
+			return err
+		}
+		return e
+	}
+	lv.expiration = daysEntry()
+	lv.noncurrent = daysEntry()
+	lv.abort = daysEntry()
+	lv.form = widget.NewForm(
+		widget.NewFormItem("ID", lv.id),
+		widget.NewFormItem("", lv.enabled),
+		widget.NewFormItem("Prefix", lv.prefix),
+		widget.NewFormItem("Tags", lv.tags),
+		widget.NewFormItem("Transitions", lv.transitions),
+		widget.NewFormItem("Expire after", lv.expiration),
+		widget.NewFormItem("Expire noncurrent after", lv.noncurrent),
+		widget.NewFormItem("Abort uploads after", lv.abort),
+	)
+	lv.form.Hide()
+
+	toolbar := widget.NewToolbar(
+		widget.NewToolbarAction(theme.ContentAddIcon(), lv.add),
+		widget.NewToolbarAction(theme.DeleteIcon(), lv.remove),
+	)
+	save := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), lv.save)
+	save.Importance = widget.HighImportance
+	split := container.NewHSplit(
+		container.NewBorder(toolbar, nil, nil, nil, lv.list),
+		container.NewVScroll(lv.form),
+	)
+	split.SetOffset(0.3)
+	lv.w.SetContent(container.NewBorder(nil, container.NewHBox(layout.NewSpacer(), save), nil, nil, split))
+}
+
+// load shows rule i in the form
+func (lv *lifecycleView) load(i int) {
+	lv.cur = i
+	if i < 0 || i >= len(lv.rules) {
+		lv.cur = -1
+		lv.form.Hide()
+		return
+	}
+	r := lv.rules[i]
+	lv.id.SetText(r.ID)
+	lv.enabled.SetChecked(r.Enabled)
+	lv.prefix.SetText(r.Prefix)
+	lv.tags.SetText(formatKeyValues(r.Tags))
+	lv.transitions.SetText(formatTransitions(r.Transitions))
+	for _, d := range []struct {
+		e    *widget.Entry
+		days int
+	}{
+		{lv.expiration, r.ExpirationDays},
+		{lv.noncurrent, r.NoncurrentExpirationDays},
+		{lv.abort, r.AbortMultipartDays},
+	} {
+		d.e.SetText("")
+		if d.days > 0 {
+			d.e.SetText(strconv.Itoa(d.days))
+		}
+	}
+	lv.form.Show()
+}
+
+// commit stores the form into the current rule
+func (lv *lifecycleView) commit() (err error) {
+	if lv.cur < 0 {
+		return nil
+	}
+	r := &lv.rules[lv.cur]
+	tags, err := parseKeyValues(lv.tags.Text)
+	if err != nil {
+		return fmt.Errorf("tags %w", err)
+	}
+	transitions, err := parseTransitions(lv.transitions.Text)
+	if err != nil {
+		return fmt.Errorf("transitions %w", err)
+	}
+	days := map[*widget.Entry]int{}
+	for _, e := range []*widget.Entry{lv.expiration, lv.noncurrent, lv.abort} {
+		if days[e], err = parseDays(e.Text); err != nil {
+			return
+		}
+	}
+	r.ID = lv.id.Text
+	r.Enabled = lv.enabled.Checked
+	r.Prefix = lv.prefix.Text
+	r.Tags = tags
+	r.Transitions = transitions
+	r.ExpirationDays = days[lv.expiration]
+	r.NoncurrentExpirationDays = days[lv.noncurrent]
+	r.AbortMultipartDays = days[lv.abort]
+	lv.list.RefreshItem(lv.cur)
+	return nil
+}
+
+func (lv *lifecycleView) add() {
+	if err := lv.commit(); err != nil {
+		dialog.ShowError(err, lv.w)
+		return
+	}
+	lv.rules = append(lv.rules, LifecycleRule{
+		ID:      fmt.Sprintf("rule-%d", len(lv.rules)+1),
+		Enabled: true,
+		Tags:    map[string]string{},
+	})
+	lv.list.Refresh()
+	lv.list.Select(len(lv.rules) - 1)
+}
+
+func (lv *lifecycleView) remove() {
+	if lv.cur < 0 {
+		return
+	}
+	lv.rules = append(lv.rules[:lv.cur], lv.rules[lv.cur+1:]...)
+	lv.cur = -1
+	lv.list.UnselectAll()
+	lv.list.Refresh()
+	lv.load(-1)
+}
+
+func (lv *lifecycleView) save() {
+	if err := lv.commit(); err != nil {
+		dialog.ShowError(err, lv.w)
+		return
+	}
+	if err := validateLifecycle(lv.rules); err != nil {
+		dialog.ShowError(err, lv.w)
+		return
+	}
+	go func() {
+		if err := lv.le.SetLifecycle(context.Background(), lv.rules); err != nil {
+			slog.Warn("set lifecycle failed",
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), lv.w)
+			return
+		}
+		slog.Info("set lifecycle success",
+			slog.Int("rules", len(lv.rules)),
+		)
+		dialog.ShowInformation("Lifecycle", fmt.Sprintf("Saved %d rules", len(lv.rules)), lv.w)
+	}()
+}
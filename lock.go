@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// lockNone is the retention choice for no retention
+const lockNone = "None"
+
+// parseRetainUntil reads a local date with an optional time
+func parseRetainUntil(s string) (t time.Time, err error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+		if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+			return
+		}
+	}
+	return t, fmt.Errorf("invalid date %q, want like 2030-01-31 12:00", s)
+}
+
+// showObjectLock shows the object lock configuration of the bucket
+func (sc *Fone) showObjectLock(le lockEditor) {
+	go func() {
+		l, err := le.ObjectLock(context.Background())
+		if err != nil {
+			slog.Warn("get object lock failed",
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), sc.w)
+			return
+		}
+		text := "Object lock is " + l.String()
+		if l.Enabled {
+			text += "\n\nObjects and versions under retention or legal hold can't be deleted,\nset them per object in Properties"
+		}
+		dialog.ShowInformation("Object lock", text, sc.w)
+	}()
+}
+
+// retentionItems edits the retention and legal hold of an object, the
+// returned func reads the choice back
+func retentionItems(lock ObjectLock, old Retention) (items []*widget.FormItem, read func() (r Retention, bypass bool)) {
+	state := "not locked"
+	if old.Locked(time.Now()) {
+		state = "locked, deletes fail"
+	}
+	mode := widget.NewSelect(append([]string{lockNone}, lockModes...), nil)
+	until := widget.NewEntry()
+	until.SetPlaceHolder("2030-01-31 12:00")
+	until.Validator = func(s string) error {
+		if mode.Selected == lockNone {
+			return nil
+		}
+		if _, err := parseRetainUntil(s); err != nil {
+			return err
+		}
+		return nil
+	}
+	mode.OnChanged = func(s string) {
+		if s == lockNone {
+			until.Disable()
+		} else {
+			until.Enable()
+			if until.Text == "" && lock.Mode != "" {
+				// start from the bucket default
+				until.SetText(time.Now().AddDate(lock.Years, 0, lock.Days).Format("2006-01-02 15:04"))
+			}
+		}
+		until.Validate()
+	}
+	if old.Mode != "" {
+		until.SetText(old.Until.Local().Format("2006-01-02 15:04"))
+		mode.SetSelected(old.Mode)
+	} else {
+		mode.SetSelected(lockNone)
+	}
+	legalHold := widget.NewCheck("On", nil)
+	legalHold.SetChecked(old.LegalHold)
+	bypass := widget.NewCheck("Bypass governance to shorten or remove", nil)
+	items = []*widget.FormItem{
+		widget.NewFormItem("Object lock", widget.NewLabel(state+", bucket "+lock.String())),
+		widget.NewFormItem("Retention", mode),
+		widget.NewFormItem("Retain until", until),
+		widget.NewFormItem("Legal hold", legalHold),
+		widget.NewFormItem("", bypass),
+	}
+	return items, func() (r Retention, bypassed bool) {
+		r.LegalHold = legalHold.Checked
+		if mode.Selected != lockNone {
+			r.Mode = mode.Selected
+			r.Until, _ = parseRetainUntil(until.Text)
+			if old.Mode != "" && r.Until.Equal(old.Until.Truncate(time.Minute)) {
+				// the entry drops seconds
+				r.Until = old.Until
+			}
+		}
+		return r, bypass.Checked
+	}
+}
+
+// applyRetention saves a retention changed in the properties dialog
+func applyRetention(ctx context.Context, le lockEditor, key string, old, r Retention, bypass bool) error {
+	if r.Mode == old.Mode && r.Until.Equal(old.Until) && r.LegalHold == old.LegalHold {
+		return nil
+	}
+	if err := le.SetRetention(ctx, key, old, r, bypass); err != nil {
+		return err
+	}
+	slog.Info("set retention success",
+		slog.String("key", key),
+		slog.String("mode", r.Mode),
+		slog.Time("until", r.Until),
+		slog.Bool("legal_hold", r.LegalHold),
+	)
+	return nil
+}
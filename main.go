@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/url"
@@ -20,12 +21,16 @@ import (
 	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/data/validation"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
 const (
 	shvcFone = "https://github.com/shvc/fone"
+
+	viewModeList   = "list"
+	viewModeDetail = "detail"
 )
 
 type contextButtonMenu struct {
@@ -54,6 +59,101 @@ type provider interface {
 	Close(ctx context.Context) error
 }
 
+// searcher is implemented by providers that can search under prefix on the
+// server, fn is called with each batch of matches as they arrive
+type searcher interface {
+	Search(ctx context.Context, prefix, pattern string, fn func(data []File) error) (err error)
+}
+
+// prefixWalker is implemented by providers that can list everything below
+// prefix in one flat listing, fn is called with each batch
+type prefixWalker interface {
+	Walk(ctx context.Context, prefix string, fn func(data []File) error) (err error)
+}
+
+// rangeDownloader is implemented by providers that can read part of a file
+type rangeDownloader interface {
+	DownloadRange(ctx context.Context, w io.Writer, key string, offset, length int64) (err error)
+}
+
+// permEditor is implemented by providers with unix permissions, owners and
+// symlinks
+type permEditor interface {
+	Chmod(ctx context.Context, key string, mode os.FileMode) (err error)
+	Chown(ctx context.Context, key string, uid, gid int) (err error)
+	Symlink(ctx context.Context, target, key string) (err error)
+}
+
+// propsEditor is implemented by providers with object headers, metadata and
+// tags
+type propsEditor interface {
+	Properties(ctx context.Context, key string) (p ObjectProps, err error)
+	SetProperties(ctx context.Context, key string, old, p ObjectProps) (err error)
+}
+
+// classEditor is implemented by providers with storage classes and
+// archive restores
+type classEditor interface {
+	SetUploadClass(class string)
+	Restore(ctx context.Context, key string, days int, tier string) (err error)
+}
+
+// encrypter is implemented by providers with server-side encryption
+type encrypter interface {
+	Encryption() *Encryption
+	SetEncryption(e *Encryption)
+}
+
+// bucketAdmin is implemented by providers that can list, create and delete
+// buckets
+type bucketAdmin interface {
+	Region() string
+	ListBucketInfo(ctx context.Context) (data []BucketInfo, err error)
+	MakeBucket(ctx context.Context, name string, o BucketOptions) (err error)
+	RemoveBucket(ctx context.Context, name, region string, empty bool, progress func(deleted int)) (err error)
+	Summary(ctx context.Context, name, region string) (s BucketSummary)
+}
+
+// lifecycleEditor is implemented by providers with bucket lifecycle rules
+type lifecycleEditor interface {
+	Lifecycle(ctx context.Context) (rules []LifecycleRule, err error)
+	SetLifecycle(ctx context.Context, rules []LifecycleRule) (err error)
+}
+
+// lockEditor is implemented by providers with object lock
+type lockEditor interface {
+	ObjectLock(ctx context.Context) (l ObjectLock, err error)
+	SetRetention(ctx context.Context, key string, old, r Retention, bypass bool) (err error)
+}
+
+// aclInspector is implemented by providers with bucket and object ACLs
+type aclInspector interface {
+	PublicAccessBlock(ctx context.Context) (p PublicAccessBlock, err error)
+	BucketACL(ctx context.Context) (a ACL, err error)
+	ObjectACL(ctx context.Context, key string) (a ACL, err error)
+	ScanACL(ctx context.Context, prefix string, progress func(n int)) (objects []ObjectACL, err error)
+	ApplyACL(ctx context.Context, keys []string, canned string, progress func(n int)) (err error)
+}
+
+// corsEditor is implemented by providers with bucket CORS rules
+type corsEditor interface {
+	CORS(ctx context.Context) (rules []CORSRule, err error)
+	SetCORS(ctx context.Context, rules []CORSRule) (err error)
+	PreflightCORS(ctx context.Context, key, origin, method string, headers []string) (res PreflightResult, err error)
+}
+
+// websiteEditor is implemented by providers with static website hosting
+type websiteEditor interface {
+	Website(ctx context.Context) (w Website, err error)
+	SetWebsite(ctx context.Context, w Website) (err error)
+}
+
+// stateNotifier is implemented by providers whose connection can drop and
+// come back, fn is called on every change
+type stateNotifier interface {
+	OnStateChange(fn func(state connState))
+}
+
 type Fone struct {
 	a             fyne.App
 	w             fyne.Window
@@ -70,6 +170,16 @@ type Fone struct {
 	pathLabel     *widget.Label
 	infoLabel     *widget.Label
 	appTab        *container.AppTabs
+	preview       *fyne.Container
+	previewTitle  *widget.Label
+	previewBody   *fyne.Container
+	previewCancel context.CancelFunc
+	bodyView      *fyne.Container
+	searchEntry   *widget.Entry
+	vault         *Vault
+	secretEntries map[string]*widget.Entry
+	sites         *siteManager
+	insecure      bool
 }
 
 func splitKeyValue(data, sep string) (string, string) {
@@ -162,6 +272,36 @@ func (sc *Fone) makeHeader() error {
 			slog.Info("Bucket Versioning")
 		}),
 	)
+	if le, ok := unwrap(sc.client).(lifecycleEditor); ok {
+		bucketItem.ChildMenu.Items = append(bucketItem.ChildMenu.Items, fyne.NewMenuItem("Lifecycle", func() {
+			sc.showLifecycle(le)
+		}))
+	}
+	if le, ok := unwrap(sc.client).(lockEditor); ok {
+		bucketItem.ChildMenu.Items = append(bucketItem.ChildMenu.Items, fyne.NewMenuItem("Object lock", func() {
+			sc.showObjectLock(le)
+		}))
+	}
+	if ai, ok := unwrap(sc.client).(aclInspector); ok {
+		bucketItem.ChildMenu.Items = append(bucketItem.ChildMenu.Items, fyne.NewMenuItem("Public access", func() {
+			sc.showACL(ai)
+		}))
+	}
+	if ce, ok := unwrap(sc.client).(corsEditor); ok {
+		bucketItem.ChildMenu.Items = append(bucketItem.ChildMenu.Items, fyne.NewMenuItem("CORS", func() {
+			sc.showCORS(ce)
+		}))
+	}
+	if we, ok := unwrap(sc.client).(websiteEditor); ok {
+		bucketItem.ChildMenu.Items = append(bucketItem.ChildMenu.Items, fyne.NewMenuItem("Website", func() {
+			sc.showWebsite(we)
+		}))
+	}
+	if admin, ok := unwrap(sc.client).(bucketAdmin); ok {
+		bucketItem.ChildMenu.Items = append(bucketItem.ChildMenu.Items, fyne.NewMenuItem("Manage buckets", func() {
+			sc.showBuckets(admin)
+		}))
+	}
 	link, err := url.Parse(shvcFone)
 	if err != nil {
 		slog.Warn("Could not parse URL",
@@ -169,14 +309,57 @@ func (sc *Fone) makeHeader() error {
 			slog.String("error", err.Error()),
 		)
 	}
+	viewItem := fyne.NewMenuItem("View", nil)
+	followItem := fyne.NewMenuItem("Follow links", nil)
+	followItem.Checked = sc.a.Preferences().BoolWithFallback(followLinksKey, true)
+	followItem.Action = func() {
+		followItem.Checked = !followItem.Checked
+		sc.a.Preferences().SetBool(followLinksKey, followItem.Checked)
+	}
+	viewItem.ChildMenu = fyne.NewMenu("",
+		fyne.NewMenuItem("List", func() {
+			sc.setViewMode(viewModeList)
+		}),
+		fyne.NewMenuItem("Details", func() {
+			sc.setViewMode(viewModeDetail)
+		}),
+		followItem,
+	)
+	fileItem := fyne.NewMenuItem("File", nil)
+	fileItem.ChildMenu = fyne.NewMenu("",
+		fyne.NewMenuItem("Properties", func() {
+			sc.showProperties(sc.selectFile)
+		}),
+	)
+	fileItem.ChildMenu.Items = append(fileItem.ChildMenu.Items, fyne.NewMenuItem("Analyze folder", func() {
+		sc.analyzePrefix(sc.pathLabel.Text)
+	}))
+	if _, ok := unwrap(sc.client).(permEditor); ok {
+		fileItem.ChildMenu.Items = append(fileItem.ChildMenu.Items, fyne.NewMenuItem("New symlink", sc.newSymlink))
+	}
+	if _, ok := unwrap(sc.client).(encrypter); ok {
+		fileItem.ChildMenu.Items = append(fileItem.ChildMenu.Items, fyne.NewMenuItem("Upload encryption", sc.uploadEncryption))
+	}
+	if _, ok := unwrap(sc.client).(classEditor); ok {
+		fileItem.ChildMenu.Items = append(fileItem.ChildMenu.Items, fyne.NewMenuItem("Restore", func() {
+			sc.restoreObject(sc.selectFile)
+		}))
+	}
 	menuLabel := buttonMenu(theme.MenuIcon(), fyne.NewMenu("",
+		viewItem,
+		fileItem,
 		bucketItem,
+		fyne.NewMenuItem("Lock vault", sc.vault.Lock),
 		fyne.NewMenuItem("About", func() {
 			dialog.NewCustom("About", "OK", widget.NewHyperlink(shvcFone, link), sc.w).Show()
 		}),
 		fyne.NewMenuItem("Exit", func() {
 			dialog.NewConfirm("Exit", "Exit current Session?", func(ok bool) {
 				if ok {
+					if n, ok := unwrap(sc.client).(stateNotifier); ok {
+						n.OnStateChange(nil)
+					}
+					sc.client.Close(context.Background())
 					sc.w.SetContent(sc.appTab)
 					sc.w.Resize(fyne.NewSize(600, 300))
 					sc.selectFile.Name = ""
@@ -193,6 +376,23 @@ func (sc *Fone) makeHeader() error {
 		sc.btnRefresh,
 		menuLabel,
 	)
+	if n, ok := unwrap(sc.client).(stateNotifier); ok {
+		status := widget.NewLabel("")
+		showState := func(state connState) {
+			switch state {
+			case connConnected:
+				status.Importance = widget.SuccessImportance
+			case connReconnecting:
+				status.Importance = widget.WarningImportance
+			default:
+				status.Importance = widget.DangerImportance
+			}
+			status.SetText("● " + state.String())
+		}
+		showState(connConnected)
+		n.OnStateChange(showState)
+		rightWidgets.Objects = append([]fyne.CanvasObject{status}, rightWidgets.Objects...)
+	}
 
 	btnBackward := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
 		if sc.pathLabel.Text == "" || sc.pathLabel.Text == "/" {
@@ -252,7 +452,7 @@ func (sc *Fone) makeHeader() error {
 		nil,
 		leftWidgets,
 		rightWidgets,
-		rightWidgets, leftWidgets,
+		sc.makeSearch(),
 	)
 	return nil
 }
@@ -299,6 +499,18 @@ func (sc *Fone) makeFooter() error {
 				defer uc.Close()
 
 				err := sc.client.Download(downloadCtx, uc, key)
+				if errors.Is(err, errChecksumMismatch) {
+					slog.Warn("download corrupted",
+						slog.String("key", key),
+						slog.String("error", err.Error()),
+					)
+					uc.Close()
+					if e := storage.Delete(uc.URI()); e != nil {
+						err = fmt.Errorf("%w, remove %s error %v", err, uc.URI().Name(), e)
+					}
+					dialog.ShowError(err, sc.w)
+					return
+				}
 				if err != nil {
 					slog.Warn("download failed",
 						slog.String("key", key),
@@ -396,7 +608,7 @@ func (sc *Fone) makeFooter() error {
 	btnUpload.Importance = widget.LowImportance
 
 	btnDelete := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
-		if sc.selectFile.IsDir() || sc.selectFile.Name == "" {
+		if (sc.selectFile.IsDir() && !sc.selectFile.IsLink()) || sc.selectFile.Name == "" {
 			sc.infoLabel.SetText("Warn: No file chosen to delete!")
 			return
 		}
@@ -407,6 +619,9 @@ func (sc *Fone) makeFooter() error {
 				slog.String("key", key),
 				slog.String("error", e.Error()),
 			)
+			if errors.Is(e, errLocked) {
+				dialog.ShowError(fmt.Errorf("delete %s: %w", sc.selectFile.Name, errLocked), sc.w)
+			}
 			return
 		}
 		sc.body.Delete(sc.selectItemID)
@@ -416,11 +631,34 @@ func (sc *Fone) makeFooter() error {
 	})
 	btnDelete.Importance = widget.LowImportance
 
+	btnEdit := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+		sc.editFile(sc.selectFile)
+	})
+	btnEdit.Importance = widget.LowImportance
+
+	btnInfo := widget.NewButtonWithIcon("", theme.InfoIcon(), func() {
+		sc.showProperties(sc.selectFile)
+	})
+	btnInfo.Importance = widget.LowImportance
+
 	rightWidgets := container.NewHBox(
+		btnInfo,
+		btnEdit,
 		btnUpload,
 		btnDownload,
 		btnDelete,
 	)
+	if ce, ok := unwrap(sc.client).(classEditor); ok {
+		// storage class of the next uploads
+		uploadClass := widget.NewSelect(append([]string{classDefault}, storageClasses...), nil)
+		uploadClass.SetSelected(sc.a.Preferences().StringWithFallback("s3.upload_class", classDefault))
+		uploadClass.OnChanged = func(class string) {
+			ce.SetUploadClass(class)
+			sc.a.Preferences().SetString("s3.upload_class", class)
+		}
+		ce.SetUploadClass(uploadClass.Selected)
+		rightWidgets.Objects = append([]fyne.CanvasObject{uploadClass}, rightWidgets.Objects...)
+	}
 
 	sc.infoLabel = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: false})
 	leftWidgets := container.NewHBox(
@@ -470,6 +708,13 @@ func (sc *Fone) initBody(data []File) {
 		sc.selectFile = sc.body.SelectFile(i)
 		if !sc.selectFile.IsDir() {
 			showLabelMsg(sc.infoLabel, sc.selectFile.Info())
+			sc.showPreview(sc.selectFile)
+			return
+		}
+		sc.showPreview(File{})
+		if sc.selectFile.IsLink() && !sc.a.Preferences().BoolWithFallback(followLinksKey, true) {
+			showLabelMsg(sc.infoLabel, sc.selectFile.Info())
+			sc.showProperties(sc.selectFile)
 			return
 		}
 
@@ -496,6 +741,58 @@ func (sc *Fone) initBody(data []File) {
 		sc.body.Update(prefix, data)
 		sc.appendBody(sc.refreshCtx, prefix, nextMarker)
 	}, nil)
+	sc.body.SetSort(sc.a.Preferences().IntWithFallback("view.sort_by", SortName), sc.a.Preferences().Bool("view.sort_desc"))
+	sc.body.OnSort(func(by int, desc bool) {
+		sc.a.Preferences().SetInt("view.sort_by", by)
+		sc.a.Preferences().SetBool("view.sort_desc", desc)
+	})
+	sc.body.OnMenu(func(f File, e *fyne.PointEvent) {
+		items := []*fyne.MenuItem{
+			fyne.NewMenuItem("Properties", func() {
+				sc.showProperties(f)
+			}),
+		}
+		if f.IsDir() {
+			items = append(items, fyne.NewMenuItem("Analyze", func() {
+				sc.analyzePrefix(sc.pathLabel.Text + f.Name)
+			}))
+		}
+		widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), sc.w.Canvas(), e.AbsolutePosition)
+	})
+}
+
+// viewObject returns the list or the detail table according to the saved
+// view mode
+func (sc *Fone) viewObject() fyne.CanvasObject {
+	if sc.a.Preferences().StringWithFallback("view.mode", viewModeList) == viewModeDetail {
+		return sc.body.Table()
+	}
+	return sc.body
+}
+
+func (sc *Fone) setViewMode(mode string) {
+	sc.a.Preferences().SetString("view.mode", mode)
+	if sc.bodyView == nil {
+		return
+	}
+	sc.body.UnselectAll()
+	sc.bodyView.Objects = []fyne.CanvasObject{sc.viewObject()}
+	sc.bodyView.Refresh()
+}
+
+func (sc *Fone) showSession() {
+	sc.makePreview()
+	sc.bodyView = container.NewStack(sc.viewObject())
+	split := container.NewHSplit(sc.bodyView, sc.preview)
+	split.Offset = 0.6
+	var top fyne.CanvasObject = sc.header
+	if sc.insecure {
+		banner := widget.NewLabelWithStyle("TLS certificate verification is disabled for this connection", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+		banner.Importance = widget.DangerImportance
+		top = container.NewVBox(banner, sc.header)
+	}
+	sc.w.SetContent(container.NewBorder(top, sc.footer, nil, nil, split))
+	sc.w.Resize(fyne.NewSize(1000, 600))
 }
 
 func (sc *Fone) createS3LoginForm() *widget.Form {
@@ -504,11 +801,52 @@ func (sc *Fone) createS3LoginForm() *widget.Form {
 	endpoint.Validator = validation.NewRegexp(`^(?:https?://)?(?:[^/.\s]+\.)*`, "not a valid endpoint address")
 	region := widget.NewEntryWithData(binding.BindPreferenceString("cred.s3_region", sc.a.Preferences()))
 	region.SetPlaceHolder("cn-north-1")
+	addressing := widget.NewSelectWithData([]string{addressingAuto, addressingPath, addressingVirtual}, binding.BindPreferenceString("cred.s3_addressing", sc.a.Preferences()))
+	if addressing.Selected == "" {
+		addressing.SetSelected(addressingAuto)
+	}
 	bucketEntry := widget.NewSelectEntry(nil)
 	bucketEntry.Bind(binding.BindPreferenceString("cred.s3_bucket", sc.a.Preferences()))
 	user := widget.NewEntryWithData(binding.BindPreferenceString("cred.s3_user", sc.a.Preferences()))
 	pass := widget.NewPasswordEntry()
-	pass.Bind(binding.BindPreferenceString("cred.s3_pass", sc.a.Preferences()))
+	sc.secretEntry("cred.s3_pass", pass)
+	forget := widget.NewCheckWithData("Don't remember", binding.BindPreferenceBool("cred.s3_forget", sc.a.Preferences()))
+	tuning := newS3Tuning(sc.a.Preferences())
+	advanced := tuning.accordion(widget.NewFormItem("Addressing", addressing))
+	ps := newProxySettings(sc.a.Preferences(), "s3")
+	sc.secretEntry("cred.s3_proxy_password", ps.password)
+	advanced.Append(widget.NewAccordionItem("Proxy", widget.NewForm(ps.items()...)))
+	sse := newSSESettings(sc.a.Preferences())
+	advanced.Append(widget.NewAccordionItem("Encryption", widget.NewForm(sse.items()...)))
+	cs := newCryptSettings(sc.a.Preferences(), "s3")
+	sc.secretEntry("cred.s3_crypt_passphrase", cs.passphrase)
+	advanced.Append(widget.NewAccordionItem("Client-side encryption", widget.NewForm(cs.items()...)))
+
+	config := func() connConfig {
+		cfg := connConfig{
+			Type:       "s3",
+			Endpoint:   endpoint.Text,
+			Region:     region.Text,
+			Bucket:     bucketEntry.Text,
+			Addressing: addressing.Selected,
+			AccessKey:  user.Text,
+			SecretKey:  pass.Text,
+		}
+		tuning.apply(&cfg)
+		ps.apply(&cfg)
+		sse.apply(&cfg)
+		cs.apply(&cfg)
+		return cfg
+	}
+	manage := widget.NewButtonWithIcon("", theme.StorageIcon(), func() {
+		cfg := config()
+		opt, err := cfg.s3Options()
+		if err != nil {
+			dialog.ShowError(err, sc.w)
+			return
+		}
+		sc.showBuckets(NewClientWithOptions(cfg.AccessKey, cfg.SecretKey, cfg.Region, cfg.Endpoint, opt))
+	})
 
 	return &widget.Form{
 		Items: []*widget.FormItem{
@@ -516,44 +854,27 @@ func (sc *Fone) createS3LoginForm() *widget.Form {
 			widget.NewFormItem("Region", region),
 			widget.NewFormItem("AccessKey", user),
 			widget.NewFormItem("SecretKey", pass),
-			widget.NewFormItem("Bucket", bucketEntry),
+			widget.NewFormItem("", forget),
+			widget.NewFormItem("Bucket", container.NewBorder(nil, nil, nil, manage, bucketEntry)),
+			widget.NewFormItem("", advanced),
 		},
 		SubmitText: "Enter",
 		OnSubmit: func() {
-			sc.w.SetTitle("S3")
+			sc.storeSecrets(map[string]string{
+				"cred.s3_pass":             pass.Text,
+				"cred.s3_proxy_password":   ps.password.Text,
+				"cred.s3_crypt_passphrase": cs.passphrase.Text,
+			}, forget.Checked)
+			cfg := config()
 			if bucketEntry.Text != "" {
-				bucketName, keyPrefix := splitKeyValue(bucketEntry.Text, "/")
-				sc.client = NewClientWithBucket(bucketName, keyPrefix, user.Text, pass.Text, region.Text, endpoint.Text)
-				sc.lockRefresh()
-				data, nextMarker, err := sc.client.List(context.Background(), "", "")
+				sc.connect("S3", cfg)
+			} else {
+				opt, err := cfg.s3Options()
 				if err != nil {
-					slog.Warn("list file failed",
-						slog.String("endpoint", endpoint.Text),
-						slog.String("bucket", bucketEntry.Text),
-						slog.String("user", user.Text),
-						slog.String("error", err.Error()),
-					)
-					dialog.ShowError(unwrapError(err), sc.w)
+					dialog.ShowError(err, sc.w)
 					return
 				}
-				slog.Info("list file success",
-					slog.String("endpoint", endpoint.Text),
-					slog.String("bucket", bucketEntry.Text),
-					slog.String("user", user.Text),
-				)
-
-				sc.makeHeader()
-				sc.initBody(data)
-				sc.makeFooter()
-
-				sc.refreshCtx, sc.refreshCancel = context.WithCancel(context.Background())
-				sc.lockRefresh()
-				sc.appendBody(sc.refreshCtx, "", nextMarker)
-
-				sc.w.SetContent(container.NewBorder(sc.header, sc.footer, nil, nil, sc.body))
-				sc.w.Resize(fyne.NewSize(800, 600))
-			} else {
-				client := NewClient(user.Text, pass.Text, region.Text, endpoint.Text)
+				client := NewClientWithOptions(user.Text, pass.Text, region.Text, endpoint.Text, opt)
 				data, err := client.ListAllMyBuckets(context.Background())
 				if err != nil {
 					slog.Warn("list buckets failed",
@@ -584,7 +905,18 @@ func (sc *Fone) createSftpLoginForm() *widget.Form {
 	remoteDir := widget.NewEntryWithData(binding.BindPreferenceString("cred.sftp_dir", sc.a.Preferences()))
 	sftpUser := widget.NewEntryWithData(binding.BindPreferenceString("cred.sftp_user", sc.a.Preferences()))
 	sftpPassword := widget.NewPasswordEntry()
-	sftpPassword.Bind(binding.BindPreferenceString("cred.sftp_password", sc.a.Preferences()))
+	sc.secretEntry("cred.sftp_password", sftpPassword)
+	forget := widget.NewCheckWithData("Don't remember", binding.BindPreferenceBool("cred.sftp_forget", sc.a.Preferences()))
+	noChecksum := widget.NewCheckWithData("Skip checksum verification", binding.BindPreferenceBool("cred.sftp_no_checksum", sc.a.Preferences()))
+	ps := newProxySettings(sc.a.Preferences(), "sftp")
+	sc.secretEntry("cred.sftp_proxy_password", ps.password)
+	cs := newCryptSettings(sc.a.Preferences(), "sftp")
+	sc.secretEntry("cred.sftp_crypt_passphrase", cs.passphrase)
+	advanced := widget.NewAccordion(
+		widget.NewAccordionItem("Proxy", widget.NewForm(ps.items()...)),
+		widget.NewAccordionItem("Client-side encryption", widget.NewForm(cs.items()...)),
+		widget.NewAccordionItem("Integrity", widget.NewForm(widget.NewFormItem("", noChecksum))),
+	)
 
 	return &widget.Form{
 		Items: []*widget.FormItem{
@@ -592,58 +924,83 @@ func (sc *Fone) createSftpLoginForm() *widget.Form {
 			widget.NewFormItem("Directory", remoteDir),
 			widget.NewFormItem("User", sftpUser),
 			widget.NewFormItem("Password", sftpPassword),
+			widget.NewFormItem("", forget),
+			widget.NewFormItem("", advanced),
 		},
 		SubmitText: "Enter",
 		OnSubmit: func() {
-			var err error
-			var pwd string
-			sc.w.SetTitle("sftp")
-			sc.client, pwd, err = NewSftpClient(server.Text, sftpUser.Text, sftpPassword.Text, remoteDir.Text)
-			if err != nil {
-				slog.Warn("init provider failed",
-					slog.String("server", server.Text),
-					slog.String("pwd", pwd),
-					slog.String("user", sftpUser.Text),
-					slog.String("error", err.Error()),
-				)
-				return
+			sc.storeSecrets(map[string]string{
+				"cred.sftp_password":         sftpPassword.Text,
+				"cred.sftp_proxy_password":   ps.password.Text,
+				"cred.sftp_crypt_passphrase": cs.passphrase.Text,
+			}, forget.Checked)
+			cfg := connConfig{
+				Type:     "sftp",
+				Server:   server.Text,
+				Dir:      remoteDir.Text,
+				User:     sftpUser.Text,
+				Auth:     authPassword,
+				Password: sftpPassword.Text,
 			}
-
-			sc.lockRefresh()
-			data, nextMarker, err := sc.client.List(context.Background(), pwd, "")
-			if err != nil {
-				slog.Warn("list file failed",
-					slog.String("server", server.Text),
-					slog.String("pwd", pwd),
-					slog.String("user", sftpUser.Text),
-					slog.String("error", err.Error()),
-				)
-				dialog.ShowError(unwrapError(err), sc.w)
-				return
+			if noChecksum.Checked {
+				cfg.Checksum = checksumOff
 			}
+			ps.apply(&cfg)
+			cs.apply(&cfg)
+			sc.connect("sftp", cfg)
+		},
+	}
+}
 
-			slog.Info("list file success",
-				slog.String("server", server.Text),
-				slog.String("pwd", pwd),
-				slog.String("user", sftpUser.Text),
-			)
+// connect opens cfg and shows its file list
+func (sc *Fone) connect(title string, cfg connConfig) {
+	sc.w.SetTitle(title)
+	client, pwd, err := cfg.open()
+	if err != nil {
+		slog.Warn("init provider failed",
+			slog.String("type", cfg.Type),
+			slog.String("address", cfg.address()),
+			slog.String("user", cfg.user()),
+			slog.String("error", err.Error()),
+		)
+		dialog.ShowError(unwrapError(err), sc.w)
+		return
+	}
+	sc.client = client
+	sc.insecure = cfg.Type == "s3" && (cfg.SkipVerify || os.Getenv("AWS_SKIP_VERIFY") != "")
 
-			sc.makeHeader()
-			sc.initBody(data)
-			sc.makeFooter()
-			if !strings.HasSuffix(pwd, "/") {
-				pwd += "/"
-			}
-			sc.pathLabel.SetText(pwd)
+	sc.lockRefresh()
+	data, nextMarker, err := sc.client.List(context.Background(), pwd, "")
+	if err != nil {
+		slog.Warn("list file failed",
+			slog.String("type", cfg.Type),
+			slog.String("address", cfg.address()),
+			slog.String("pwd", pwd),
+			slog.String("user", cfg.user()),
+			slog.String("error", err.Error()),
+		)
+		sc.unlockRefresh()
+		dialog.ShowError(unwrapError(err), sc.w)
+		return
+	}
 
-			sc.refreshCtx, sc.refreshCancel = context.WithCancel(context.Background())
-			sc.lockRefresh()
-			sc.appendBody(sc.refreshCtx, "", nextMarker)
+	slog.Info("list file success",
+		slog.String("type", cfg.Type),
+		slog.String("address", cfg.address()),
+		slog.String("pwd", pwd),
+		slog.String("user", cfg.user()),
+	)
 
-			sc.w.SetContent(container.NewBorder(sc.header, sc.footer, nil, nil, sc.body))
-			sc.w.Resize(fyne.NewSize(800, 600))
-		},
-	}
+	sc.makeHeader()
+	sc.initBody(data)
+	sc.makeFooter()
+	sc.pathLabel.SetText(pwd)
+
+	sc.refreshCtx, sc.refreshCancel = context.WithCancel(context.Background())
+	sc.lockRefresh()
+	sc.appendBody(sc.refreshCtx, pwd, nextMarker)
+
+	sc.showSession()
 }
 
 func main() {
@@ -651,6 +1008,10 @@ func main() {
 	var debug bool
 	flag.StringVar(&logfile, "log", filepath.Join(os.TempDir(), "fone.log"), "log filename")
 	flag.BoolVar(&debug, "debug", false, "debug log")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), cliUsage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	logfd, err := os.OpenFile(logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
@@ -663,12 +1024,17 @@ func main() {
 	}
 	slog.SetDefault(slog.New(slog.NewTextHandler(logfd, logOpt)))
 
+	if flag.NArg() > 0 {
+		os.Exit(runCLI(flag.Args()))
+	}
+
 	fe := Fone{
 		a: app.NewWithID("cc.shvc.fone"),
 	}
 	fe.w = fe.a.NewWindow("fone")
 
 	fe.appTab = container.NewAppTabs(
+		container.NewTabItemWithIcon("Sites", theme.StorageIcon(), fe.createSiteManager()),
 		container.NewTabItemWithIcon("S3", theme.FileIcon(), fe.createS3LoginForm()),
 		container.NewTabItemWithIcon("sftp", theme.FolderIcon(), fe.createSftpLoginForm()),
 	)
@@ -676,5 +1042,6 @@ func main() {
 	fe.w.SetContent(fe.appTab)
 	fe.w.Resize(fyne.NewSize(600, 300))
 	fe.w.CenterOnScreen()
+	fe.initVault()
 	fe.w.ShowAndRun()
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// filesGroup holds the files directly in the analyzed prefix
+	filesGroup = "(files)"
+	noneGroup  = "(none)"
+)
+
+// Total is the object count and size of a group
+type Total struct {
+	Name  string
+	Count int64
+	Bytes int64
+}
+
+// PrefixStats are the running totals of a prefix walk
+type PrefixStats struct {
+	Count   int64
+	Bytes   int64
+	BySub   map[string]*Total
+	ByClass map[string]*Total
+	ByExt   map[string]*Total
+	// Largest are the topN largest files, largest first
+	Largest []File
+	topN    int
+}
+
+func newPrefixStats(topN int) *PrefixStats {
+	return &PrefixStats{
+		BySub:   map[string]*Total{},
+		ByClass: map[string]*Total{},
+		ByExt:   map[string]*Total{},
+		topN:    topN,
+	}
+}
+
+func addTotal(m map[string]*Total, name string, size int64) {
+	t, ok := m[name]
+	if !ok {
+		t = &Total{Name: name}
+		m[name] = t
+	}
+	t.Count++
+	t.Bytes += size
+}
+
+// Add counts f, its Name is relative to the analyzed prefix
+func (s *PrefixStats) Add(f File) {
+	s.Count++
+	s.Bytes += f.Size
+	sub := filesGroup
+	if i := strings.Index(f.Name, "/"); i >= 0 {
+		sub = f.Name[:i+1]
+	}
+	addTotal(s.BySub, sub, f.Size)
+	addTotal(s.ByClass, cmp.Or(f.StorageClass, noneGroup), f.Size)
+	addTotal(s.ByExt, cmp.Or(strings.ToLower(path.Ext(path.Base(f.Name))), noneGroup), f.Size)
+
+	if s.topN <= 0 || (len(s.Largest) == s.topN && f.Size <= s.Largest[len(s.Largest)-1].Size) {
+		return
+	}
+	i, _ := slices.BinarySearchFunc(s.Largest, f.Size, func(l File, size int64) int {
+		return cmp.Compare(size, l.Size)
+	})
+	s.Largest = slices.Insert(s.Largest, i, f)
+	if len(s.Largest) > s.topN {
+		s.Largest = s.Largest[:s.topN]
+	}
+}
+
+// sortedTotals returns the groups of m, largest first
+func sortedTotals(m map[string]*Total) []Total {
+	out := make([]Total, 0, len(m))
+	for _, t := range m {
+		out = append(out, *t)
+	}
+	slices.SortFunc(out, func(a, b Total) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), strings.Compare(a.Name, b.Name))
+	})
+	return out
+}
+
+// WriteCSV writes every breakdown as section,name,count,bytes rows
+func (s *PrefixStats) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	row := func(section, name string, count, bytes int64) {
+		cw.Write([]string{section, name, strconv.FormatInt(count, 10), strconv.FormatInt(bytes, 10)})
+	}
+	cw.Write([]string{"section", "name", "count", "bytes"})
+	row("total", "", s.Count, s.Bytes)
+	for _, section := range []struct {
+		name string
+		m    map[string]*Total
+	}{
+		{"prefix", s.BySub},
+		{"class", s.ByClass},
+		{"extension", s.ByExt},
+	} {
+		for _, t := range sortedTotals(section.m) {
+			row(section.name, t.Name, t.Count, t.Bytes)
+		}
+	}
+	for _, f := range s.Largest {
+		row("largest", f.Name, 1, f.Size)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// walkPrefix calls fn with batches of every file below prefix, named
+// relative to it, using a flat listing where the provider has one
+func walkPrefix(ctx context.Context, c provider, prefix string, fn func(data []File) error) error {
+	// the encryption wrapper always has Walk, ask what it wraps
+	if _, ok := unwrap(c).(prefixWalker); ok {
+		return c.(prefixWalker).Walk(ctx, prefix, fn)
+	}
+	return walkRemote(ctx, c, prefix, func(rel string, f File) error {
+		f.Name = rel
+		return fn([]File{f})
+	})
+}
+
+// rect is a cell of the treemap
+type rect struct {
+	X, Y, W, H float64
+}
+
+// squarify lays weights out in a w×h area as a treemap with cells close to
+// square, weights must be positive and sorted largest first
+func squarify(weights []float64, w, h float64) []rect {
+	out := make([]rect, len(weights))
+	var total float64
+	for _, v := range weights {
+		total += v
+	}
+	if total <= 0 || w <= 0 || h <= 0 {
+		return out
+	}
+	areas := make([]float64, len(weights))
+	for i, v := range weights {
+		areas[i] = v / total * w * h
+	}
+	var x, y float64
+	for i := 0; i < len(areas); {
+		side := min(w, h)
+		// grow the row while that makes its worst aspect ratio better
+		j := i + 1
+		for j < len(areas) && worstRatio(areas[i:j+1], side) <= worstRatio(areas[i:j], side) {
+			j++
+		}
+		var sum float64
+		for _, a := range areas[i:j] {
+			sum += a
+		}
+		thick := sum / side
+		var off float64
+		for k, a := range areas[i:j] {
+			length := a / thick
+			if w >= h {
+				out[i+k] = rect{x, y + off, thick, length}
+			} else {
+				out[i+k] = rect{x + off, y, length, thick}
+			}
+			off += length
+		}
+		if w >= h {
+			x, w = x+thick, w-thick
+		} else {
+			y, h = y+thick, h-thick
+		}
+		i = j
+	}
+	return out
+}
+
+// worstRatio is the largest aspect ratio of row laid along side
+func worstRatio(row []float64, side float64) float64 {
+	var sum, hi float64
+	lo := row[0]
+	for _, a := range row {
+		sum += a
+		hi = max(hi, a)
+		lo = min(lo, a)
+	}
+	s2, side2 := sum*sum, side*side
+	return max(side2*hi/s2, s2/(side2*lo))
+}
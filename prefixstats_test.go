@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPrefixStats_Add(t *testing.T) {
+	s := newPrefixStats(2)
+	for _, f := range []File{
+		{Name: "a.TXT", Size: 10, StorageClass: "STANDARD"},
+		{Name: "logs/1.log", Size: 100, StorageClass: "STANDARD"},
+		{Name: "logs/2.log", Size: 300, StorageClass: "GLACIER"},
+		{Name: "img/x/y.png", Size: 50},
+		{Name: "README", Size: 5},
+	} {
+		s.Add(f)
+	}
+	if s.Count != 5 || s.Bytes != 465 {
+		t.Errorf("totals = %d, %d, want 5, 465", s.Count, s.Bytes)
+	}
+	subs := sortedTotals(s.BySub)
+	want := []Total{{"logs/", 2, 400}, {"img/", 1, 50}, {filesGroup, 2, 15}}
+	if len(subs) != len(want) {
+		t.Fatalf("BySub = %v, want %v", subs, want)
+	}
+	for i := range want {
+		if subs[i] != want[i] {
+			t.Errorf("BySub[%d] = %v, want %v", i, subs[i], want[i])
+		}
+	}
+	if c := s.ByClass[noneGroup]; c == nil || c.Count != 2 {
+		t.Errorf("ByClass = %v", s.ByClass)
+	}
+	if e := s.ByExt[".txt"]; e == nil || e.Bytes != 10 || s.ByExt[noneGroup].Count != 1 || s.ByExt[".log"].Count != 2 {
+		t.Errorf("ByExt = %v", s.ByExt)
+	}
+	if len(s.Largest) != 2 || s.Largest[0].Size != 300 || s.Largest[1].Size != 100 {
+		t.Errorf("Largest = %v", s.Largest)
+	}
+}
+
+func TestPrefixStats_WriteCSV(t *testing.T) {
+	s := newPrefixStats(1)
+	s.Add(File{Name: "a/b.txt", Size: 3, StorageClass: "STANDARD"})
+	var buf bytes.Buffer
+	if err := s.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "section,name,count,bytes\ntotal,,1,3\nprefix,a/,1,3\nclass,STANDARD,1,3\nextension,.txt,1,3\nlargest,a/b.txt,1,3\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSquarify(t *testing.T) {
+	weights := []float64{6, 6, 4, 3, 2, 2, 1}
+	rects := squarify(weights, 6, 4)
+	var area float64
+	for i, r := range rects {
+		want := weights[i] / 24 * 24
+		if math.Abs(r.W*r.H-want) > 1e-9 {
+			t.Errorf("rect %d area = %v, want %v", i, r.W*r.H, want)
+		}
+		if r.X < -1e-9 || r.Y < -1e-9 || r.X+r.W > 6+1e-9 || r.Y+r.H > 4+1e-9 {
+			t.Errorf("rect %d = %+v outside 6x4", i, r)
+		}
+		area += r.W * r.H
+	}
+	if math.Abs(area-24) > 1e-9 {
+		t.Errorf("total area = %v, want 24", area)
+	}
+	// the first two cells of the classic example share the left column
+	if rects[0].X != 0 || rects[1].X != 0 || rects[0].W != 3 {
+		t.Errorf("first row = %+v, %+v", rects[0], rects[1])
+	}
+	if got := squarify(nil, 6, 4); len(got) != 0 {
+		t.Errorf("squarify(nil) = %v", got)
+	}
+}
+
+func TestWalkPrefix(t *testing.T) {
+	m := &memProvider{files: map[string][]byte{
+		"dir/a.txt":     []byte("aa"),
+		"dir/sub/b.txt": []byte("bbb"),
+		"other.txt":     []byte("o"),
+	}}
+	var names []string
+	err := walkPrefix(context.Background(), m, "dir/", func(data []File) error {
+		for _, f := range data {
+			names = append(names, f.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, ","); got != "a.txt,sub/b.txt" && got != "sub/b.txt,a.txt" {
+		t.Errorf("walkPrefix() = %v", names)
+	}
+
+	f := withFakeS3(t, xmlResponse(http.StatusOK, `<ListBucketResult><IsTruncated>false</IsTruncated>`+
+		`<Contents><Key>dir/</Key><Size>0</Size></Contents>`+
+		`<Contents><Key>dir/sub/b.txt</Key><Size>3</Size><StorageClass>GLACIER</StorageClass></Contents></ListBucketResult>`))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	var got []File
+	err = walkPrefix(context.Background(), c, "dir/", func(data []File) error {
+		got = append(got, data...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "sub/b.txt" || got[0].Size != 3 || got[0].StorageClass != "GLACIER" {
+		t.Errorf("walkPrefix(s3) = %+v", got)
+	}
+	if q := f.requests[0].URL.Query(); q.Get("delimiter") != "" || q.Get("prefix") != "dir/" {
+		t.Errorf("walkPrefix(s3) query = %v", q)
+	}
+
+	// client-side encryption keeps the flat listing
+	f = withFakeS3(t, xmlResponse(http.StatusOK, `<ListBucketResult><IsTruncated>false</IsTruncated>`+
+		`<Contents><Key>dir/sub/b.txt</Key><Size>3</Size></Contents></ListBucketResult>`))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	got = nil
+	err = walkPrefix(context.Background(), &cryptProvider{provider: c, m: testCryptMaster(t)}, "dir/", func(data []File) error {
+		got = append(got, data...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "sub/b.txt" || len(f.requests) != 1 || f.requests[0].URL.Query().Has("delimiter") {
+		t.Errorf("walkPrefix(crypt) = %+v, requests %v", got, f.urls)
+	}
+	// and falls back to walking directories when the provider has no flat listing
+	names = nil
+	err = walkPrefix(context.Background(), &cryptProvider{provider: m, m: testCryptMaster(t)}, "dir/", func(data []File) error {
+		for _, f := range data {
+			names = append(names, f.Name)
+		}
+		return nil
+	})
+	if err != nil || len(names) != 2 {
+		t.Errorf("walkPrefix(crypt mem) = %v, %v", names, err)
+	}
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	previewNone = iota
+	previewText
+	previewJSON
+	previewYAML
+	previewCSV
+	previewLog
+	previewImage
+)
+
+const (
+	previewMaxBytes      = 256 * 1024
+	previewTailBytes     = 64 * 1024
+	previewMaxImageBytes = 8 * 1024 * 1024
+	previewMaxRows       = 500
+)
+
+// errRangeDone stops a full Download once the requested range has been read
+var errRangeDone = errors.New("range done")
+
+// rangeWriter discards skip bytes, then keeps at most remain bytes
+type rangeWriter struct {
+	buf    bytes.Buffer
+	skip   int64
+	remain int64
+}
+
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.skip > 0 {
+		if int64(len(p)) <= w.skip {
+			w.skip -= int64(len(p))
+			return n, nil
+		}
+		p = p[w.skip:]
+		w.skip = 0
+	}
+	if int64(len(p)) > w.remain {
+		p = p[:w.remain]
+	}
+	w.buf.Write(p)
+	w.remain -= int64(len(p))
+	if w.remain <= 0 {
+		return n, errRangeDone
+	}
+	return n, nil
+}
+
+// readRange reads length bytes at offset of key, using a ranged read if the
+// provider supports it.
+func readRange(ctx context.Context, c provider, key string, offset, length int64) ([]byte, error) {
+	if rd, ok := c.(rangeDownloader); ok {
+		var buf bytes.Buffer
+		err := rd.DownloadRange(ctx, &buf, key, offset, length)
+		return buf.Bytes(), err
+	}
+
+	w := &rangeWriter{skip: offset, remain: length}
+	err := c.Download(ctx, w, key)
+	if errors.Is(err, errRangeDone) {
+		err = nil
+	}
+	return w.buf.Bytes(), err
+}
+
+// detectPreviewKind guesses how to preview a file from its name and content
+// type, gzipped reports a trailing .gz that must be decompressed first.
+func detectPreviewKind(name, contentType string) (kind int, gzipped bool) {
+	name = strings.ToLower(path.Base(name))
+	if strings.HasSuffix(name, ".gz") {
+		gzipped = true
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	switch path.Ext(name) {
+	case ".json":
+		return previewJSON, gzipped
+	case ".yaml", ".yml":
+		return previewYAML, gzipped
+	case ".csv", ".tsv":
+		return previewCSV, gzipped
+	case ".log", ".out":
+		return previewLog, gzipped
+	case ".png", ".jpg", ".jpeg", ".gif", ".bmp", ".svg":
+		if gzipped {
+			return previewNone, gzipped
+		}
+		return previewImage, gzipped
+	case ".txt", ".md", ".conf", ".cfg", ".ini", ".toml", ".xml", ".html", ".css", ".js",
+		".sh", ".go", ".py", ".c", ".h", ".java", ".properties", ".env":
+		return previewText, gzipped
+	}
+	if strings.Contains(name, ".log.") {
+		return previewLog, gzipped
+	}
+
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		return previewJSON, gzipped
+	case strings.HasPrefix(contentType, "image/"):
+		return previewImage, gzipped
+	case strings.HasPrefix(contentType, "text/csv"):
+		return previewCSV, gzipped
+	case strings.HasPrefix(contentType, "text/"):
+		return previewText, gzipped
+	}
+
+	return previewNone, gzipped
+}
+
+// previewRange returns the byte range to fetch for a preview. Plain logs are
+// tailed, everything else is read from the start and capped.
+func previewRange(kind int, gzipped bool, size int64) (offset, length int64) {
+	if kind == previewImage {
+		return 0, size
+	}
+	if kind == previewLog && !gzipped && size > previewTailBytes {
+		return size - previewTailBytes, previewTailBytes
+	}
+	if size > previewMaxBytes {
+		return 0, previewMaxBytes
+	}
+	return 0, size
+}
+
+type previewData struct {
+	kind      int
+	name      string
+	data      []byte
+	truncated bool
+}
+
+// loadPreview fetches and decodes at most previewMaxBytes of key
+func loadPreview(ctx context.Context, c provider, key string, size int64, kind int, gzipped bool) (*previewData, error) {
+	if kind == previewImage && size > previewMaxImageBytes {
+		return nil, fmt.Errorf("image too large to preview (%s)", bytefmt.ByteSize(uint64(size)))
+	}
+	offset, length := previewRange(kind, gzipped, size)
+	data, err := readRange(ctx, c, key, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &previewData{
+		kind:      kind,
+		name:      key,
+		truncated: offset+length < size,
+	}
+	if gzipped {
+		data, p.truncated, err = gunzipPreview(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if kind == previewImage {
+		p.data = data
+		return p, nil
+	}
+	if offset > 0 {
+		// drop the partial first line of a tailed log
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+		p.truncated = true
+	}
+	p.data = bytes.ToValidUTF8(data, []byte("�"))
+
+	return p, nil
+}
+
+// gunzipPreview decompresses at most previewMaxBytes of a possibly truncated
+// gzip stream
+func gunzipPreview(data []byte) ([]byte, bool, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("gzip error %w", err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(io.LimitReader(zr, previewMaxBytes+1))
+	truncated := false
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		truncated = true
+		err = nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("gzip error %w", err)
+	}
+	if len(out) > previewMaxBytes {
+		out = out[:previewMaxBytes]
+		truncated = true
+	}
+	return out, truncated, nil
+}
+
+func isBinary(data []byte) bool {
+	if len(data) > 512 {
+		data = data[:512]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+func textSegment(text string, color fyne.ThemeColorName) *widget.TextSegment {
+	return &widget.TextSegment{
+		Text: text,
+		Style: widget.RichTextStyle{
+			ColorName: color,
+			Inline:    true,
+			TextStyle: fyne.TextStyle{Monospace: true},
+		},
+	}
+}
+
+// highlightJSON splits src into colored segments: keys, strings, and
+// numbers/literals
+func highlightJSON(src string) []widget.RichTextSegment {
+	var segs []widget.RichTextSegment
+	plain := 0
+	flush := func(end int) {
+		if end > plain {
+			segs = append(segs, textSegment(src[plain:end], theme.ColorNameForeground))
+		}
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(src) {
+				j++
+			}
+			if j > len(src) {
+				j = len(src)
+			}
+			color := theme.ColorNameSuccess
+			k := j
+			for k < len(src) && (src[k] == ' ' || src[k] == '\t') {
+				k++
+			}
+			if k < len(src) && src[k] == ':' {
+				color = theme.ColorNamePrimary
+			}
+			flush(i)
+			segs = append(segs, textSegment(src[i:j], color))
+			i, plain = j, j
+		case c == '-' || (c >= '0' && c <= '9') || c == 't' || c == 'f' || c == 'n':
+			j := i
+			for j < len(src) && strings.IndexByte(",:]}\n\r\t ", src[j]) < 0 {
+				j++
+			}
+			flush(i)
+			segs = append(segs, textSegment(src[i:j], theme.ColorNameWarning))
+			i, plain = j, j
+		default:
+			i++
+		}
+	}
+	flush(len(src))
+	return segs
+}
+
+// highlightYAML colors comments and mapping keys line by line
+func highlightYAML(src string) []widget.RichTextSegment {
+	var segs []widget.RichTextSegment
+	for _, line := range strings.SplitAfter(src, "\n") {
+		trimmed := strings.TrimLeft(line, " \t-")
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			segs = append(segs, textSegment(line, theme.ColorNameDisabled))
+		case strings.Contains(trimmed, ":") && !strings.HasPrefix(trimmed, "\"") && !strings.HasPrefix(trimmed, "'"):
+			i := len(line) - len(trimmed) + strings.Index(trimmed, ":")
+			segs = append(segs,
+				textSegment(line[:i], theme.ColorNamePrimary),
+				textSegment(line[i:], theme.ColorNameForeground),
+			)
+		case line != "":
+			segs = append(segs, textSegment(line, theme.ColorNameForeground))
+		}
+	}
+	return segs
+}
+
+// parseCSVPreview parses at most previewMaxRows records, tolerating ragged
+// and truncated rows
+func parseCSVPreview(data []byte, tsv bool) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if tsv {
+		r.Comma = '\t'
+	}
+	var rows [][]string
+	for len(rows) < previewMaxRows {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if len(rows) > 0 {
+				break
+			}
+			return nil, err
+		}
+		rows = append(rows, rec)
+	}
+	return rows, nil
+}
+
+func (p *previewData) render() fyne.CanvasObject {
+	if p.kind == previewImage {
+		img := canvas.NewImageFromReader(bytes.NewReader(p.data), path.Base(p.name))
+		img.FillMode = canvas.ImageFillContain
+		return img
+	}
+	if isBinary(p.data) {
+		return widget.NewLabel("Binary content, no preview")
+	}
+
+	text := string(p.data)
+	switch p.kind {
+	case previewJSON:
+		var out bytes.Buffer
+		if !p.truncated && json.Indent(&out, p.data, "", "  ") == nil {
+			text = out.String()
+		}
+		return container.NewScroll(widget.NewRichText(highlightJSON(text)...))
+	case previewYAML:
+		return container.NewScroll(widget.NewRichText(highlightYAML(text)...))
+	case previewCSV:
+		rows, err := parseCSVPreview(p.data, strings.HasSuffix(strings.TrimSuffix(p.name, ".gz"), ".tsv"))
+		if err == nil && len(rows) > 0 {
+			return csvTable(rows)
+		}
+	case previewLog:
+		s := container.NewScroll(widget.NewRichText(textSegment(text, theme.ColorNameForeground)))
+		s.ScrollToBottom()
+		return s
+	}
+
+	return container.NewScroll(widget.NewRichText(textSegment(text, theme.ColorNameForeground)))
+}
+
+func csvTable(rows [][]string) *widget.Table {
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len(r))
+	}
+	t := widget.NewTable(
+		func() (int, int) {
+			return len(rows), cols
+		},
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			l := o.(*widget.Label)
+			l.TextStyle.Bold = id.Row == 0
+			if id.Col < len(rows[id.Row]) {
+				l.SetText(rows[id.Row][id.Col])
+			} else {
+				l.SetText("")
+			}
+		},
+	)
+	for i := 0; i < cols; i++ {
+		t.SetColumnWidth(i, 120)
+	}
+	return t
+}
+
+func (sc *Fone) makePreview() {
+	sc.previewTitle = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	sc.previewTitle.Truncation = fyne.TextTruncateEllipsis
+	sc.previewBody = container.NewStack()
+	sc.preview = container.NewBorder(sc.previewTitle, nil, nil, nil, sc.previewBody)
+}
+
+func (sc *Fone) setPreview(title string, o fyne.CanvasObject) {
+	sc.previewTitle.SetText(title)
+	if o == nil {
+		sc.previewBody.RemoveAll()
+		return
+	}
+	sc.previewBody.Objects = []fyne.CanvasObject{o}
+	sc.previewBody.Refresh()
+}
+
+// showPreview loads f into the preview pane, cancelling any preview still
+// loading
+func (sc *Fone) showPreview(f File) {
+	if sc.preview == nil {
+		return
+	}
+	if sc.previewCancel != nil {
+		sc.previewCancel()
+		sc.previewCancel = nil
+	}
+	if f.Name == "" || f.IsDir() {
+		sc.setPreview("", nil)
+		return
+	}
+
+	title := path.Base(f.Name)
+	kind, gzipped := detectPreviewKind(f.Name, f.ContentType)
+	if kind == previewNone {
+		sc.setPreview(title, widget.NewLabel("No preview available"))
+		return
+	}
+	if f.Size == 0 {
+		sc.setPreview(title, widget.NewLabel("Empty file"))
+		return
+	}
+
+	key := sc.pathLabel.Text + f.Name
+	ctx, cancel := context.WithCancel(context.Background())
+	sc.previewCancel = cancel
+	sc.setPreview(title, widget.NewProgressBarInfinite())
+	go func() {
+		p, err := loadPreview(ctx, sc.client, key, f.Size, kind, gzipped)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("preview failed",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+			sc.setPreview(title, widget.NewLabel("Error: "+err.Error()))
+			return
+		}
+		if p.truncated {
+			title += " (partial)"
+		}
+		sc.setPreview(title, p.render())
+	}()
+}
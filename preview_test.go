@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// memProvider is an in-memory provider for testing
+type memProvider struct {
+	files map[string][]byte
+}
+
+func (m *memProvider) List(ctx context.Context, prefix, marker string) (data []File, nextMarker string, err error) {
+	dirs := map[string]bool{}
+	for k, v := range m.files {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(k, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			if !dirs[rest[:i+1]] {
+				dirs[rest[:i+1]] = true
+				data = append(data, File{Name: rest[:i+1], Type: FileDir})
+			}
+			continue
+		}
+		data = append(data, File{Name: rest, Size: int64(len(v))})
+	}
+	return
+}
+
+func (m *memProvider) Upload(ctx context.Context, rs io.ReadSeeker, key, contentType string) (err error) {
+	data, err := io.ReadAll(rs)
+	if err != nil {
+		return
+	}
+	if m.files == nil {
+		m.files = map[string][]byte{}
+	}
+	m.files[key] = data
+	return
+}
+
+func (m *memProvider) Download(ctx context.Context, w io.Writer, key string) (err error) {
+	data, ok := m.files[key]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	// write in small chunks like a network stream
+	for len(data) > 0 {
+		n := min(len(data), 7)
+		if _, err = w.Write(data[:n]); err != nil {
+			return
+		}
+		data = data[n:]
+	}
+	return
+}
+
+func (m *memProvider) Delete(ctx context.Context, key string) (err error) {
+	delete(m.files, key)
+	return
+}
+
+func (m *memProvider) Stat(ctx context.Context, key string) (f File, err error) {
+	data, ok := m.files[key]
+	if !ok {
+		return f, fs.ErrNotExist
+	}
+	f.Name = key
+	f.Size = int64(len(data))
+	return
+}
+
+func (m *memProvider) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestDetectPreviewKind(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		wantKind    int
+		wantGzip    bool
+	}{
+		{name: "a/config.json", wantKind: previewJSON},
+		{name: "values.YML", wantKind: previewYAML},
+		{name: "data.csv", wantKind: previewCSV},
+		{name: "app.log", wantKind: previewLog},
+		{name: "app.log.1", wantKind: previewLog},
+		{name: "app.log.gz", wantKind: previewLog, wantGzip: true},
+		{name: "photo.jpg", wantKind: previewImage},
+		{name: "photo.jpg.gz", wantKind: previewNone, wantGzip: true},
+		{name: "README.md", wantKind: previewText},
+		{name: "blob", contentType: "application/json; charset=utf-8", wantKind: previewJSON},
+		{name: "blob", contentType: "text/plain", wantKind: previewText},
+		{name: "blob.bin", wantKind: previewNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, gzipped := detectPreviewKind(tt.name, tt.contentType)
+			if kind != tt.wantKind || gzipped != tt.wantGzip {
+				t.Errorf("detectPreviewKind() = %v, %v, want %v, %v", kind, gzipped, tt.wantKind, tt.wantGzip)
+			}
+		})
+	}
+}
+
+func TestPreviewRange(t *testing.T) {
+	offset, length := previewRange(previewLog, false, 10*previewTailBytes)
+	if offset != 9*previewTailBytes || length != previewTailBytes {
+		t.Errorf("previewRange(log) = %v, %v", offset, length)
+	}
+	offset, length = previewRange(previewText, false, 10*previewMaxBytes)
+	if offset != 0 || length != previewMaxBytes {
+		t.Errorf("previewRange(text) = %v, %v", offset, length)
+	}
+	offset, length = previewRange(previewJSON, false, 100)
+	if offset != 0 || length != 100 {
+		t.Errorf("previewRange(small) = %v, %v", offset, length)
+	}
+}
+
+func TestReadRangeFallback(t *testing.T) {
+	m := &memProvider{files: map[string][]byte{"k": []byte("0123456789abcdefghij")}}
+	data, err := readRange(context.Background(), m, "k", 5, 8)
+	if err != nil {
+		t.Fatalf("readRange() error = %v", err)
+	}
+	if string(data) != "56789abc" {
+		t.Errorf("readRange() = %q, want %q", data, "56789abc")
+	}
+}
+
+func TestLoadPreviewTailLog(t *testing.T) {
+	var log bytes.Buffer
+	for log.Len() < 2*previewTailBytes {
+		log.WriteString("2024-01-15 10:30:00 INFO something happened\n")
+	}
+	log.WriteString("last line\n")
+	m := &memProvider{files: map[string][]byte{"app.log": log.Bytes()}}
+
+	p, err := loadPreview(context.Background(), m, "app.log", int64(log.Len()), previewLog, false)
+	if err != nil {
+		t.Fatalf("loadPreview() error = %v", err)
+	}
+	if !p.truncated {
+		t.Errorf("loadPreview() truncated = false, want true")
+	}
+	if !strings.HasPrefix(string(p.data), "2024-01-15") {
+		t.Errorf("loadPreview() should start at a full line, got %q", p.data[:20])
+	}
+	if !strings.HasSuffix(string(p.data), "last line\n") {
+		t.Errorf("loadPreview() should end with the last line")
+	}
+}
+
+func TestLoadPreviewGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"a":1}`))
+	zw.Close()
+	m := &memProvider{files: map[string][]byte{"a.json.gz": buf.Bytes()}}
+
+	p, err := loadPreview(context.Background(), m, "a.json.gz", int64(buf.Len()), previewJSON, true)
+	if err != nil {
+		t.Fatalf("loadPreview() error = %v", err)
+	}
+	if string(p.data) != `{"a":1}` {
+		t.Errorf("loadPreview() = %q, want %q", p.data, `{"a":1}`)
+	}
+	if p.truncated {
+		t.Errorf("loadPreview() truncated = true, want false")
+	}
+}
+
+func TestHighlightJSON(t *testing.T) {
+	src := "{\n  \"name\": \"fone\",\n  \"size\": -12.5,\n  \"ok\": true\n}"
+	segs := highlightJSON(src)
+	var got strings.Builder
+	for _, s := range segs {
+		got.WriteString(s.Textual())
+	}
+	if got.String() != src {
+		t.Errorf("highlightJSON() text = %q, want %q", got.String(), src)
+	}
+}
+
+func TestParseCSVPreview(t *testing.T) {
+	rows, err := parseCSVPreview([]byte("a,b,c\n1,2\n3,4,5,6\n\"trunc"), false)
+	if err != nil {
+		t.Fatalf("parseCSVPreview() error = %v", err)
+	}
+	if len(rows) < 3 {
+		t.Errorf("parseCSVPreview() rows = %v, want at least 3", len(rows))
+	}
+	if rows[0][1] != "b" {
+		t.Errorf("parseCSVPreview() header = %v", rows[0])
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	profilesKey = "profiles"
+
+	authPassword = "password"
+	authKey      = "key"
+)
+
+// connConfig holds everything needed to open a provider
+type connConfig struct {
+	Type     string `json:"type" toml:"type"`
+	Endpoint string `json:"endpoint,omitempty" toml:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty" toml:"region,omitempty"`
+	Bucket   string `json:"bucket,omitempty" toml:"bucket,omitempty"`
+	// Addressing is the S3 addressing mode, auto, path or virtual
+	Addressing string `json:"addressing,omitempty" toml:"addressing,omitempty"`
+	AccessKey  string `json:"access_key,omitempty" toml:"access_key,omitempty"`
+	SecretKey  string `json:"secret_key,omitempty" toml:"secret_key,omitempty"`
+	Server     string `json:"server,omitempty" toml:"server,omitempty"`
+	User       string `json:"user,omitempty" toml:"user,omitempty"`
+	Auth       string `json:"auth,omitempty" toml:"auth,omitempty"`
+	Password   string `json:"password,omitempty" toml:"password,omitempty"`
+	KeyFile    string `json:"key_file,omitempty" toml:"key_file,omitempty"`
+	Dir        string `json:"dir,omitempty" toml:"dir,omitempty"`
+	// S3 tuning, timeouts are in seconds and 0 keeps the default
+	MaxAttempts    int `json:"max_attempts,omitempty" toml:"max_attempts,omitempty"`
+	ConnectTimeout int `json:"connect_timeout,omitempty" toml:"connect_timeout,omitempty"`
+	ReadTimeout    int `json:"read_timeout,omitempty" toml:"read_timeout,omitempty"`
+	MaxConns       int `json:"max_conns,omitempty" toml:"max_conns,omitempty"`
+	// S3 TLS, Pins are base64 SHA-256 hashes of trusted public keys
+	CAFile     string   `json:"ca_file,omitempty" toml:"ca_file,omitempty"`
+	ClientCert string   `json:"client_cert,omitempty" toml:"client_cert,omitempty"`
+	ClientKey  string   `json:"client_key,omitempty" toml:"client_key,omitempty"`
+	MinTLS     string   `json:"min_tls,omitempty" toml:"min_tls,omitempty"`
+	Pins       []string `json:"pins,omitempty" toml:"pins,omitempty"`
+	SkipVerify bool     `json:"skip_verify,omitempty" toml:"skip_verify,omitempty"`
+	// Proxy is an http, https or socks5 URL, empty uses the environment for
+	// S3 and direct for sftp, "direct" never uses a proxy
+	Proxy         string `json:"proxy,omitempty" toml:"proxy,omitempty"`
+	ProxyUser     string `json:"proxy_user,omitempty" toml:"proxy_user,omitempty"`
+	ProxyPassword string `json:"proxy_password,omitempty" toml:"proxy_password,omitempty"`
+	// SSE is the S3 server-side encryption of uploads, AES256, aws:kms or
+	// SSE-C, empty for the bucket default
+	SSE          string `json:"sse,omitempty" toml:"sse,omitempty"`
+	SSEKMSKeyID  string `json:"sse_kms_key_id,omitempty" toml:"sse_kms_key_id,omitempty"`
+	SSEBucketKey bool   `json:"sse_bucket_key,omitempty" toml:"sse_bucket_key,omitempty"`
+	SSECKeyFile  string `json:"sse_c_key_file,omitempty" toml:"sse_c_key_file,omitempty"`
+	// client-side encryption with a passphrase or a key file, on for
+	// either of them
+	CryptPassphrase string `json:"crypt_passphrase,omitempty" toml:"crypt_passphrase,omitempty"`
+	CryptKeyFile    string `json:"crypt_key_file,omitempty" toml:"crypt_key_file,omitempty"`
+	CryptPlainSizes bool   `json:"crypt_plain_sizes,omitempty" toml:"crypt_plain_sizes,omitempty"`
+	// Checksum is md5, crc32c or sha256 for S3 uploads, sftp always uses
+	// sha256, empty is the default and off skips verifying transfers
+	Checksum string `json:"checksum,omitempty" toml:"checksum,omitempty"`
+}
+
+// secrets returns the secret fields of cfg by vault field name
+func (cfg *connConfig) secrets() map[string]*string {
+	return map[string]*string{
+		"secret_key":       &cfg.SecretKey,
+		"password":         &cfg.Password,
+		"proxy_password":   &cfg.ProxyPassword,
+		"crypt_passphrase": &cfg.CryptPassphrase,
+	}
+}
+
+// open connects to the configured server, pwd is the sftp working
+// directory and empty for S3
+func (cfg *connConfig) open() (c provider, pwd string, err error) {
+	if cfg.CryptPassphrase == "" && cfg.CryptKeyFile == "" {
+		return cfg.dial()
+	}
+	m, err := newCryptMaster(cfg.CryptPassphrase, cfg.CryptKeyFile)
+	if err != nil {
+		return nil, "", err
+	}
+	if c, pwd, err = cfg.dial(); err != nil {
+		return
+	}
+	return &cryptProvider{provider: c, m: m, plainSizes: cfg.CryptPlainSizes}, pwd, nil
+}
+
+// dial opens the provider of cfg without client-side encryption
+func (cfg *connConfig) dial() (c provider, pwd string, err error) {
+	switch cfg.Type {
+	case "s3":
+		if cfg.Bucket == "" {
+			return nil, "", errors.New("s3 bucket required")
+		}
+		switch cfg.Addressing {
+		case "", addressingAuto, addressingPath, addressingVirtual:
+		default:
+			return nil, "", fmt.Errorf("unknown s3 addressing %q", cfg.Addressing)
+		}
+		if !validChecksum(cfg.Checksum) {
+			return nil, "", fmt.Errorf("unknown checksum %q", cfg.Checksum)
+		}
+		bucketName, keyPrefix := splitKeyValue(cfg.Bucket, "/")
+		opt, err := cfg.s3Options()
+		if err != nil {
+			return nil, "", err
+		}
+		s3c := NewClientWithOptions(cfg.AccessKey, cfg.SecretKey, cfg.Region, cfg.Endpoint, opt)
+		s3c.Bucket = bucketName
+		s3c.Prefix = keyPrefix
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s3c.DetectRegion(ctx); err != nil {
+			slog.Debug("s3 detect region failed",
+				slog.String("bucket", bucketName),
+				slog.String("error", err.Error()),
+			)
+		}
+		return s3c, "", nil
+	case "sftp":
+		if cfg.Server == "" {
+			return nil, "", errors.New("sftp server required")
+		}
+		auth := passwordAuth(cfg.Password)
+		if cfg.Auth == authKey {
+			key, err := keyFileAuth(cfg.KeyFile, cfg.Password)
+			if err != nil {
+				return nil, "", err
+			}
+			auth = []ssh.AuthMethod{key}
+		}
+		proxyURL, err := cfg.proxyURL()
+		if err != nil {
+			return nil, "", err
+		}
+		dial, err := proxyDialer(proxyURL, sftpDialTimeout)
+		if err != nil {
+			return nil, "", err
+		}
+		sftpc, pwd, err := NewSftpClientWithDialer(cfg.Server, cfg.User, cfg.Dir, dial, auth...)
+		if err != nil {
+			return nil, "", err
+		}
+		sftpc.SetVerify(cfg.Checksum != checksumOff)
+		if !strings.HasSuffix(pwd, "/") {
+			pwd += "/"
+		}
+		return sftpc, pwd, nil
+	}
+	return nil, "", fmt.Errorf("unknown connection type %q", cfg.Type)
+}
+
+func (cfg *connConfig) s3Options() (S3Options, error) {
+	tc, err := cfg.tlsConfig()
+	if err != nil {
+		return S3Options{}, err
+	}
+	proxyURL, err := cfg.proxyURL()
+	if err != nil {
+		return S3Options{}, err
+	}
+	enc, err := cfg.encryption()
+	if err != nil {
+		return S3Options{}, err
+	}
+	return S3Options{
+		Encryption:     enc,
+		TLS:            tc,
+		Proxy:          proxyURL,
+		NoProxy:        cfg.Proxy == proxyDirect,
+		Addressing:     cfg.Addressing,
+		MaxAttempts:    cfg.MaxAttempts,
+		ConnectTimeout: time.Duration(cfg.ConnectTimeout) * time.Second,
+		ReadTimeout:    time.Duration(cfg.ReadTimeout) * time.Second,
+		MaxConns:       cfg.MaxConns,
+		Checksum:       cfg.Checksum,
+	}, nil
+}
+
+// address returns the endpoint and bucket of S3 or the sftp server, for
+// logging and display
+func (cfg *connConfig) address() string {
+	if cfg.Type == "sftp" {
+		return cfg.Server
+	}
+	return strings.TrimSuffix(cfg.Endpoint, "/") + "/" + cfg.Bucket
+}
+
+// user returns the login name of either connection type
+func (cfg *connConfig) user() string {
+	if cfg.Type == "sftp" {
+		return cfg.User
+	}
+	return cfg.AccessKey
+}
+
+// Profile is a named connection saved in the site manager
+type Profile struct {
+	Name   string   `json:"name" toml:"name"`
+	Folder string   `json:"folder,omitempty" toml:"folder,omitempty"`
+	Tags   []string `json:"tags,omitempty" toml:"tags,omitempty"`
+	// Forget asks for the secret on every connect instead of saving it
+	Forget bool `json:"forget,omitempty" toml:"forget,omitempty"`
+	connConfig
+}
+
+type profileFile struct {
+	Profiles []Profile `json:"profiles" toml:"profiles"`
+}
+
+// withoutSecrets returns a copy of p with passwords and keys cleared
+func (p Profile) withoutSecrets() Profile {
+	for _, v := range p.secrets() {
+		*v = ""
+	}
+	p.Tags = slices.Clone(p.Tags)
+	return p
+}
+
+// cloneName returns a name for a copy of name not used in profiles
+func cloneName(profiles []Profile, name string) string {
+	for i := 1; ; i++ {
+		n := fmt.Sprintf("%s (copy %d)", name, i)
+		if i == 1 {
+			n = name + " (copy)"
+		}
+		if findProfile(profiles, n) == nil {
+			return n
+		}
+	}
+}
+
+func findProfile(profiles []Profile, name string) *Profile {
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i]
+		}
+	}
+	return nil
+}
+
+// mergeProfiles adds or replaces profiles by name, keeping the secrets of
+// a replaced profile if the imported one has none
+func mergeProfiles(profiles, in []Profile) []Profile {
+	for _, p := range in {
+		old := findProfile(profiles, p.Name)
+		if old == nil {
+			profiles = append(profiles, p)
+			continue
+		}
+		oldSecrets := old.secrets()
+		for field, v := range p.secrets() {
+			if *v == "" {
+				*v = *oldSecrets[field]
+			}
+		}
+		*old = p
+	}
+	sortProfiles(profiles)
+	return profiles
+}
+
+func sortProfiles(profiles []Profile) {
+	sort.SliceStable(profiles, func(i, j int) bool {
+		if profiles[i].Folder != profiles[j].Folder {
+			return profiles[i].Folder < profiles[j].Folder
+		}
+		return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name)
+	})
+}
+
+// profileFolders returns the sorted folder names used by profiles
+func profileFolders(profiles []Profile) []string {
+	var folders []string
+	for _, p := range profiles {
+		if !slices.Contains(folders, p.Folder) {
+			folders = append(folders, p.Folder)
+		}
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+// parseTags splits a comma separated tag list
+func parseTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func validateProfiles(profiles []Profile) error {
+	seen := map[string]bool{}
+	for _, p := range profiles {
+		if p.Name == "" {
+			return errors.New("profile name required")
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate profile %q", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Type != "s3" && p.Type != "sftp" {
+			return fmt.Errorf("profile %q: unknown type %q", p.Name, p.Type)
+		}
+	}
+	return nil
+}
+
+// decodeProfiles parses a profile file, as TOML if isTOML is set and JSON
+// otherwise
+func decodeProfiles(data []byte, isTOML bool) ([]Profile, error) {
+	var pf profileFile
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var err error
+	if isTOML {
+		_, err = toml.Decode(string(data), &pf)
+	} else {
+		err = json.Unmarshal(data, &pf)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode profiles error %w", err)
+	}
+	if err = validateProfiles(pf.Profiles); err != nil {
+		return nil, err
+	}
+	return pf.Profiles, nil
+}
+
+// encodeProfiles writes profiles as TOML or JSON
+func encodeProfiles(w io.Writer, profiles []Profile, isTOML bool) error {
+	pf := profileFile{Profiles: profiles}
+	if isTOML {
+		return toml.NewEncoder(w).Encode(pf)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pf)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testProfiles() []Profile {
+	return []Profile{
+		{
+			Name:   "minio",
+			Folder: "lab",
+			Tags:   []string{"dev"},
+			connConfig: connConfig{
+				Type:      "s3",
+				Endpoint:  "http://192.168.0.8:9000",
+				Bucket:    "data/logs",
+				AccessKey: "ak",
+				SecretKey: "sk",
+			},
+		},
+		{
+			Name: "web",
+			connConfig: connConfig{
+				Type:     "sftp",
+				Server:   "192.168.0.9:22",
+				User:     "root",
+				Auth:     authKey,
+				KeyFile:  "/root/.ssh/id_ed25519",
+				Password: "passphrase",
+			},
+		},
+	}
+}
+
+func TestEncodeDecodeProfiles(t *testing.T) {
+	for _, isTOML := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := encodeProfiles(&buf, testProfiles(), isTOML); err != nil {
+			t.Fatalf("encodeProfiles(toml=%v) error = %v", isTOML, err)
+		}
+		got, err := decodeProfiles(buf.Bytes(), isTOML)
+		if err != nil {
+			t.Fatalf("decodeProfiles(toml=%v) error = %v", isTOML, err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("decodeProfiles(toml=%v) len = %v, want 2", isTOML, len(got))
+		}
+		if got[0].Bucket != "data/logs" || got[0].Tags[0] != "dev" || got[0].Folder != "lab" {
+			t.Errorf("decodeProfiles(toml=%v) [0] = %+v", isTOML, got[0])
+		}
+		if got[1].KeyFile != "/root/.ssh/id_ed25519" || got[1].Auth != authKey {
+			t.Errorf("decodeProfiles(toml=%v) [1] = %+v", isTOML, got[1])
+		}
+	}
+}
+
+func TestDecodeProfilesInvalid(t *testing.T) {
+	if p, err := decodeProfiles(nil, false); err != nil || p != nil {
+		t.Errorf("decodeProfiles(empty) = %v, %v", p, err)
+	}
+	dup := `{"profiles":[{"name":"a","type":"s3"},{"name":"a","type":"s3"}]}`
+	if _, err := decodeProfiles([]byte(dup), false); err == nil {
+		t.Errorf("decodeProfiles(duplicate) error = nil")
+	}
+	bad := `{"profiles":[{"name":"a","type":"ftp"}]}`
+	if _, err := decodeProfiles([]byte(bad), false); err == nil {
+		t.Errorf("decodeProfiles(unknown type) error = nil")
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	profiles := testProfiles()
+	in := []Profile{
+		testProfiles()[0].withoutSecrets(),
+		{Name: "new", connConfig: connConfig{Type: "s3"}},
+	}
+	in[0].Region = "us-east-1"
+	got := mergeProfiles(profiles, in)
+	if len(got) != 3 {
+		t.Fatalf("mergeProfiles() len = %v, want 3", len(got))
+	}
+	p := findProfile(got, "minio")
+	if p.Region != "us-east-1" {
+		t.Errorf("mergeProfiles() region = %v, want us-east-1", p.Region)
+	}
+	if p.SecretKey != "sk" {
+		t.Errorf("mergeProfiles() should keep the existing secret")
+	}
+}
+
+func TestWithoutSecrets(t *testing.T) {
+	for _, p := range testProfiles() {
+		got := p.withoutSecrets()
+		if got.SecretKey != "" || got.Password != "" {
+			t.Errorf("withoutSecrets(%v) kept secrets", p.Name)
+		}
+	}
+}
+
+func TestCloneName(t *testing.T) {
+	profiles := testProfiles()
+	if got := cloneName(profiles, "web"); got != "web (copy)" {
+		t.Errorf("cloneName() = %v, want web (copy)", got)
+	}
+	profiles = append(profiles, Profile{Name: "web (copy)"})
+	if got := cloneName(profiles, "web"); got != "web (copy 2)" {
+		t.Errorf("cloneName() = %v, want web (copy 2)", got)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	got := parseTags(" prod, backup,,prod ")
+	if strings.Join(got, "|") != "prod|backup" {
+		t.Errorf("parseTags() = %v, want [prod backup]", got)
+	}
+}
+
+func TestProfileFolders(t *testing.T) {
+	got := profileFolders(testProfiles())
+	if strings.Join(got, "|") != "|lab" {
+		t.Errorf("profileFolders() = %q", got)
+	}
+}
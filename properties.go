@@ -0,0 +1,460 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// followLinksKey is the preference to open links to directories instead of
+// showing their properties
+const followLinksKey = "view.follow_links"
+
+// specialModes are kept as they are when the permission grid is applied
+const specialModes = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// permGrid edits the rwx bits with one check per class and permission
+type permGrid struct {
+	checks  [9]*widget.Check
+	octal   *widget.Label
+	special os.FileMode
+}
+
+func newPermGrid(mode os.FileMode) *permGrid {
+	g := &permGrid{
+		octal:   widget.NewLabel(""),
+		special: mode & specialModes,
+	}
+	for i := range g.checks {
+		g.checks[i] = widget.NewCheck("", func(bool) {
+			g.octal.SetText(fmt.Sprintf("%04o", g.mode().Perm()))
+		})
+	}
+	g.set(mode)
+	return g
+}
+
+func (g *permGrid) set(mode os.FileMode) {
+	for i, c := range g.checks {
+		c.SetChecked(mode&(1<<(8-i)) != 0)
+	}
+	g.octal.SetText(fmt.Sprintf("%04o", mode.Perm()))
+}
+
+func (g *permGrid) mode() (mode os.FileMode) {
+	for i, c := range g.checks {
+		if c.Checked {
+			mode |= 1 << (8 - i)
+		}
+	}
+	return mode | g.special
+}
+
+func (g *permGrid) object() fyne.CanvasObject {
+	objects := []fyne.CanvasObject{
+		widget.NewLabel(""),
+		widget.NewLabel("Read"),
+		widget.NewLabel("Write"),
+		widget.NewLabel("Execute"),
+	}
+	for class, name := range []string{"Owner", "Group", "Other"} {
+		objects = append(objects, widget.NewLabel(name))
+		for bit := range 3 {
+			objects = append(objects, g.checks[class*3+bit])
+		}
+	}
+	return container.NewVBox(container.NewGridWithColumns(4, objects...), g.octal)
+}
+
+// showProperties shows the details of f, with permission and owner editing
+// on providers that support them
+func (sc *Fone) showProperties(f File) {
+	if f.Name == "" {
+		sc.infoLabel.SetText("Warn: No file chosen!")
+		return
+	}
+	key := path.Join(sc.pathLabel.Text, f.Name)
+	if pe, ok := unwrap(sc.client).(propsEditor); ok && !f.IsDir() {
+		sc.showObjectProperties(pe, key, f)
+		return
+	}
+	items := fileItems(key, f)
+	if f.Link != "" {
+		items = append(items, widget.NewFormItem("Link target", widget.NewLabel(f.Link)))
+	}
+
+	editor, canEdit := unwrap(sc.client).(permEditor)
+	if f.Mode == 0 {
+		canEdit = false
+	}
+	var grid *permGrid
+	uid := widget.NewEntry()
+	gid := widget.NewEntry()
+	if f.Mode != 0 {
+		uid.SetText(strconv.Itoa(f.Uid))
+		gid.SetText(strconv.Itoa(f.Gid))
+		uid.Validator = validateID
+		gid.Validator = validateID
+		// chmod would follow the link and change its target
+		if f.IsLink() {
+			items = append(items, widget.NewFormItem("Permissions", widget.NewLabel(cmp.Or(f.LinkMode, f.Mode).String())))
+		} else {
+			grid = newPermGrid(f.Mode)
+			items = append(items, widget.NewFormItem("Permissions", grid.object()))
+		}
+		if !canEdit {
+			uid.Disable()
+			gid.Disable()
+		}
+		items = append(items,
+			widget.NewFormItem("Owner (uid)", uid),
+			widget.NewFormItem("Group (gid)", gid),
+		)
+	}
+
+	if !canEdit {
+		d := dialog.NewCustom("Properties", "Close", widget.NewForm(items...), sc.w)
+		d.Resize(fyne.NewSize(420, 0))
+		d.Show()
+		return
+	}
+	d := dialog.NewForm("Properties", "Apply", "Close", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		ctx := context.Background()
+		if grid != nil && grid.mode() != f.Mode&(os.ModePerm|specialModes) {
+			if err := editor.Chmod(ctx, key, grid.mode()); err != nil {
+				slog.Warn("chmod failed",
+					slog.String("key", key),
+					slog.String("error", err.Error()),
+				)
+				dialog.ShowError(unwrapError(err), sc.w)
+				return
+			}
+			f.Mode = f.Mode&^(os.ModePerm|specialModes) | grid.mode()
+		}
+		u, _ := strconv.Atoi(uid.Text)
+		g, _ := strconv.Atoi(gid.Text)
+		if u != f.Uid || g != f.Gid {
+			if err := editor.Chown(ctx, key, u, g); err != nil {
+				slog.Warn("chown failed",
+					slog.String("key", key),
+					slog.String("error", err.Error()),
+				)
+				dialog.ShowError(unwrapError(err), sc.w)
+				return
+			}
+			f.Uid, f.Gid = u, g
+		}
+		slog.Info("properties success",
+			slog.String("key", key),
+			slog.String("mode", f.Perm()),
+		)
+		sc.body.Set(f)
+		showLabelMsg(sc.infoLabel, f.Info())
+	}, sc.w)
+	d.Resize(fyne.NewSize(420, 0))
+	d.Show()
+}
+
+// fileItems are the properties every provider has
+func fileItems(key string, f File) []*widget.FormItem {
+	modified := ""
+	if !f.Time.IsZero() {
+		modified = f.Time.Local().Format("2006-01-02 15:04:05")
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", widget.NewLabel(strings.TrimSuffix(f.Name, "/"))),
+		widget.NewFormItem("Path", widget.NewLabel(key)),
+		widget.NewFormItem("Type", widget.NewLabel(f.TypeName())),
+		widget.NewFormItem("Modified", widget.NewLabel(modified)),
+	}
+	if !f.IsDir() {
+		items = append(items, widget.NewFormItem("Size", widget.NewLabel(fmt.Sprintf("%s (%d bytes)", bytefmt.ByteSize(uint64(f.Size)), f.Size))))
+	}
+	return items
+}
+
+// showObjectProperties loads the headers, metadata and tags of an object
+// and lets the user replace them
+func (sc *Fone) showObjectProperties(pe propsEditor, key string, f File) {
+	go func() {
+		ctx := context.Background()
+		old, err := pe.Properties(ctx, key)
+		if err != nil {
+			slog.Warn("properties failed",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), sc.w)
+			return
+		}
+		if old.TagsErr != nil {
+			slog.Warn("get tags failed",
+				slog.String("key", key),
+				slog.String("error", old.TagsErr.Error()),
+			)
+		}
+
+		items := fileItems(key, f)
+		sse := old.SSE
+		if old.SSEKeyID != "" {
+			sse += " (" + old.SSEKeyID + ")"
+		}
+		if old.SSECustomer != "" {
+			sse = sseC + " (" + old.SSECustomer + ")"
+		}
+		restore := ""
+		if old.Restore != nil {
+			restore = old.Restore.String()
+		}
+		for _, kv := range [][2]string{
+			{"ETag", old.ETag},
+			{"Version ID", old.VersionID},
+			{"Encryption", sse},
+			{"Restore", restore},
+		} {
+			if kv[1] != "" {
+				items = append(items, widget.NewFormItem(kv[0], widget.NewLabel(kv[1])))
+			}
+		}
+		class := widget.NewSelect(storageClasses, nil)
+		class.SetSelected(old.StorageClass)
+		items = append(items, widget.NewFormItem("Storage class", class))
+
+		headers := []struct {
+			name  string
+			entry *widget.Entry
+			value *string
+		}{
+			{name: "Content-Type"},
+			{name: "Cache-Control"},
+			{name: "Content-Disposition"},
+			{name: "Content-Encoding"},
+			{name: "Content-Language"},
+		}
+		p := old
+		for i, v := range []*string{&p.ContentType, &p.CacheControl, &p.ContentDisposition, &p.ContentEncoding, &p.ContentLanguage} {
+			headers[i].value = v
+			headers[i].entry = widget.NewEntry()
+			headers[i].entry.SetText(*v)
+			items = append(items, widget.NewFormItem(headers[i].name, headers[i].entry))
+		}
+		metadata := widget.NewMultiLineEntry()
+		metadata.SetText(formatKeyValues(old.Metadata))
+		metadata.SetPlaceHolder("key=value, one per line")
+		metadata.SetMinRowsVisible(3)
+		metadata.Validator = func(s string) error {
+			_, err := parseKeyValues(s)
+			return err
+		}
+		tags := widget.NewMultiLineEntry()
+		tags.SetText(formatKeyValues(old.Tags))
+		tags.SetPlaceHolder("key=value, one per line")
+		tags.SetMinRowsVisible(3)
+		if old.TagsErr != nil {
+			tags.SetPlaceHolder("unavailable: " + unwrapError(old.TagsErr).Error())
+			tags.Disable()
+		} else {
+			tags.Validator = metadata.Validator
+		}
+		items = append(items,
+			widget.NewFormItem("Metadata", metadata),
+			widget.NewFormItem("Tags", tags),
+		)
+
+		// the lock fields only show on buckets with object lock or on
+		// objects that still carry a retention
+		le, canLock := unwrap(sc.client).(lockEditor)
+		var readRetention func() (Retention, bool)
+		if canLock {
+			lock, err := le.ObjectLock(ctx)
+			if err != nil {
+				slog.Warn("get object lock failed",
+					slog.String("error", err.Error()),
+				)
+			}
+			if lock.Enabled || old.Retention.Mode != "" || old.Retention.LegalHold {
+				var lockItems []*widget.FormItem
+				lockItems, readRetention = retentionItems(lock, old.Retention)
+				items = append(items, lockItems...)
+			}
+		}
+
+		d := dialog.NewForm("Properties", "Apply", "Close", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			for _, h := range headers {
+				*h.value = strings.TrimSpace(h.entry.Text)
+			}
+			p.StorageClass = class.Selected
+			meta, _ := parseKeyValues(metadata.Text)
+			p.Metadata = map[string]string{}
+			for k, v := range meta {
+				// S3 stores metadata names in lower case
+				p.Metadata[strings.ToLower(k)] = v
+			}
+			if old.TagsErr == nil {
+				p.Tags, _ = parseKeyValues(tags.Text)
+			}
+			var bypass bool
+			if readRetention != nil {
+				p.Retention, bypass = readRetention()
+			}
+			// a copy takes the retention along to the new version, otherwise
+			// it is set on the object as is
+			if err := pe.SetProperties(context.Background(), key, old, p); err != nil {
+				slog.Warn("set properties failed",
+					slog.String("key", key),
+					slog.String("error", err.Error()),
+				)
+				dialog.ShowError(unwrapError(err), sc.w)
+				return
+			}
+			if readRetention != nil && !p.copies(old) {
+				if err := applyRetention(context.Background(), le, key, old.Retention, p.Retention, bypass); err != nil {
+					slog.Warn("set retention failed",
+						slog.String("key", key),
+						slog.String("error", err.Error()),
+					)
+					dialog.ShowError(unwrapError(err), sc.w)
+					return
+				}
+			}
+			slog.Info("set properties success",
+				slog.String("key", key),
+			)
+			f.ContentType = p.ContentType
+			f.StorageClass = p.StorageClass
+			sc.body.Set(f)
+		}, sc.w)
+		d.Resize(fyne.NewSize(480, 0))
+		d.Show()
+	}()
+}
+
+func validateID(s string) error {
+	if n, err := strconv.Atoi(s); err != nil || n < 0 {
+		return errors.New("not a valid id")
+	}
+	return nil
+}
+
+// newSymlink asks for a name and a target and creates the link in the
+// current directory
+func (sc *Fone) newSymlink() {
+	editor, ok := unwrap(sc.client).(permEditor)
+	if !ok {
+		sc.infoLabel.SetText("Warn: links are not supported here!")
+		return
+	}
+	name := widget.NewEntry()
+	name.Validator = func(s string) error {
+		if s == "" || strings.Contains(s, "/") {
+			return errors.New("not a valid name")
+		}
+		return nil
+	}
+	target := widget.NewEntry()
+	target.SetPlaceHolder("../shared or /var/log")
+	if sc.selectFile.Name != "" {
+		target.SetText(strings.TrimSuffix(sc.selectFile.Name, "/"))
+	}
+	d := dialog.NewForm("New symlink", "Create", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Name", name),
+		widget.NewFormItem("Target", target),
+	}, func(ok bool) {
+		if !ok || target.Text == "" {
+			return
+		}
+		ctx := context.Background()
+		key := path.Join(sc.pathLabel.Text, name.Text)
+		if err := editor.Symlink(ctx, target.Text, key); err != nil {
+			slog.Warn("symlink failed",
+				slog.String("key", key),
+				slog.String("target", target.Text),
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), sc.w)
+			return
+		}
+		slog.Info("symlink success",
+			slog.String("key", key),
+			slog.String("target", target.Text),
+		)
+		f, err := sc.client.Stat(ctx, key)
+		if err != nil {
+			f = File{Name: name.Text, Mode: os.ModeSymlink | os.ModePerm, Link: target.Text}
+		}
+		if f.IsDir() {
+			f.Name += "/"
+		}
+		sc.body.Add(f)
+	}, sc.w)
+	d.Resize(fyne.NewSize(400, 0))
+	d.Show()
+}
+
+// restoreObject asks how long and how fast to restore the selected archived
+// object
+func (sc *Fone) restoreObject(f File) {
+	ce, ok := unwrap(sc.client).(classEditor)
+	if !ok || f.Name == "" || f.IsDir() {
+		sc.infoLabel.SetText("Warn: No object chosen to restore!")
+		return
+	}
+	if f.StorageClass != "" && !isArchived(f.StorageClass) {
+		showLabelMsg(sc.infoLabel, "Warn: "+f.StorageClass+" objects need no restore")
+		return
+	}
+	key := path.Join(sc.pathLabel.Text, f.Name)
+	days := widget.NewEntry()
+	days.SetText("7")
+	days.Validator = func(s string) error {
+		if n, err := strconv.Atoi(s); err != nil || n < 1 {
+			return errors.New("not a valid number of days")
+		}
+		return nil
+	}
+	tier := widget.NewSelect(restoreTiers, nil)
+	tier.SetSelected(restoreTiers[0])
+	d := dialog.NewForm("Restore "+path.Base(key), "Restore", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Days", days),
+		widget.NewFormItem("Tier", tier),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		n, _ := strconv.Atoi(days.Text)
+		if err := ce.Restore(context.Background(), key, n, tier.Selected); err != nil {
+			slog.Warn("restore failed",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), sc.w)
+			return
+		}
+		slog.Info("restore requested",
+			slog.String("key", key),
+			slog.Int("days", n),
+			slog.String("tier", tier.Selected),
+		)
+		showLabelMsg(sc.infoLabel, "Restore requested, see Properties for its status")
+	}, sc.w)
+	d.Resize(fyne.NewSize(360, 0))
+	d.Show()
+}
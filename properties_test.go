@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestPermGrid(t *testing.T) {
+	test.NewApp()
+	tests := []os.FileMode{0o644, 0o755, 0o600, 0o777 | os.ModeSticky, 0}
+	for _, mode := range tests {
+		g := newPermGrid(mode)
+		if got := g.mode(); got != mode {
+			t.Errorf("permGrid(%v).mode() = %v", mode, got)
+		}
+	}
+
+	g := newPermGrid(0o644)
+	g.checks[2].SetChecked(true) // owner execute
+	g.checks[8].SetChecked(true) // other execute
+	if got := g.mode(); got != 0o745 {
+		t.Errorf("permGrid.mode() = %o, want 745", got)
+	}
+	if g.octal.Text != "0745" {
+		t.Errorf("permGrid octal = %q, want 0745", g.octal.Text)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/widget"
+	"golang.org/x/net/proxy"
+)
+
+// proxyDirect disables the proxy from the environment
+const proxyDirect = "direct"
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// parseProxy checks a proxy setting, u is nil for the environment default
+// or direct connections
+func parseProxy(s string) (u *url.URL, err error) {
+	if s == "" || s == proxyDirect {
+		return nil, nil
+	}
+	u, err = url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy error %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, use http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %q has no host", s)
+	}
+	return u, nil
+}
+
+// proxyURL returns the proxy of cfg with its credentials
+func (cfg *connConfig) proxyURL() (*url.URL, error) {
+	u, err := parseProxy(cfg.Proxy)
+	if u == nil || err != nil {
+		return u, err
+	}
+	if cfg.ProxyUser != "" {
+		u.User = url.UserPassword(cfg.ProxyUser, cfg.ProxyPassword)
+	}
+	return u, nil
+}
+
+// proxyDialer returns a dialer tunneling through u, or a plain dialer if u
+// is nil
+func proxyDialer(u *url.URL, timeout time.Duration) (dialFunc, error) {
+	direct := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if u == nil {
+		return direct.DialContext, nil
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(u, direct)
+		if err != nil {
+			return nil, fmt.Errorf("socks5 proxy error %w", err)
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, errors.New("socks5 proxy does not support contexts")
+		}
+		return cd.DialContext, nil
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return httpConnect(ctx, direct, u, addr)
+	}, nil
+}
+
+// bufferedConn returns the bytes read ahead while parsing the CONNECT reply
+// before reading from the connection
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// httpConnect opens a tunnel to addr with an HTTP CONNECT request
+func httpConnect(ctx context.Context, d *net.Dialer, u *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("dial proxy %s error %w", proxyAddr, err)
+	}
+	if u.Scheme == "https" {
+		tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err = tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy tls error %w", err)
+		}
+		conn = tc
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy connect error %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy connect error %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy connect %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// proxySettings edits the proxy of a connection
+type proxySettings struct {
+	proxy    *widget.Entry
+	user     *widget.Entry
+	password *widget.Entry
+}
+
+// newProxySettings creates the entries, the address and user are bound to
+// the cred.<prefix>_proxy preferences if prefs is set
+func newProxySettings(prefs fyne.Preferences, prefix string) *proxySettings {
+	ps := &proxySettings{
+		proxy:    widget.NewEntry(),
+		user:     widget.NewEntry(),
+		password: widget.NewPasswordEntry(),
+	}
+	if prefs != nil {
+		ps.proxy.Bind(binding.BindPreferenceString("cred."+prefix+"_proxy", prefs))
+		ps.user.Bind(binding.BindPreferenceString("cred."+prefix+"_proxy_user", prefs))
+	}
+	ps.proxy.SetPlaceHolder("socks5://host:1080, http://host:3128 or direct")
+	ps.proxy.Validator = func(s string) error {
+		_, err := parseProxy(s)
+		return err
+	}
+	return ps
+}
+
+func (ps *proxySettings) items() []*widget.FormItem {
+	return []*widget.FormItem{
+		widget.NewFormItem("Proxy", ps.proxy),
+		widget.NewFormItem("Proxy user", ps.user),
+		widget.NewFormItem("Proxy password", ps.password),
+	}
+}
+
+func (ps *proxySettings) set(cfg connConfig) {
+	ps.proxy.SetText(cfg.Proxy)
+	ps.user.SetText(cfg.ProxyUser)
+	ps.password.SetText(cfg.ProxyPassword)
+}
+
+func (ps *proxySettings) apply(cfg *connConfig) {
+	cfg.Proxy = ps.proxy.Text
+	cfg.ProxyUser = ps.user.Text
+	cfg.ProxyPassword = ps.password.Text
+}
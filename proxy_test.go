@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseProxy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: ""},
+		{in: "direct", want: ""},
+		{in: "http://squid:3128", want: "http://squid:3128"},
+		{in: "socks5://127.0.0.1:1080", want: "socks5://127.0.0.1:1080"},
+		{in: "ftp://host", wantErr: true},
+		{in: "http://", wantErr: true},
+		{in: "squid:3128", wantErr: true},
+	}
+	for _, tt := range tests {
+		u, err := parseProxy(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseProxy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != tt.want {
+			t.Errorf("parseProxy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// fakeConnectProxy accepts one CONNECT request and echoes the tunnel
+func fakeConnectProxy(t *testing.T) (addr string, requests chan *http.Request) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	requests = make(chan *http.Request, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		requests <- req
+		if req.Header.Get("Proxy-Authorization") == "" {
+			io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+		// the greeting arrives with the reply to check buffered reads
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\nhello\n")
+		line, _ := br.ReadString('\n')
+		io.WriteString(conn, line)
+	}()
+	return ln.Addr().String(), requests
+}
+
+func TestProxyDialer_HTTPConnect(t *testing.T) {
+	addr, requests := fakeConnectProxy(t)
+	cfg := connConfig{Proxy: "http://" + addr, ProxyUser: "u", ProxyPassword: "p"}
+	u, err := cfg.proxyURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dial, err := proxyDialer(u, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dial(ctx, "tcp", "example.com:22")
+	if err != nil {
+		t.Fatalf("dial error = %v", err)
+	}
+	defer conn.Close()
+
+	req := <-requests
+	if req.Method != http.MethodConnect || req.Host != "example.com:22" {
+		t.Errorf("proxy request = %s %s", req.Method, req.Host)
+	}
+	if got := req.Header.Get("Proxy-Authorization"); got != "Basic dTpw" {
+		t.Errorf("Proxy-Authorization = %q", got)
+	}
+	br := bufio.NewReader(conn)
+	if line, _ := br.ReadString('\n'); line != "hello\n" {
+		t.Errorf("greeting = %q", line)
+	}
+	io.WriteString(conn, "ping\n")
+	if line, _ := br.ReadString('\n'); line != "ping\n" {
+		t.Errorf("echo = %q", line)
+	}
+}
+
+func TestProxyDialer_Rejected(t *testing.T) {
+	addr, _ := fakeConnectProxy(t)
+	u, _ := parseProxy("http://" + addr)
+	dial, err := proxyDialer(u, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dial(context.Background(), "tcp", "example.com:22"); err == nil {
+		t.Error("dial without credentials succeeded")
+	}
+}
@@ -3,21 +3,33 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 const (
 	listDelimiter = "/"
+
+	addressingAuto    = "auto"
+	addressingPath    = "path"
+	addressingVirtual = "virtual"
+
+	defaultRegion = "us-east-1"
 )
 
 var transport http.RoundTripper = &http.Transport{
@@ -60,29 +72,90 @@ func NewClientWithBucket(bucket, prefix, accessKey, secretKey, region, endpoint
 	return c
 }
 
+// S3Options tunes how a client talks to its endpoint
+type S3Options struct {
+	// Addressing is auto, path or virtual, empty means auto. Auto uses
+	// virtual-hosted style and follows bucket region redirects on AWS, and
+	// path style everywhere else
+	Addressing string
+	// MaxAttempts is the number of tries per request, 0 means 3 and 1
+	// disables retries
+	MaxAttempts    int
+	ConnectTimeout time.Duration
+	// ReadTimeout limits waiting for response headers and each body read
+	ReadTimeout time.Duration
+	// MaxConns limits the connections per host, 0 means no limit
+	MaxConns int
+	// TLS replaces the default TLS settings if set
+	TLS *tls.Config
+	// Proxy replaces the proxy from the environment if set, NoProxy
+	// connects directly
+	Proxy   *url.URL
+	NoProxy bool
+	// Encryption is the server-side encryption of uploads, nil for none
+	Encryption *Encryption
+	// Checksum is sent with uploads, md5, crc32c or sha256, empty means md5
+	// and off also skips checking downloads
+	Checksum string
+}
+
+// isAWSEndpoint reports whether endpoint is empty or an amazonaws.com host
+func isAWSEndpoint(endpoint string) bool {
+	if endpoint == "" {
+		return true
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		u, err = url.Parse("https://" + endpoint)
+		if err != nil {
+			return false
+		}
+	}
+	host := u.Hostname()
+	return host == "amazonaws.com" || strings.HasSuffix(host, ".amazonaws.com")
+}
+
+// usePathStyle resolves the addressing mode for endpoint
+func usePathStyle(addressing, endpoint string) bool {
+	switch addressing {
+	case addressingPath:
+		return true
+	case addressingVirtual:
+		return false
+	}
+	return !isAWSEndpoint(endpoint)
+}
+
 func NewClient(accessKey, secretKey, region, endpoint string) *S3Client {
+	return NewClientWithOptions(accessKey, secretKey, region, endpoint, S3Options{})
+}
+
+func NewClientWithOptions(accessKey, secretKey, region, endpoint string, opt S3Options) *S3Client {
 	// Use insecure transport only if AWS_SKIP_VERIFY is set
 	transportToUse := transport
 	if os.Getenv("AWS_SKIP_VERIFY") != "" {
 		transportToUse = insecureTransport
+		if opt.TLS != nil {
+			opt.TLS = opt.TLS.Clone()
+			opt.TLS.InsecureSkipVerify = true
+		}
 		slog.Warn("using insecure TLS configuration (AWS_SKIP_VERIFY is set)")
 	}
 
+	if region == "" {
+		region = defaultRegion
+	}
+	if opt.Addressing == "" {
+		opt.Addressing = addressingAuto
+	}
 	awsConfig := aws.Config{
 		Region:        region,
 		ClientLogMode: 0,
 		HTTPClient: &http.Client{
-			Transport: transportToUse,
+			Transport: tunedTransport(transportToUse, opt),
 		},
-		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, rg string, opts ...interface{}) (aws.Endpoint, error) {
-			return aws.Endpoint{
-				URL:           endpoint,
-				SigningName:   "s3",
-				SigningRegion: rg,
-			}, nil
-		}),
 		Retryer: func() aws.Retryer {
-			return aws.NopRetryer{}
+			return newRetryer(opt.MaxAttempts)
 		},
 	}
 
@@ -97,25 +170,120 @@ func NewClient(accessKey, secretKey, region, endpoint string) *S3Client {
 		})
 	}
 
+	// in auto mode AWS endpoints follow the region, so the bucket can move
+	// to its own regional endpoint after a redirect
+	baseEndpoint := endpoint
+	if opt.Addressing == addressingAuto && isAWSEndpoint(endpoint) {
+		baseEndpoint = ""
+	} else if baseEndpoint != "" && !strings.Contains(baseEndpoint, "://") {
+		baseEndpoint = "https://" + baseEndpoint
+	}
 	client := s3.NewFromConfig(awsConfig, func(opts *s3.Options) {
-		opts.UsePathStyle = true
+		opts.UsePathStyle = usePathStyle(opt.Addressing, endpoint)
+		if baseEndpoint != "" {
+			opts.BaseEndpoint = aws.String(baseEndpoint)
+		}
 	})
 
-	return &S3Client{
-		Client: client,
+	c := &S3Client{
+		Client:      client,
+		addressing:  opt.Addressing,
+		awsEndpoint: isAWSEndpoint(endpoint),
 	}
+	c.region.Store(&region)
+	c.sse.Store(opt.Encryption)
+	c.SetChecksum(opt.Checksum)
+	return c
 }
 
 type S3Client struct {
 	Bucket string
 	Prefix string
 	*s3.Client
+	addressing string
+	// region may change after a redirect, it is passed to every call
+	region atomic.Pointer[string]
+	// uploadClass is the storage class of new objects, empty for the bucket
+	// default
+	uploadClass atomic.Pointer[string]
+	sse         atomic.Pointer[Encryption]
+	checksum    atomic.Pointer[string]
+	// awsEndpoint is set for AWS, where the ETag of an SSE-S3 object is
+	// still its MD5
+	awsEndpoint bool
+}
+
+// Region returns the region requests are currently signed for
+func (c *S3Client) Region() string {
+	return *c.region.Load()
+}
+
+// withRegion is the per call option applying the current region
+func (c *S3Client) withRegion(o *s3.Options) {
+	o.Region = c.Region()
+}
+
+// redirectRegion returns the bucket region of a PermanentRedirect or wrong
+// region error, or "" for any other error
+func redirectRegion(err error) string {
+	var re *awshttp.ResponseError
+	if err == nil || !errors.As(err, &re) || re.Response == nil {
+		return ""
+	}
+	switch re.HTTPStatusCode() {
+	case http.StatusMovedPermanently, http.StatusBadRequest, http.StatusForbidden:
+		return re.Response.Header.Get("x-amz-bucket-region")
+	}
+	return ""
+}
+
+// followRedirect runs op and, in auto mode, runs it once more against the
+// region a redirect points to
+func (c *S3Client) followRedirect(op func() error) error {
+	err := op()
+	if c.addressing != addressingAuto {
+		return err
+	}
+	if region := redirectRegion(err); region != "" && region != c.Region() {
+		slog.Info("s3 bucket redirect",
+			slog.String("bucket", c.Bucket),
+			slog.String("from", c.Region()),
+			slog.String("to", region),
+		)
+		c.region.Store(&region)
+		err = op()
+	}
+	return err
+}
+
+// DetectRegion asks the bucket for its region with HeadBucket and switches
+// to it, only in auto mode
+func (c *S3Client) DetectRegion(ctx context.Context) (err error) {
+	if c.addressing != addressingAuto || c.Bucket == "" {
+		return
+	}
+	resp, err := c.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(c.Bucket),
+	}, c.withRegion)
+	region := redirectRegion(err)
+	if err == nil {
+		region = aws.ToString(resp.BucketRegion)
+	}
+	if region != "" && region != c.Region() {
+		slog.Info("s3 bucket region detected",
+			slog.String("bucket", c.Bucket),
+			slog.String("region", region),
+		)
+		c.region.Store(&region)
+		return nil
+	}
+	return
 }
 
 func (c *S3Client) ListAllMyBuckets(ctx context.Context) (data []string, err error) {
 	slog.Debug("s3 list buckets")
 
-	s3out, err := c.ListBuckets(ctx, &s3.ListBucketsInput{})
+	s3out, err := c.ListBuckets(ctx, &s3.ListBucketsInput{}, c.withRegion)
 	if err != nil {
 		return
 	}
@@ -145,7 +313,11 @@ func (c *S3Client) List(ctx context.Context, prefix, marker string) (data []File
 		loi.Marker = aws.String(marker)
 	}
 
-	s3out, err := c.ListObjects(ctx, loi)
+	var s3out *s3.ListObjectsOutput
+	err = c.followRedirect(func() (err error) {
+		s3out, err = c.ListObjects(ctx, loi, c.withRegion)
+		return
+	})
 	if err != nil {
 		return
 	}
@@ -165,9 +337,11 @@ func (c *S3Client) List(ctx context.Context, prefix, marker string) (data []File
 	}
 	for i, v := range s3out.Contents {
 		f := File{
-			Name: *v.Key,
-			Time: *v.LastModified,
-			Size: *v.Size,
+			Name:         *v.Key,
+			Time:         *v.LastModified,
+			Size:         *v.Size,
+			ETag:         aws.ToString(v.ETag),
+			StorageClass: string(v.StorageClass),
 		}
 		if prefix != "" {
 			f.Name = strings.TrimPrefix(f.Name, prefix)
@@ -186,6 +360,83 @@ func (c *S3Client) List(ctx context.Context, prefix, marker string) (data []File
 	return
 }
 
+// Search lists the keys under prefix starting with pattern, one delimiter
+// level deep, streaming each page to fn
+func (c *S3Client) Search(ctx context.Context, prefix, pattern string, fn func(data []File) error) (err error) {
+	slog.Debug("s3 search",
+		slog.String("prefix", prefix),
+		slog.String("pattern", pattern),
+	)
+	prefix = c.Prefix + prefix
+	p := s3.NewListObjectsV2Paginator(c.Client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(c.Bucket),
+		Delimiter: aws.String(listDelimiter),
+		Prefix:    aws.String(prefix + pattern),
+	})
+	for p.HasMorePages() {
+		s3out, err := p.NextPage(ctx, c.withRegion)
+		if err != nil {
+			return err
+		}
+		data := make([]File, 0, len(s3out.CommonPrefixes)+len(s3out.Contents))
+		for _, v := range s3out.CommonPrefixes {
+			data = append(data, File{
+				Name: strings.TrimPrefix(*v.Prefix, prefix),
+				Type: FileDir,
+			})
+		}
+		for _, v := range s3out.Contents {
+			data = append(data, File{
+				Name:         strings.TrimPrefix(*v.Key, prefix),
+				Time:         *v.LastModified,
+				Size:         *v.Size,
+				ETag:         aws.ToString(v.ETag),
+				StorageClass: string(v.StorageClass),
+			})
+		}
+		if err = fn(data); err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+// Walk lists every object below prefix without a delimiter, names are
+// relative to prefix
+func (c *S3Client) Walk(ctx context.Context, prefix string, fn func(data []File) error) (err error) {
+	prefix = c.Prefix + prefix
+	p := s3.NewListObjectsV2Paginator(c.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for p.HasMorePages() {
+		s3out, err := p.NextPage(ctx, c.withRegion)
+		if err != nil {
+			return err
+		}
+		data := make([]File, 0, len(s3out.Contents))
+		for _, v := range s3out.Contents {
+			if strings.HasSuffix(*v.Key, "/") && aws.ToInt64(v.Size) == 0 {
+				// folder placeholder
+				continue
+			}
+			data = append(data, File{
+				Name:         strings.TrimPrefix(*v.Key, prefix),
+				Time:         aws.ToTime(v.LastModified),
+				Size:         aws.ToInt64(v.Size),
+				ETag:         aws.ToString(v.ETag),
+				StorageClass: string(v.StorageClass),
+			})
+		}
+		if err = fn(data); err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
 func (c *S3Client) Upload(ctx context.Context, rs io.ReadSeeker, key, contentType string) (err error) {
 	if c.Prefix != "" {
 		key = c.Prefix + key
@@ -198,10 +449,38 @@ func (c *S3Client) Upload(ctx context.Context, rs io.ReadSeeker, key, contentTyp
 	if contentType != "" {
 		input.ContentType = aws.String(contentType)
 	}
+	if class := c.uploadClass.Load(); class != nil && *class != "" {
+		input.StorageClass = types.StorageClass(*class)
+	}
+	c.Encryption().applyPut(input)
+	alg := c.Checksum()
+	var sum string
+	if alg != checksumOff {
+		if sum, err = checksumOf(rs, alg); err != nil {
+			err = fmt.Errorf("checksum %s error %w", key, err)
+			return
+		}
+		applyChecksum(input, alg, sum)
+	}
 
-	_, err = c.PutObject(ctx, input, s3.WithAPIOptions(
-		v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware,
-	))
+	var out *s3.PutObjectOutput
+	err = c.followRedirect(func() (err error) {
+		if _, err = rs.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		// the sum is already set, the SDK must not add a trailer of its own
+		out, err = c.PutObject(ctx, input, c.withRegion, func(o *s3.Options) {
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		}, s3.WithAPIOptions(
+			v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware,
+		))
+		return
+	})
+	if err != nil {
+		err = digestError(key, alg, err)
+		return
+	}
+	err = checkPutChecksum(out, key, alg, sum)
 
 	return
 }
@@ -210,15 +489,64 @@ func (c *S3Client) Download(ctx context.Context, w io.Writer, key string) (err e
 	if c.Prefix != "" {
 		key = c.Prefix + key
 	}
-	resp, err := c.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(c.Bucket),
-		Key:    aws.String(key),
+	verify := c.Checksum() != checksumOff
+	var resp *s3.GetObjectOutput
+	err = c.followRedirect(func() (err error) {
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(c.Bucket),
+			Key:    aws.String(key),
+		}
+		c.Encryption().applyGet(input)
+		if !verify {
+			resp, err = c.GetObject(ctx, input, c.withRegion)
+			return
+		}
+		input.ChecksumMode = types.ChecksumModeEnabled
+		resp, err = c.GetObject(ctx, input, c.withRegion, s3.WithAPIOptions(removeChecksumValidation))
+		return
 	})
 	if err != nil {
+		err = archivedError(err)
 		return
 	}
 	defer resp.Body.Close()
-	_, err = io.Copy(w, resp.Body)
+	var dc *downloadCheck
+	if verify {
+		dc = newDownloadCheck(resp, c.awsEndpoint || resp.ServerSideEncryption == "")
+	}
+	if dc == nil {
+		_, err = io.Copy(w, resp.Body)
+		return
+	}
+	if _, err = io.Copy(io.MultiWriter(w, dc.h), resp.Body); err != nil {
+		return
+	}
+	err = dc.verify(key)
+
+	return
+}
+
+func (c *S3Client) DownloadRange(ctx context.Context, w io.Writer, key string, offset, length int64) (err error) {
+	if c.Prefix != "" {
+		key = c.Prefix + key
+	}
+	var resp *s3.GetObjectOutput
+	err = c.followRedirect(func() (err error) {
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(c.Bucket),
+			Key:    aws.String(key),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+		}
+		c.Encryption().applyGet(input)
+		resp, err = c.GetObject(ctx, input, c.withRegion)
+		return
+	})
+	if err != nil {
+		err = archivedError(err)
+		return
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, io.LimitReader(resp.Body, length))
 
 	return
 }
@@ -227,10 +555,14 @@ func (c *S3Client) Delete(ctx context.Context, key string) (err error) {
 	if c.Prefix != "" {
 		key = c.Prefix + key
 	}
-	_, err = c.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(c.Bucket),
-		Key:    aws.String(key),
+	err = c.followRedirect(func() (err error) {
+		_, err = c.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(c.Bucket),
+			Key:    aws.String(key),
+		}, c.withRegion)
+		return
 	})
+	err = lockedError(err)
 
 	return
 }
@@ -239,17 +571,25 @@ func (c *S3Client) Stat(ctx context.Context, key string) (f File, err error) {
 	if c.Prefix != "" {
 		key = c.Prefix + key
 	}
-	resp, err := c.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(c.Bucket),
-		Key:    aws.String(key),
+	var resp *s3.HeadObjectOutput
+	err = c.followRedirect(func() (err error) {
+		input := &s3.HeadObjectInput{
+			Bucket: aws.String(c.Bucket),
+			Key:    aws.String(key),
+		}
+		c.Encryption().applyHead(input)
+		resp, err = c.HeadObject(ctx, input, c.withRegion)
+		return
 	})
 	if err != nil {
 		return
 	}
 	f.Name = key
-	f.Size = *resp.ContentLength
-	f.ContentType = *resp.ContentType
-	f.Time = *resp.LastModified
+	f.Size = aws.ToInt64(resp.ContentLength)
+	f.ContentType = aws.ToString(resp.ContentType)
+	f.Time = aws.ToTime(resp.LastModified)
+	f.ETag = aws.ToString(resp.ETag)
+	f.StorageClass = string(resp.StorageClass)
 
 	return
 }
@@ -2,6 +2,10 @@ package main
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -71,3 +75,114 @@ func TestS3Client_Close(t *testing.T) {
 		t.Errorf("S3Client.Close() error = %v, want nil", err)
 	}
 }
+
+func TestUsePathStyle(t *testing.T) {
+	tests := []struct {
+		addressing string
+		endpoint   string
+		want       bool
+	}{
+		{addressingAuto, "http://192.168.0.8:9000", true},
+		{addressingAuto, "https://s3.eu-west-1.amazonaws.com", false},
+		{addressingAuto, "", false},
+		{addressingPath, "https://s3.amazonaws.com", true},
+		{addressingVirtual, "http://minio.local:9000", false},
+	}
+	for _, tt := range tests {
+		if got := usePathStyle(tt.addressing, tt.endpoint); got != tt.want {
+			t.Errorf("usePathStyle(%v, %v) = %v, want %v", tt.addressing, tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+// fakeS3 answers every request with the next canned response and records
+// the requests
+type fakeS3 struct {
+	mu        sync.Mutex
+	urls      []string
+	requests  []*http.Request
+	bodies    [][]byte
+	responses []*http.Response
+}
+
+func (f *fakeS3) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.urls = append(f.urls, req.URL.Host+req.URL.Path)
+	f.requests = append(f.requests, req)
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+	f.bodies = append(f.bodies, body)
+	resp := f.responses[0]
+	if len(f.responses) > 1 {
+		f.responses = f.responses[1:]
+	}
+	resp.Request = req
+	if resp.Body == nil {
+		resp.Body = io.NopCloser(strings.NewReader(""))
+	}
+	return resp, nil
+}
+
+func withFakeS3(t *testing.T, responses ...*http.Response) *fakeS3 {
+	f := &fakeS3{responses: responses}
+	old := transport
+	transport = f
+	t.Cleanup(func() { transport = old })
+	return f
+}
+
+func TestS3Client_Addressing(t *testing.T) {
+	tests := []struct {
+		addressing string
+		endpoint   string
+		want       string
+	}{
+		{addressingAuto, "http://minio.local:9000", "minio.local:9000/bucket/key"},
+		{addressingAuto, "https://s3.amazonaws.com", "bucket.s3.eu-west-1.amazonaws.com/key"},
+		{addressingVirtual, "http://minio.local:9000", "bucket.minio.local:9000/key"},
+		{addressingPath, "https://s3.eu-west-1.amazonaws.com", "s3.eu-west-1.amazonaws.com/bucket/key"},
+	}
+	for _, tt := range tests {
+		f := withFakeS3(t, &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}})
+		c := NewClientWithOptions("ak", "sk", "eu-west-1", tt.endpoint, S3Options{Addressing: tt.addressing})
+		c.Bucket = "bucket"
+		c.Delete(context.Background(), "key")
+		if len(f.urls) == 0 || f.urls[0] != tt.want {
+			t.Errorf("Delete(%v, %v) url = %v, want %v", tt.addressing, tt.endpoint, f.urls, tt.want)
+		}
+	}
+}
+
+func TestS3Client_FollowRedirect(t *testing.T) {
+	redirect := &http.Response{
+		StatusCode: http.StatusMovedPermanently,
+		Header:     http.Header{"X-Amz-Bucket-Region": {"ap-southeast-2"}},
+	}
+	ok := &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Length": {"3"},
+			"Content-Type":   {"text/plain"},
+			"Last-Modified":  {"Mon, 15 Jan 2024 10:30:00 GMT"},
+		},
+	}
+	f := withFakeS3(t, redirect, ok)
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "", S3Options{})
+	c.Bucket = "bucket"
+	file, err := c.Stat(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if file.Size != 3 {
+		t.Errorf("Stat() size = %v, want 3", file.Size)
+	}
+	if c.Region() != "ap-southeast-2" {
+		t.Errorf("Region() = %v, want ap-southeast-2", c.Region())
+	}
+	if len(f.urls) != 2 || f.urls[1] != "bucket.s3.ap-southeast-2.amazonaws.com/key" {
+		t.Errorf("Stat() urls = %v", f.urls)
+	}
+}
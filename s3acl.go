@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	groupAllUsers           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	groupAuthenticatedUsers = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	groupLogDelivery        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+var cannedACLs = []string{
+	string(types.ObjectCannedACLPrivate),
+	string(types.ObjectCannedACLPublicRead),
+	string(types.ObjectCannedACLBucketOwnerFullControl),
+}
+
+var errACLDisabled = errors.New("ACLs are disabled on this bucket, access is set by bucket policy only")
+
+// aclError explains the error of reading or setting ACLs on a bucket with
+// object ownership enforced
+func aclError(err error) error {
+	if apiErrorCode(err) == "AccessControlListNotSupported" {
+		return fmt.Errorf("%w: %w", errACLDisabled, err)
+	}
+	return err
+}
+
+// Grant gives Permission to Grantee, a group name, display name, ID or
+// email
+type Grant struct {
+	Grantee    string
+	Permission string
+	// Public is set for grants to AllUsers and AuthenticatedUsers
+	Public bool
+}
+
+// ACL is the access control list of a bucket or object
+type ACL struct {
+	Owner  string
+	Grants []Grant
+}
+
+// Public returns the grants to everyone or to any AWS account
+func (a ACL) Public() (grants []Grant) {
+	for _, g := range a.Grants {
+		if g.Public {
+			grants = append(grants, g)
+		}
+	}
+	return
+}
+
+// String lists the public grants, or the number of private ones
+func (a ACL) String() string {
+	public := a.Public()
+	if len(public) == 0 {
+		return fmt.Sprintf("private, %d grants", len(a.Grants))
+	}
+	var s []string
+	for _, g := range public {
+		s = append(s, g.Grantee+" "+g.Permission)
+	}
+	return "PUBLIC " + strings.Join(s, ", ")
+}
+
+func aclFromAWS(owner *types.Owner, grants []types.Grant) (a ACL) {
+	if owner != nil {
+		a.Owner = aws.ToString(owner.DisplayName)
+		if a.Owner == "" {
+			a.Owner = aws.ToString(owner.ID)
+		}
+	}
+	for _, g := range grants {
+		grant := Grant{Permission: string(g.Permission)}
+		if ge := g.Grantee; ge != nil {
+			switch uri := aws.ToString(ge.URI); uri {
+			case groupAllUsers:
+				grant.Grantee, grant.Public = "AllUsers", true
+			case groupAuthenticatedUsers:
+				grant.Grantee, grant.Public = "AuthenticatedUsers", true
+			case groupLogDelivery:
+				grant.Grantee = "LogDelivery"
+			case "":
+				for _, s := range []*string{ge.DisplayName, ge.EmailAddress, ge.ID} {
+					if grant.Grantee = aws.ToString(s); grant.Grantee != "" {
+						break
+					}
+				}
+			default:
+				grant.Grantee = uri
+			}
+		}
+		a.Grants = append(a.Grants, grant)
+	}
+	return
+}
+
+// cannedPublic reports whether objects with the canned ACL can be read by
+// anyone
+func cannedPublic(canned string) bool {
+	return canned == string(types.ObjectCannedACLPublicRead) ||
+		canned == string(types.ObjectCannedACLPublicReadWrite) ||
+		canned == string(types.ObjectCannedACLAuthenticatedRead)
+}
+
+// ObjectACL is the ACL of Key, or Err if it could not be read
+type ObjectACL struct {
+	Key string
+	ACL ACL
+	Err error
+}
+
+// aclPlan summarizes what applying canned to the scanned objects changes
+func aclPlan(objects []ObjectACL, canned string) string {
+	var public, private, failed int
+	for _, o := range objects {
+		switch {
+		case o.Err != nil:
+			failed++
+		case len(o.ACL.Public()) > 0:
+			public++
+		default:
+			private++
+		}
+	}
+	s := fmt.Sprintf("Set %s on %d objects", canned, len(objects))
+	if cannedPublic(canned) {
+		s += fmt.Sprintf(", %d private objects become PUBLIC", private)
+	} else {
+		s += fmt.Sprintf(", %d public objects become private", public)
+	}
+	if failed > 0 {
+		s += fmt.Sprintf(", %d have an unreadable ACL", failed)
+	}
+	return s
+}
+
+// PublicAccessBlock are the block public access settings of a bucket
+type PublicAccessBlock struct {
+	Configured            bool
+	BlockPublicACLs       bool
+	IgnorePublicACLs      bool
+	BlockPublicPolicy     bool
+	RestrictPublicBuckets bool
+}
+
+func (p PublicAccessBlock) String() string {
+	if !p.Configured {
+		return publicAccessString(nil)
+	}
+	return publicAccessString(&types.PublicAccessBlockConfiguration{
+		BlockPublicAcls:       aws.Bool(p.BlockPublicACLs),
+		IgnorePublicAcls:      aws.Bool(p.IgnorePublicACLs),
+		BlockPublicPolicy:     aws.Bool(p.BlockPublicPolicy),
+		RestrictPublicBuckets: aws.Bool(p.RestrictPublicBuckets),
+	})
+}
+
+// PublicAccessBlock reads the block public access settings of the bucket
+func (c *S3Client) PublicAccessBlock(ctx context.Context) (p PublicAccessBlock, err error) {
+	var out *s3.GetPublicAccessBlockOutput
+	err = c.followRedirect(func() (err error) {
+		out, err = c.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(c.Bucket)}, c.withRegion)
+		return
+	})
+	if apiErrorCode(err) == "NoSuchPublicAccessBlockConfiguration" {
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("get public access block of %s error %w", c.Bucket, err)
+	}
+	if cfg := out.PublicAccessBlockConfiguration; cfg != nil {
+		p = PublicAccessBlock{
+			Configured:            true,
+			BlockPublicACLs:       aws.ToBool(cfg.BlockPublicAcls),
+			IgnorePublicACLs:      aws.ToBool(cfg.IgnorePublicAcls),
+			BlockPublicPolicy:     aws.ToBool(cfg.BlockPublicPolicy),
+			RestrictPublicBuckets: aws.ToBool(cfg.RestrictPublicBuckets),
+		}
+	}
+	return
+}
+
+// BucketACL reads the ACL of the bucket
+func (c *S3Client) BucketACL(ctx context.Context) (a ACL, err error) {
+	var out *s3.GetBucketAclOutput
+	err = c.followRedirect(func() (err error) {
+		out, err = c.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: aws.String(c.Bucket)}, c.withRegion)
+		return
+	})
+	if err != nil {
+		return a, fmt.Errorf("get acl of %s error %w", c.Bucket, aclError(err))
+	}
+	return aclFromAWS(out.Owner, out.Grants), nil
+}
+
+// ObjectACL reads the ACL of key
+func (c *S3Client) ObjectACL(ctx context.Context, key string) (a ACL, err error) {
+	if c.Prefix != "" {
+		key = c.Prefix + key
+	}
+	var out *s3.GetObjectAclOutput
+	err = c.followRedirect(func() (err error) {
+		out, err = c.GetObjectAcl(ctx, &s3.GetObjectAclInput{Bucket: aws.String(c.Bucket), Key: aws.String(key)}, c.withRegion)
+		return
+	})
+	if err != nil {
+		return a, fmt.Errorf("get acl of %s error %w", key, aclError(err))
+	}
+	return aclFromAWS(out.Owner, out.Grants), nil
+}
+
+// ScanACL reads the ACL of every object under prefix, progress is called
+// with the number read so far
+func (c *S3Client) ScanACL(ctx context.Context, prefix string, progress func(n int)) (objects []ObjectACL, err error) {
+	var keys []string
+	p := s3.NewListObjectsV2Paginator(c, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.Bucket),
+		Prefix: aws.String(c.Prefix + prefix),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx, c.withRegion)
+		if err != nil {
+			return nil, fmt.Errorf("list %s error %w", prefix, err)
+		}
+		for _, o := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.ToString(o.Key), c.Prefix))
+		}
+	}
+
+	objects = make([]ObjectACL, len(keys))
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	sem := make(chan struct{}, 8)
+	for i, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			a, err := c.ObjectACL(ctx, key)
+			objects[i] = ObjectACL{Key: key, ACL: a, Err: err}
+			mu.Lock()
+			done++
+			if progress != nil {
+				progress(done)
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return objects, ctx.Err()
+}
+
+// ApplyACL sets the canned ACL on keys, stopping at the first error
+func (c *S3Client) ApplyACL(ctx context.Context, keys []string, canned string, progress func(n int)) (err error) {
+	for i, key := range keys {
+		full := c.Prefix + key
+		err = c.followRedirect(func() (err error) {
+			_, err = c.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+				Bucket: aws.String(c.Bucket),
+				Key:    aws.String(full),
+				ACL:    types.ObjectCannedACL(canned),
+			}, c.withRegion)
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("set acl of %s error %w", full, aclError(err))
+		}
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+	return
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const publicACL = `<AccessControlPolicy><Owner><ID>o1</ID><DisplayName>ops</DisplayName></Owner><AccessControlList>` +
+	`<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser"><ID>o1</ID></Grantee><Permission>FULL_CONTROL</Permission></Grant>` +
+	`<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>http://acs.amazonaws.com/groups/global/AllUsers</URI></Grantee><Permission>READ</Permission></Grant>` +
+	`</AccessControlList></AccessControlPolicy>`
+
+const privateACL = `<AccessControlPolicy><Owner><ID>o1</ID></Owner><AccessControlList>` +
+	`<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser"><ID>o1</ID></Grantee><Permission>FULL_CONTROL</Permission></Grant>` +
+	`</AccessControlList></AccessControlPolicy>`
+
+func TestS3Client_ObjectACL(t *testing.T) {
+	withFakeS3(t, xmlResponse(http.StatusOK, publicACL))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	a, err := c.ObjectACL(context.Background(), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Owner != "ops" || len(a.Grants) != 2 || a.Grants[0].Grantee != "o1" {
+		t.Errorf("ObjectACL() = %+v", a)
+	}
+	if got := a.String(); got != "PUBLIC AllUsers READ" {
+		t.Errorf("ACL.String() = %q", got)
+	}
+
+	withFakeS3(t, xmlResponse(http.StatusBadRequest, `<Error><Code>AccessControlListNotSupported</Code></Error>`))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	if _, err = c.ObjectACL(context.Background(), "a.txt"); !errors.Is(err, errACLDisabled) {
+		t.Errorf("ObjectACL() error = %v, want errACLDisabled", err)
+	}
+}
+
+func TestS3Client_PublicAccessBlock(t *testing.T) {
+	withFakeS3(t, xmlResponse(http.StatusNotFound, `<Error><Code>NoSuchPublicAccessBlockConfiguration</Code></Error>`))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	p, err := c.PublicAccessBlock(context.Background())
+	if err != nil || p.Configured || p.String() != "Not configured" {
+		t.Errorf("PublicAccessBlock() = %+v, %v, want not configured", p, err)
+	}
+
+	withFakeS3(t, xmlResponse(http.StatusOK, `<PublicAccessBlockConfiguration><BlockPublicAcls>true</BlockPublicAcls>`+
+		`<IgnorePublicAcls>true</IgnorePublicAcls></PublicAccessBlockConfiguration>`))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	p, err = c.PublicAccessBlock(context.Background())
+	if err != nil || !p.BlockPublicACLs || !p.IgnorePublicACLs || p.BlockPublicPolicy {
+		t.Errorf("PublicAccessBlock() = %+v, %v", p, err)
+	}
+}
+
+func TestS3Client_ScanACL(t *testing.T) {
+	withFakeS3(t,
+		xmlResponse(http.StatusOK, `<ListBucketResult><IsTruncated>false</IsTruncated><KeyCount>2</KeyCount>`+
+			`<Contents><Key>pub/a</Key></Contents><Contents><Key>pub/b</Key></Contents></ListBucketResult>`),
+		xmlResponse(http.StatusOK, privateACL),
+		xmlResponse(http.StatusOK, privateACL),
+	)
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	var read int
+	objects, err := c.ScanACL(context.Background(), "pub/", func(n int) { read = n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Key != "pub/a" || objects[1].Key != "pub/b" || read != 2 {
+		t.Errorf("ScanACL() = %+v, progress %d", objects, read)
+	}
+	for _, o := range objects {
+		if o.Err != nil || o.ACL.Owner != "o1" || len(o.ACL.Public()) != 0 {
+			t.Errorf("ScanACL() %s = %+v", o.Key, o)
+		}
+	}
+}
+
+func TestACLPlan(t *testing.T) {
+	objects := []ObjectACL{
+		{Key: "a", ACL: ACL{Grants: []Grant{{Grantee: "AllUsers", Permission: "READ", Public: true}}}},
+		{Key: "b", ACL: ACL{Grants: []Grant{{Grantee: "o1", Permission: "FULL_CONTROL"}}}},
+		{Key: "c", ACL: ACL{}},
+		{Key: "d", Err: errACLDisabled},
+	}
+	tests := []struct {
+		canned string
+		want   string
+	}{
+		{"private", "Set private on 4 objects, 1 public objects become private, 1 have an unreadable ACL"},
+		{"public-read", "Set public-read on 4 objects, 2 private objects become PUBLIC, 1 have an unreadable ACL"},
+	}
+	for _, tt := range tests {
+		if got := aclPlan(objects, tt.canned); got != tt.want {
+			t.Errorf("aclPlan(%s) = %q, want %q", tt.canned, got, tt.want)
+		}
+	}
+}
+
+func TestS3Client_ApplyACL(t *testing.T) {
+	f := withFakeS3(t, xmlResponse(http.StatusOK, ""))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	c.Prefix = "root/"
+	if err := c.ApplyACL(context.Background(), []string{"a", "b"}, "private", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.requests) != 2 || f.urls[1] != "minio.local:9000/bucket/root/b" {
+		t.Fatalf("ApplyACL() urls = %v", f.urls)
+	}
+	if h := f.requests[0].Header.Get("X-Amz-Acl"); h != "private" || !strings.Contains(f.requests[0].URL.RawQuery, "acl") {
+		t.Errorf("ApplyACL() request = %s %s", h, f.requests[0].URL.RawQuery)
+	}
+}
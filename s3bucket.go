@@ -0,0 +1,298 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// BucketInfo is a bucket of ListBucketInfo, Region is empty if the server
+// doesn't tell
+type BucketInfo struct {
+	Name    string
+	Created time.Time
+	Region  string
+}
+
+// BucketOptions are the settings of a new bucket, object lock always
+// enables versioning
+type BucketOptions struct {
+	Region     string
+	ObjectLock bool
+	Versioning bool
+}
+
+// BucketSummary describes the content and settings of a bucket
+type BucketSummary struct {
+	Objects int64
+	Size    int64
+	// Versioning is Enabled, Suspended or Off
+	Versioning   string
+	Encryption   string
+	PublicAccess string
+	// Errs holds the parts that could not be read by name, objects,
+	// versioning, encryption or public access
+	Errs map[string]error
+}
+
+var bucketNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// validateBucketName checks the common S3 bucket naming rules
+func validateBucketName(name string) error {
+	if !bucketNameRe.MatchString(name) || strings.Contains(name, "..") {
+		return errors.New("3 to 63 lowercase letters, digits, dots and hyphens, starting and ending with a letter or digit")
+	}
+	return nil
+}
+
+// inRegion is the per call option for a bucket in region, the client region
+// if it is empty
+func (c *S3Client) inRegion(region string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		o.Region = cmp.Or(region, c.Region())
+	}
+}
+
+// apiErrorCode returns the S3 error code of err, or ""
+func apiErrorCode(err error) string {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		return ae.ErrorCode()
+	}
+	return ""
+}
+
+// ListBucketInfo lists the buckets with their creation date and region
+func (c *S3Client) ListBucketInfo(ctx context.Context) (data []BucketInfo, err error) {
+	out, err := c.ListBuckets(ctx, &s3.ListBucketsInput{}, c.withRegion)
+	if err != nil {
+		return nil, fmt.Errorf("list buckets error %w", err)
+	}
+	data = make([]BucketInfo, len(out.Buckets))
+	for i, b := range out.Buckets {
+		data[i] = BucketInfo{
+			Name:    aws.ToString(b.Name),
+			Created: aws.ToTime(b.CreationDate),
+			Region:  aws.ToString(b.BucketRegion),
+		}
+		if data[i].Region != "" {
+			continue
+		}
+		loc, err := c.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: b.Name}, c.withRegion)
+		if err != nil {
+			slog.Debug("s3 bucket location failed",
+				slog.String("bucket", data[i].Name),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		data[i].Region = locationRegion(loc.LocationConstraint)
+	}
+	return
+}
+
+// locationRegion maps a bucket location constraint to its region, buckets
+// in us-east-1 have none and old eu-west-1 buckets say EU
+func locationRegion(loc types.BucketLocationConstraint) string {
+	switch loc {
+	case "":
+		return "us-east-1"
+	case types.BucketLocationConstraintEu:
+		return "eu-west-1"
+	}
+	return string(loc)
+}
+
+// MakeBucket creates the bucket name
+func (c *S3Client) MakeBucket(ctx context.Context, name string, o BucketOptions) (err error) {
+	in := &s3.CreateBucketInput{Bucket: aws.String(name)}
+	// us-east-1 is the default and rejected as a constraint
+	if o.Region != "" && o.Region != "us-east-1" {
+		in.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(o.Region),
+		}
+	}
+	if o.ObjectLock {
+		in.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+	if _, err = c.CreateBucket(ctx, in, c.inRegion(o.Region)); err != nil {
+		return fmt.Errorf("create bucket %s error %w", name, err)
+	}
+	if o.Versioning && !o.ObjectLock {
+		_, err = c.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+			Bucket: aws.String(name),
+			VersioningConfiguration: &types.VersioningConfiguration{
+				Status: types.BucketVersioningStatusEnabled,
+			},
+		}, c.inRegion(o.Region))
+		if err != nil {
+			return fmt.Errorf("enable versioning of %s error %w", name, err)
+		}
+	}
+	return
+}
+
+// RemoveBucket deletes the bucket name, if empty is set all its objects,
+// versions and delete markers are deleted first and progress gets the
+// number deleted so far
+func (c *S3Client) RemoveBucket(ctx context.Context, name, region string, empty bool, progress func(deleted int)) (err error) {
+	if empty {
+		if err = c.emptyBucket(ctx, name, region, progress); err != nil {
+			return
+		}
+	}
+	_, err = c.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(name)}, c.inRegion(region))
+	if err != nil {
+		return fmt.Errorf("delete bucket %s error %w", name, err)
+	}
+	return
+}
+
+func (c *S3Client) emptyBucket(ctx context.Context, name, region string, progress func(deleted int)) error {
+	deleted := 0
+	p := s3.NewListObjectVersionsPaginator(c, &s3.ListObjectVersionsInput{Bucket: aws.String(name)})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx, c.inRegion(region))
+		if err != nil {
+			return fmt.Errorf("list versions of %s error %w", name, err)
+		}
+		var ids []types.ObjectIdentifier
+		for _, v := range page.Versions {
+			ids = append(ids, types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range page.DeleteMarkers {
+			ids = append(ids, types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+		// a page holds at most 1000 entries of each kind, DeleteObjects
+		// takes 1000
+		for len(ids) > 0 {
+			batch := ids[:min(len(ids), 1000)]
+			ids = ids[len(batch):]
+			out, err := c.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(name),
+				Delete: &types.Delete{Objects: batch, Quiet: aws.Bool(true)},
+			}, c.inRegion(region))
+			if err != nil {
+				return fmt.Errorf("empty bucket %s error %w", name, err)
+			}
+			if len(out.Errors) > 0 {
+				locked := 0
+				for _, e := range out.Errors {
+					if isLockedError(aws.ToString(e.Code), aws.ToString(e.Message)) {
+						locked++
+					}
+				}
+				if locked > 0 {
+					return fmt.Errorf("empty bucket %s error %d versions are locked: %w", name, locked, errLocked)
+				}
+				e := out.Errors[0]
+				return fmt.Errorf("empty bucket %s error %s: %s %s", name, aws.ToString(e.Key), aws.ToString(e.Code), aws.ToString(e.Message))
+			}
+			deleted += len(batch)
+			if progress != nil {
+				progress(deleted)
+			}
+		}
+	}
+	return nil
+}
+
+// Summary counts the current objects of the bucket name and reads its
+// versioning, default encryption and public access block
+func (c *S3Client) Summary(ctx context.Context, name, region string) (s BucketSummary) {
+	s.Errs = map[string]error{}
+	p := s3.NewListObjectsV2Paginator(c, &s3.ListObjectsV2Input{Bucket: aws.String(name)})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx, c.inRegion(region))
+		if err != nil {
+			s.Errs["objects"] = err
+			break
+		}
+		for _, o := range page.Contents {
+			s.Objects++
+			s.Size += aws.ToInt64(o.Size)
+		}
+	}
+
+	ver, err := c.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(name)}, c.inRegion(region))
+	if err != nil {
+		s.Errs["versioning"] = err
+	} else {
+		s.Versioning = cmp.Or(string(ver.Status), "Off")
+	}
+
+	enc, err := c.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(name)}, c.inRegion(region))
+	switch {
+	case apiErrorCode(err) == "ServerSideEncryptionConfigurationNotFoundError":
+		s.Encryption = "None"
+	case err != nil:
+		s.Errs["encryption"] = err
+	default:
+		var rules []string
+		for _, r := range enc.ServerSideEncryptionConfiguration.Rules {
+			d := r.ApplyServerSideEncryptionByDefault
+			if d == nil {
+				continue
+			}
+			rule := string(d.SSEAlgorithm)
+			if d.KMSMasterKeyID != nil {
+				rule += " (" + aws.ToString(d.KMSMasterKeyID) + ")"
+			}
+			if aws.ToBool(r.BucketKeyEnabled) {
+				rule += ", bucket key"
+			}
+			rules = append(rules, rule)
+		}
+		s.Encryption = cmp.Or(strings.Join(rules, "; "), "None")
+	}
+
+	pab, err := c.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(name)}, c.inRegion(region))
+	switch {
+	case apiErrorCode(err) == "NoSuchPublicAccessBlockConfiguration":
+		s.PublicAccess = "Not configured"
+	case err != nil:
+		s.Errs["public access"] = err
+	default:
+		s.PublicAccess = publicAccessString(pab.PublicAccessBlockConfiguration)
+	}
+	return
+}
+
+// publicAccessString summarizes the four block public access settings
+func publicAccessString(cfg *types.PublicAccessBlockConfiguration) string {
+	if cfg == nil {
+		return "Not configured"
+	}
+	settings := []struct {
+		name string
+		on   *bool
+	}{
+		{"block public ACLs", cfg.BlockPublicAcls},
+		{"ignore public ACLs", cfg.IgnorePublicAcls},
+		{"block public policy", cfg.BlockPublicPolicy},
+		{"restrict public buckets", cfg.RestrictPublicBuckets},
+	}
+	var on []string
+	for _, s := range settings {
+		if aws.ToBool(s.on) {
+			on = append(on, s.name)
+		}
+	}
+	switch len(on) {
+	case 0:
+		return "Off"
+	case len(settings):
+		return "All blocked"
+	}
+	return strings.Join(on, ", ")
+}
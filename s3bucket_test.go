@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func xmlResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/xml"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"my-bucket", false},
+		{"logs.example.com", false},
+		{"ab", true},
+		{"My-Bucket", true},
+		{"-bucket", true},
+		{"bucket-", true},
+		{"a..b", true},
+		{strings.Repeat("a", 64), true},
+	}
+	for _, tt := range tests {
+		if err := validateBucketName(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("validateBucketName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPublicAccessString(t *testing.T) {
+	tests := []struct {
+		cfg  *types.PublicAccessBlockConfiguration
+		want string
+	}{
+		{nil, "Not configured"},
+		{&types.PublicAccessBlockConfiguration{}, "Off"},
+		{&types.PublicAccessBlockConfiguration{
+			BlockPublicAcls: aws.Bool(true), IgnorePublicAcls: aws.Bool(true),
+			BlockPublicPolicy: aws.Bool(true), RestrictPublicBuckets: aws.Bool(true),
+		}, "All blocked"},
+		{&types.PublicAccessBlockConfiguration{BlockPublicPolicy: aws.Bool(true)}, "block public policy"},
+	}
+	for _, tt := range tests {
+		if got := publicAccessString(tt.cfg); got != tt.want {
+			t.Errorf("publicAccessString(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestS3Client_ListBucketInfo(t *testing.T) {
+	location := func(loc string) *http.Response {
+		return xmlResponse(http.StatusOK, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`+loc+`</LocationConstraint>`)
+	}
+	withFakeS3(t,
+		xmlResponse(http.StatusOK, `<ListAllMyBucketsResult><Buckets>`+
+			`<Bucket><Name>east</Name></Bucket><Bucket><Name>eu</Name></Bucket>`+
+			`<Bucket><Name>sydney</Name></Bucket><Bucket><Name>tagged</Name><BucketRegion>us-west-2</BucketRegion></Bucket>`+
+			`</Buckets></ListAllMyBucketsResult>`),
+		location(""), location("EU"), location("ap-southeast-2"))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	data, err := c.ListBucketInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"us-east-1", "eu-west-1", "ap-southeast-2", "us-west-2"}
+	for i, b := range data {
+		if i >= len(want) || b.Region != want[i] {
+			t.Errorf("ListBucketInfo() %s region = %q", b.Name, b.Region)
+		}
+	}
+	if len(data) != len(want) {
+		t.Errorf("ListBucketInfo() = %+v", data)
+	}
+}
+
+func TestS3Client_MakeBucket(t *testing.T) {
+	f := withFakeS3(t, xmlResponse(http.StatusOK, ""))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	err := c.MakeBucket(context.Background(), "locked", BucketOptions{Region: "eu-west-1", ObjectLock: true, Versioning: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// object lock turns versioning on by itself
+	if len(f.requests) != 1 {
+		t.Fatalf("MakeBucket() sent %d requests, want 1", len(f.requests))
+	}
+	if got := f.requests[0].Header.Get("X-Amz-Bucket-Object-Lock-Enabled"); got != "true" {
+		t.Errorf("MakeBucket() object lock header = %q, want true", got)
+	}
+
+	f = withFakeS3(t, xmlResponse(http.StatusOK, ""))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	if err = c.MakeBucket(context.Background(), "plain", BucketOptions{Versioning: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.requests) != 2 || !f.requests[1].URL.Query().Has("versioning") {
+		t.Errorf("MakeBucket() requests = %v, want create and versioning", f.urls)
+	}
+}
+
+func TestS3Client_RemoveBucket(t *testing.T) {
+	f := withFakeS3(t,
+		xmlResponse(http.StatusOK, `<ListVersionsResult><IsTruncated>false</IsTruncated>`+
+			`<Version><Key>a</Key><VersionId>1</VersionId></Version>`+
+			`<DeleteMarker><Key>a</Key><VersionId>2</VersionId></DeleteMarker></ListVersionsResult>`),
+		xmlResponse(http.StatusOK, `<DeleteResult></DeleteResult>`),
+		xmlResponse(http.StatusNoContent, ""),
+	)
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	var deleted int
+	err := c.RemoveBucket(context.Background(), "old", "", true, func(n int) {
+		deleted = n
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("RemoveBucket() progress = %d, want 2", deleted)
+	}
+	if len(f.requests) != 3 || f.requests[1].Method != http.MethodPost || f.requests[2].Method != http.MethodDelete {
+		t.Errorf("RemoveBucket() requests = %v, want list, delete objects, delete bucket", f.urls)
+	}
+}
+
+func TestS3Client_Summary(t *testing.T) {
+	withFakeS3(t,
+		xmlResponse(http.StatusOK, `<ListBucketResult><IsTruncated>false</IsTruncated>`+
+			`<Contents><Key>a</Key><Size>10</Size></Contents><Contents><Key>b</Key><Size>5</Size></Contents></ListBucketResult>`),
+		xmlResponse(http.StatusOK, `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`),
+		xmlResponse(http.StatusNotFound, `<Error><Code>ServerSideEncryptionConfigurationNotFoundError</Code></Error>`),
+		xmlResponse(http.StatusForbidden, `<Error><Code>AccessDenied</Code></Error>`),
+	)
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	s := c.Summary(context.Background(), "data", "")
+	if s.Objects != 2 || s.Size != 15 {
+		t.Errorf("Summary() objects = %d, size = %d, want 2, 15", s.Objects, s.Size)
+	}
+	if s.Versioning != "Enabled" || s.Encryption != "None" {
+		t.Errorf("Summary() versioning = %q, encryption = %q", s.Versioning, s.Encryption)
+	}
+	if apiErrorCode(s.Errs["public access"]) != "AccessDenied" {
+		t.Errorf("Summary() public access error = %v, want AccessDenied", s.Errs["public access"])
+	}
+}
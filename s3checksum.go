@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	checksumOff    = "off"
+	checksumMD5    = "md5"
+	checksumCRC32C = "crc32c"
+	checksumSHA256 = "sha256"
+	// only ever reported by the server
+	checksumCRC32     = "crc32"
+	checksumCRC64NVME = "crc64nvme"
+	checksumSHA1      = "sha1"
+)
+
+// checksumAlgorithms are the choices for uploads, md5 is the default as
+// every S3 compatible server checks Content-MD5
+var checksumAlgorithms = []string{checksumMD5, checksumCRC32C, checksumSHA256, checksumOff}
+
+var errChecksumMismatch = errors.New("checksum mismatch, the data was corrupted in transfer")
+
+var crc64NVME = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+func newChecksumHash(alg string) hash.Hash {
+	switch alg {
+	case checksumMD5:
+		return md5.New()
+	case checksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case checksumCRC32:
+		return crc32.NewIEEE()
+	case checksumCRC64NVME:
+		return crc64.New(crc64NVME)
+	case checksumSHA1:
+		return sha1.New()
+	case checksumSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// validChecksum reports whether alg can be chosen for uploads, empty
+// means the default
+func validChecksum(alg string) bool {
+	return alg == "" || slices.Contains(checksumAlgorithms, alg)
+}
+
+// checksumOf returns the base64 sum of rs and rewinds it
+func checksumOf(rs io.ReadSeeker, alg string) (sum string, err error) {
+	h := newChecksumHash(alg)
+	if h == nil {
+		return "", fmt.Errorf("unknown checksum %q", alg)
+	}
+	if _, err = rs.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if _, err = io.Copy(h, rs); err != nil {
+		return
+	}
+	if _, err = rs.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func mismatchError(key, alg, want, got string) error {
+	return fmt.Errorf("%w: %s %s is %s, expected %s", errChecksumMismatch, key, alg, got, want)
+}
+
+// digestError turns the rejection of a sent checksum into a mismatch
+func digestError(key, alg string, err error) error {
+	switch apiErrorCode(err) {
+	case "BadDigest", "InvalidDigest", "XAmzContentChecksumMismatch":
+		return fmt.Errorf("%w: %s %s rejected by the server: %w", errChecksumMismatch, key, alg, err)
+	}
+	return err
+}
+
+// applyChecksum sets sum of alg on a put
+func applyChecksum(input *s3.PutObjectInput, alg, sum string) {
+	switch alg {
+	case checksumMD5:
+		input.ContentMD5 = &sum
+	case checksumCRC32C:
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmCrc32c
+		input.ChecksumCRC32C = &sum
+	case checksumSHA256:
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+		input.ChecksumSHA256 = &sum
+	}
+}
+
+// checkPutChecksum compares the checksum the server stored with the one
+// sent, servers ignoring the header echo nothing
+func checkPutChecksum(out *s3.PutObjectOutput, key, alg, sum string) error {
+	var got *string
+	switch alg {
+	case checksumCRC32C:
+		got = out.ChecksumCRC32C
+	case checksumSHA256:
+		got = out.ChecksumSHA256
+	}
+	if got != nil && *got != "" && *got != sum {
+		return mismatchError(key, alg, sum, *got)
+	}
+	return nil
+}
+
+// removeChecksumValidation drops the SDK validation of downloads, Download
+// checks the same headers itself to report a mismatch as such
+func removeChecksumValidation(stack *middleware.Stack) error {
+	stack.Deserialize.Remove("AWSChecksum:ValidateOutputPayloadChecksum")
+	return nil
+}
+
+// downloadCheck hashes a download to compare it with the checksum the
+// server reported
+type downloadCheck struct {
+	alg  string
+	want string
+	h    hash.Hash
+	// etag sums are hex, the others base64
+	hex bool
+}
+
+// newDownloadCheck picks the strongest checksum of the whole object, nil if
+// there is none. Checksums of multipart uploads cover the parts and ETags
+// are only MD5 sums of unencrypted single part objects
+func newDownloadCheck(out *s3.GetObjectOutput, trustETag bool) *downloadCheck {
+	if out.ChecksumType != types.ChecksumTypeComposite {
+		for _, c := range []struct {
+			alg string
+			sum *string
+		}{
+			{checksumSHA256, out.ChecksumSHA256},
+			{checksumSHA1, out.ChecksumSHA1},
+			{checksumCRC64NVME, out.ChecksumCRC64NVME},
+			{checksumCRC32C, out.ChecksumCRC32C},
+			{checksumCRC32, out.ChecksumCRC32},
+		} {
+			if c.sum != nil && *c.sum != "" && !strings.Contains(*c.sum, "-") {
+				return &downloadCheck{alg: c.alg, want: *c.sum, h: newChecksumHash(c.alg)}
+			}
+		}
+	}
+	if !trustETag || out.ETag == nil || out.SSECustomerAlgorithm != nil {
+		return nil
+	}
+	switch out.ServerSideEncryption {
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+		return nil
+	}
+	etag := strings.ToLower(strings.Trim(*out.ETag, `"`))
+	if _, err := hex.DecodeString(etag); err != nil || len(etag) != 2*md5.Size {
+		return nil
+	}
+	return &downloadCheck{alg: checksumMD5, want: etag, h: md5.New(), hex: true}
+}
+
+func (dc *downloadCheck) verify(key string) error {
+	sum := dc.h.Sum(nil)
+	got := base64.StdEncoding.EncodeToString(sum)
+	if dc.hex {
+		got = hex.EncodeToString(sum)
+	}
+	if got != dc.want {
+		return mismatchError(key, dc.alg, dc.want, got)
+	}
+	return nil
+}
+
+// SetChecksum sets the checksum sent with uploads, off also skips checking
+// downloads
+func (c *S3Client) SetChecksum(alg string) {
+	if alg == "" {
+		alg = checksumMD5
+	}
+	c.checksum.Store(&alg)
+}
+
+// Checksum returns the checksum sent with uploads
+func (c *S3Client) Checksum() string {
+	if alg := c.checksum.Load(); alg != nil {
+		return *alg
+	}
+	return checksumMD5
+}
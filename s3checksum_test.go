@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestChecksumOf(t *testing.T) {
+	tests := []struct {
+		alg  string
+		want string
+	}{
+		{checksumMD5, "XUFAKrxLKna5cZ2REBfFkg=="},
+		{checksumSHA256, "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="},
+		{checksumCRC32C, "mnG7TA=="},
+	}
+	for _, tt := range tests {
+		rs := strings.NewReader("hello")
+		rs.Seek(2, 0)
+		got, err := checksumOf(rs, tt.alg)
+		if err != nil {
+			t.Fatalf("checksumOf(%s) error = %v", tt.alg, err)
+		}
+		if got != tt.want {
+			t.Errorf("checksumOf(%s) = %v, want %v", tt.alg, got, tt.want)
+		}
+		if rs.Len() != 5 {
+			t.Errorf("checksumOf(%s) left %d bytes to read, want 5", tt.alg, rs.Len())
+		}
+	}
+	if _, err := checksumOf(strings.NewReader(""), "adler32"); err == nil {
+		t.Error("checksumOf(adler32) error = nil")
+	}
+}
+
+func TestS3Client_UploadChecksum(t *testing.T) {
+	ok := func(header http.Header) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Header: header}
+	}
+	tests := []struct {
+		name     string
+		alg      string
+		resp     *http.Response
+		header   string
+		want     string
+		mismatch bool
+	}{
+		{"md5", "", ok(http.Header{}), "Content-Md5", "XUFAKrxLKna5cZ2REBfFkg==", false},
+		{"crc32c", checksumCRC32C, ok(http.Header{}), "X-Amz-Checksum-Crc32c", "mnG7TA==", false},
+		{"sha256", checksumSHA256, ok(http.Header{}), "X-Amz-Checksum-Sha256", "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=", false},
+		{"off", checksumOff, ok(http.Header{}), "Content-Md5", "", false},
+		{"bad digest", "", xmlResponse(http.StatusBadRequest, "<Error><Code>BadDigest</Code><Message>The Content-MD5 you specified did not match what we received.</Message></Error>"), "Content-Md5", "XUFAKrxLKna5cZ2REBfFkg==", true},
+		{"stored other", checksumCRC32C, ok(http.Header{"X-Amz-Checksum-Crc32c": {"AAAAAA=="}}), "X-Amz-Checksum-Crc32c", "mnG7TA==", true},
+	}
+	for _, tt := range tests {
+		f := withFakeS3(t, tt.resp)
+		c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1, Checksum: tt.alg})
+		c.Bucket = "bucket"
+		err := c.Upload(context.Background(), strings.NewReader("hello"), "a.txt", "")
+		if errors.Is(err, errChecksumMismatch) != tt.mismatch || (err != nil && !tt.mismatch) {
+			t.Errorf("Upload(%s) error = %v, want mismatch %v", tt.name, err, tt.mismatch)
+		}
+		h := f.requests[0].Header
+		if got := h.Get(tt.header); got != tt.want {
+			t.Errorf("Upload(%s) %s = %q, want %q", tt.name, tt.header, got, tt.want)
+		}
+		if got := h.Get("X-Amz-Trailer"); got != "" {
+			t.Errorf("Upload(%s) trailer = %q, want none", tt.name, got)
+		}
+		if string(f.bodies[0]) != "hello" {
+			t.Errorf("Upload(%s) body = %q, want hello", tt.name, f.bodies[0])
+		}
+	}
+}
+
+func TestS3Client_DownloadChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		header   http.Header
+		mismatch bool
+	}{
+		{"none", "", http.Header{}, false},
+		{"sha256", "", http.Header{"X-Amz-Checksum-Sha256": {"LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="}}, false},
+		{"sha256 wrong", "", http.Header{"X-Amz-Checksum-Sha256": {"LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="[:40] + "AAAA"}}, true},
+		{"crc32c wrong", "", http.Header{"X-Amz-Checksum-Crc32c": {"AAAAAA=="}}, true},
+		{"crc64nvme", "", http.Header{"X-Amz-Checksum-Crc64nvme": {"AAAAAAAAAAA="}, "X-Amz-Checksum-Type": {"FULL_OBJECT"}}, true},
+		{"composite", "", http.Header{"X-Amz-Checksum-Crc32c": {"AAAAAA==-2"}, "X-Amz-Checksum-Type": {"COMPOSITE"}}, false},
+		{"etag", "", http.Header{"Etag": {`"5d41402abc4b2a76b9719d911017c592"`}}, false},
+		{"etag wrong", "", http.Header{"Etag": {`"00000000000000000000000000000000"`}}, true},
+		{"etag multipart", "", http.Header{"Etag": {`"00000000000000000000000000000000-2"`}}, false},
+		{"etag kms", "", http.Header{"Etag": {`"00000000000000000000000000000000"`}, "X-Amz-Server-Side-Encryption": {"aws:kms"}}, false},
+		{"etag sse-s3 aws", "https://s3.amazonaws.com", http.Header{"Etag": {`"00000000000000000000000000000000"`}, "X-Amz-Server-Side-Encryption": {"AES256"}}, true},
+		{"etag sse-s3 minio", "http://minio.local:9000", http.Header{"Etag": {`"00000000000000000000000000000000"`}, "X-Amz-Server-Side-Encryption": {"AES256"}}, false},
+	}
+	for _, tt := range tests {
+		tt.header.Set("Content-Length", "5")
+		f := withFakeS3(t, &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     tt.header,
+			Body:       io.NopCloser(strings.NewReader("hello")),
+		})
+		endpoint := tt.endpoint
+		if endpoint == "" {
+			endpoint = "http://minio.local:9000"
+		}
+		c := NewClientWithOptions("ak", "sk", "us-east-1", endpoint, S3Options{MaxAttempts: 1, Addressing: addressingPath})
+		c.Bucket = "bucket"
+		var buf bytes.Buffer
+		err := c.Download(context.Background(), &buf, "a.txt")
+		if errors.Is(err, errChecksumMismatch) != tt.mismatch || (err != nil && !tt.mismatch) {
+			t.Errorf("Download(%s) error = %v, want mismatch %v", tt.name, err, tt.mismatch)
+		}
+		if buf.String() != "hello" {
+			t.Errorf("Download(%s) = %q, want hello", tt.name, buf.String())
+		}
+		if got := f.requests[0].Header.Get("X-Amz-Checksum-Mode"); got != "ENABLED" {
+			t.Errorf("Download(%s) checksum mode = %q, want ENABLED", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// classDefault leaves the storage class of uploads to the bucket
+const classDefault = "Default"
+
+var storageClasses = []string{
+	string(types.StorageClassStandard),
+	string(types.StorageClassStandardIa),
+	string(types.StorageClassOnezoneIa),
+	string(types.StorageClassIntelligentTiering),
+	string(types.StorageClassGlacierIr),
+	string(types.StorageClassGlacier),
+	string(types.StorageClassDeepArchive),
+	string(types.StorageClassReducedRedundancy),
+}
+
+var restoreTiers = []string{
+	string(types.TierStandard),
+	string(types.TierBulk),
+	string(types.TierExpedited),
+}
+
+var errArchived = errors.New("object is archived, restore it before downloading")
+
+// isArchived reports whether objects of class must be restored before they
+// can be read
+func isArchived(class string) bool {
+	return class == string(types.StorageClassGlacier) || class == string(types.StorageClassDeepArchive)
+}
+
+// archivedError explains the InvalidObjectState error of reading an
+// archived object
+func archivedError(err error) error {
+	if apiErrorCode(err) == "InvalidObjectState" {
+		return fmt.Errorf("%w: %w", errArchived, err)
+	}
+	return err
+}
+
+// RestoreStatus is the x-amz-restore header of an archived object
+type RestoreStatus struct {
+	Ongoing bool
+	// Expiry is when the restored copy is removed again
+	Expiry time.Time
+}
+
+// parseRestore reads a header like
+// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+func parseRestore(h string) (rs RestoreStatus, ok bool) {
+	h = strings.TrimSpace(h)
+	for h != "" {
+		name, rest, found := strings.Cut(h, "=")
+		if !found {
+			return rs, false
+		}
+		name = strings.TrimSpace(name)
+		value := rest
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return rs, false
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		switch name {
+		case "ongoing-request":
+			ongoing, err := strconv.ParseBool(value)
+			if err != nil {
+				return rs, false
+			}
+			rs.Ongoing = ongoing
+			ok = true
+		case "expiry-date":
+			if t, err := time.Parse(time.RFC1123, value); err == nil {
+				rs.Expiry = t
+			}
+		}
+		h = strings.TrimLeft(strings.TrimSpace(rest), ",")
+		h = strings.TrimSpace(h)
+	}
+	return
+}
+
+func (rs RestoreStatus) String() string {
+	if rs.Ongoing {
+		return "in progress"
+	}
+	if rs.Expiry.IsZero() {
+		return "restored"
+	}
+	return "restored until " + rs.Expiry.Local().Format("2006-01-02 15:04")
+}
+
+// SetUploadClass sets the storage class of new objects, "" or classDefault
+// for the bucket default
+func (c *S3Client) SetUploadClass(class string) {
+	if class == classDefault {
+		class = ""
+	}
+	c.uploadClass.Store(&class)
+}
+
+// Restore asks for a temporary copy of an archived object for days, the
+// progress shows in the restore status of its properties
+func (c *S3Client) Restore(ctx context.Context, key string, days int, tier string) (err error) {
+	if c.Prefix != "" {
+		key = c.Prefix + key
+	}
+	err = c.followRedirect(func() (err error) {
+		_, err = c.RestoreObject(ctx, &s3.RestoreObjectInput{
+			Bucket: aws.String(c.Bucket),
+			Key:    aws.String(key),
+			RestoreRequest: &types.RestoreRequest{
+				Days: aws.Int32(int32(days)),
+				GlacierJobParameters: &types.GlacierJobParameters{
+					Tier: types.Tier(tier),
+				},
+			},
+		}, c.withRegion)
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("restore %s error %w", key, err)
+	}
+	return
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRestore(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   RestoreStatus
+		wantOK bool
+	}{
+		{in: `ongoing-request="true"`, want: RestoreStatus{Ongoing: true}, wantOK: true},
+		{
+			in:     `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`,
+			want:   RestoreStatus{Expiry: time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)},
+			wantOK: true,
+		},
+		{in: "", wantOK: false},
+		{in: `ongoing-request="maybe"`, wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRestore(tt.in)
+		if ok != tt.wantOK || got.Ongoing != tt.want.Ongoing || !got.Expiry.Equal(tt.want.Expiry) {
+			t.Errorf("parseRestore(%q) = %+v, %v, want %+v, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestS3Client_DownloadArchived(t *testing.T) {
+	withFakeS3(t, &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header:     http.Header{},
+		Body: io.NopCloser(strings.NewReader(`<Error><Code>InvalidObjectState</Code>` +
+			`<Message>The operation is not valid for the object's storage class</Message></Error>`)),
+	})
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	err := c.Download(context.Background(), io.Discard, "old.tar")
+	if !errors.Is(err, errArchived) {
+		t.Errorf("Download() error = %v, want errArchived", err)
+	}
+}
+
+func TestS3Client_StorageClass(t *testing.T) {
+	f := withFakeS3(t, &http.Response{StatusCode: http.StatusOK, Header: http.Header{}})
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{})
+	c.Bucket = "bucket"
+	c.SetUploadClass("GLACIER")
+	if err := c.Upload(context.Background(), bytes.NewReader([]byte("x")), "a.txt", ""); err != nil {
+		t.Fatal(err)
+	}
+	c.SetUploadClass(classDefault)
+	if err := c.Upload(context.Background(), bytes.NewReader([]byte("x")), "b.txt", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.requests[0].Header.Get("X-Amz-Storage-Class"); got != "GLACIER" {
+		t.Errorf("Upload() storage class = %q, want GLACIER", got)
+	}
+	if got := f.requests[1].Header.Get("X-Amz-Storage-Class"); got != "" {
+		t.Errorf("Upload() default storage class = %q, want none", got)
+	}
+
+	f = withFakeS3(t, &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`<CopyObjectResult><ETag>"x"</ETag></CopyObjectResult>`)),
+	})
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{})
+	c.Bucket = "bucket"
+	old := ObjectProps{StorageClass: "STANDARD", ContentType: "text/plain"}
+	p := old
+	p.StorageClass = "STANDARD_IA"
+	if err := c.SetProperties(context.Background(), "a.txt", old, p); err != nil {
+		t.Fatal(err)
+	}
+	h := f.requests[0].Header
+	if h.Get("X-Amz-Storage-Class") != "STANDARD_IA" || h.Get("X-Amz-Metadata-Directive") != "COPY" {
+		t.Errorf("SetProperties() class change headers = %v", h)
+	}
+}
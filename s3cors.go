@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var corsMethods = []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodHead}
+
+// CORSRule is a bucket CORS rule, MaxAgeSeconds of 0 is unset
+type CORSRule struct {
+	ID             string
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposeHeaders  []string
+	MaxAgeSeconds  int
+}
+
+// validateCORS checks the rules the way S3 does before saving
+func validateCORS(rules []CORSRule) error {
+	if len(rules) > 100 {
+		return fmt.Errorf("%d CORS rules, at most 100 are allowed", len(rules))
+	}
+	for i, r := range rules {
+		name := r.ID
+		if name == "" {
+			name = fmt.Sprint(i + 1)
+		}
+		if len(r.AllowedOrigins) == 0 {
+			return fmt.Errorf("rule %s: allowed origin required", name)
+		}
+		for _, o := range r.AllowedOrigins {
+			if strings.Count(o, "*") > 1 {
+				return fmt.Errorf("rule %s: origin %s has more than one *", name, o)
+			}
+		}
+		if len(r.AllowedMethods) == 0 {
+			return fmt.Errorf("rule %s: allowed method required", name)
+		}
+		for _, m := range r.AllowedMethods {
+			if !slices.Contains(corsMethods, m) {
+				return fmt.Errorf("rule %s: unknown method %s", name, m)
+			}
+		}
+		for _, h := range r.AllowedHeaders {
+			if strings.Count(h, "*") > 1 {
+				return fmt.Errorf("rule %s: header %s has more than one *", name, h)
+			}
+		}
+		if r.MaxAgeSeconds < 0 {
+			return fmt.Errorf("rule %s: negative max age", name)
+		}
+	}
+	return nil
+}
+
+// wildcardMatch matches s against pattern with at most one *
+func wildcardMatch(pattern, s string) bool {
+	prefix, suffix, found := strings.Cut(pattern, "*")
+	if !found {
+		return pattern == s
+	}
+	return len(s) >= len(prefix)+len(suffix) && strings.HasPrefix(s, prefix) && strings.HasSuffix(s, suffix)
+}
+
+// matchCORS returns the index of the first rule allowing a preflight from
+// origin for method and headers, or -1
+func matchCORS(rules []CORSRule, origin, method string, headers []string) int {
+	for i, r := range rules {
+		if !slices.ContainsFunc(r.AllowedOrigins, func(o string) bool { return wildcardMatch(o, origin) }) {
+			continue
+		}
+		if !slices.Contains(r.AllowedMethods, method) {
+			continue
+		}
+		allowed := true
+		for _, h := range headers {
+			h = strings.ToLower(h)
+			if !slices.ContainsFunc(r.AllowedHeaders, func(a string) bool { return wildcardMatch(strings.ToLower(a), h) }) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			return i
+		}
+	}
+	return -1
+}
+
+// CORS reads the CORS rules of the bucket, none if it has no configuration
+func (c *S3Client) CORS(ctx context.Context) (rules []CORSRule, err error) {
+	var out *s3.GetBucketCorsOutput
+	err = c.followRedirect(func() (err error) {
+		out, err = c.GetBucketCors(ctx, &s3.GetBucketCorsInput{Bucket: aws.String(c.Bucket)}, c.withRegion)
+		return
+	})
+	if apiErrorCode(err) == "NoSuchCORSConfiguration" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get cors of %s error %w", c.Bucket, err)
+	}
+	for _, r := range out.CORSRules {
+		rules = append(rules, CORSRule{
+			ID:             aws.ToString(r.ID),
+			AllowedOrigins: r.AllowedOrigins,
+			AllowedMethods: r.AllowedMethods,
+			AllowedHeaders: r.AllowedHeaders,
+			ExposeHeaders:  r.ExposeHeaders,
+			MaxAgeSeconds:  int(aws.ToInt32(r.MaxAgeSeconds)),
+		})
+	}
+	return
+}
+
+// SetCORS validates and replaces the CORS rules of the bucket, no rules
+// delete the configuration
+func (c *S3Client) SetCORS(ctx context.Context, rules []CORSRule) (err error) {
+	if err = validateCORS(rules); err != nil {
+		return
+	}
+	if len(rules) == 0 {
+		err = c.followRedirect(func() (err error) {
+			_, err = c.DeleteBucketCors(ctx, &s3.DeleteBucketCorsInput{Bucket: aws.String(c.Bucket)}, c.withRegion)
+			return
+		})
+	} else {
+		cfg := &types.CORSConfiguration{}
+		for _, r := range rules {
+			rule := types.CORSRule{
+				AllowedOrigins: r.AllowedOrigins,
+				AllowedMethods: r.AllowedMethods,
+				AllowedHeaders: r.AllowedHeaders,
+				ExposeHeaders:  r.ExposeHeaders,
+			}
+			if r.ID != "" {
+				rule.ID = aws.String(r.ID)
+			}
+			if r.MaxAgeSeconds > 0 {
+				rule.MaxAgeSeconds = aws.Int32(int32(r.MaxAgeSeconds))
+			}
+			cfg.CORSRules = append(cfg.CORSRules, rule)
+		}
+		err = c.followRedirect(func() (err error) {
+			_, err = c.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+				Bucket:            aws.String(c.Bucket),
+				CORSConfiguration: cfg,
+			}, c.withRegion)
+			return
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("set cors of %s error %w", c.Bucket, err)
+	}
+	return
+}
+
+// PreflightResult is the answer to a CORS preflight
+type PreflightResult struct {
+	Status int
+	// Headers are the Access-Control-* response headers
+	Headers http.Header
+}
+
+// PreflightCORS sends the OPTIONS request a browser would send before
+// calling method on key from origin with headers
+func (c *S3Client) PreflightCORS(ctx context.Context, key, origin, method string, headers []string) (res PreflightResult, err error) {
+	if c.Prefix != "" {
+		key = c.Prefix + key
+	}
+	// the presigned URL has the endpoint and addressing of every other call
+	ps := s3.NewPresignClient(c.Client, s3.WithPresignClientFromClientOptions(c.withRegion))
+	signed, err := ps.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(c.Bucket), Key: aws.String(key)})
+	if err != nil {
+		return res, fmt.Errorf("preflight %s error %w", key, err)
+	}
+	u, err := url.Parse(signed.URL)
+	if err != nil {
+		return res, fmt.Errorf("preflight %s error %w", key, err)
+	}
+	u.RawQuery = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, u.String(), nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if len(headers) > 0 {
+		req.Header.Set("Access-Control-Request-Headers", strings.Join(headers, ", "))
+	}
+	resp, err := c.Options().HTTPClient.Do(req)
+	if err != nil {
+		return res, fmt.Errorf("preflight %s error %w", key, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	res.Status = resp.StatusCode
+	res.Headers = http.Header{}
+	for k, v := range resp.Header {
+		if strings.HasPrefix(k, "Access-Control-") {
+			res.Headers[k] = v
+		}
+	}
+	return
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []CORSRule
+		ok    bool
+	}{
+		{"none", nil, true},
+		{"valid", []CORSRule{{AllowedOrigins: []string{"https://*.example.com"}, AllowedMethods: []string{"GET"}}}, true},
+		{"no origin", []CORSRule{{AllowedMethods: []string{"GET"}}}, false},
+		{"no method", []CORSRule{{AllowedOrigins: []string{"*"}}}, false},
+		{"bad method", []CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"PATCH"}}}, false},
+		{"two wildcards", []CORSRule{{AllowedOrigins: []string{"https://*.*.com"}, AllowedMethods: []string{"GET"}}}, false},
+		{"negative max age", []CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}, MaxAgeSeconds: -1}}, false},
+	}
+	for _, tt := range tests {
+		if err := validateCORS(tt.rules); (err == nil) != tt.ok {
+			t.Errorf("validateCORS(%s) error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestMatchCORS(t *testing.T) {
+	rules := []CORSRule{
+		{AllowedOrigins: []string{"https://app.example.com"}, AllowedMethods: []string{"PUT", "POST"}, AllowedHeaders: []string{"Content-*", "x-amz-date"}},
+		{AllowedOrigins: []string{"https://*.example.com"}, AllowedMethods: []string{"GET"}},
+		{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"HEAD"}, AllowedHeaders: []string{"*"}},
+	}
+	tests := []struct {
+		origin  string
+		method  string
+		headers []string
+		want    int
+	}{
+		{"https://app.example.com", "PUT", []string{"content-type", "X-Amz-Date"}, 0},
+		{"https://app.example.com", "PUT", []string{"authorization"}, -1},
+		{"https://cdn.example.com", "GET", nil, 1},
+		{"https://cdn.example.com", "GET", []string{"range"}, -1},
+		{"https://example.com", "GET", nil, -1},
+		{"http://other.org", "HEAD", []string{"anything"}, 2},
+		{"http://other.org", "DELETE", nil, -1},
+	}
+	for _, tt := range tests {
+		if got := matchCORS(rules, tt.origin, tt.method, tt.headers); got != tt.want {
+			t.Errorf("matchCORS(%s, %s, %v) = %d, want %d", tt.origin, tt.method, tt.headers, got, tt.want)
+		}
+	}
+}
+
+func TestS3Client_CORS(t *testing.T) {
+	withFakeS3(t, xmlResponse(http.StatusNotFound, `<Error><Code>NoSuchCORSConfiguration</Code></Error>`))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	rules, err := c.CORS(context.Background())
+	if err != nil || len(rules) != 0 {
+		t.Errorf("CORS() = %v, %v, want no rules", rules, err)
+	}
+
+	withFakeS3(t, xmlResponse(http.StatusOK, `<CORSConfiguration><CORSRule><ID>web</ID>`+
+		`<AllowedOrigin>https://example.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod>`+
+		`<MaxAgeSeconds>300</MaxAgeSeconds></CORSRule></CORSConfiguration>`))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	rules, err = c.CORS(context.Background())
+	if err != nil || len(rules) != 1 || rules[0].ID != "web" || rules[0].MaxAgeSeconds != 300 {
+		t.Errorf("CORS() = %+v, %v", rules, err)
+	}
+
+	f := withFakeS3(t, xmlResponse(http.StatusNoContent, ""))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	if err = c.SetCORS(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if f.requests[0].Method != http.MethodDelete {
+		t.Errorf("SetCORS(nil) method = %s, want DELETE", f.requests[0].Method)
+	}
+}
+
+func TestS3Client_PreflightCORS(t *testing.T) {
+	f := withFakeS3(t, &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"Access-Control-Allow-Origin":  {"https://example.com"},
+		"Access-Control-Allow-Methods": {"GET"},
+		"X-Amz-Request-Id":             {"1"},
+	}})
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	res, err := c.PreflightCORS(context.Background(), "dir/a b.txt", "https://example.com", "GET", []string{"range"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := f.requests[0]
+	if req.Method != http.MethodOptions || req.URL.RawQuery != "" || f.urls[0] != "minio.local:9000/bucket/dir/a b.txt" {
+		t.Errorf("PreflightCORS() request = %s %s?%s", req.Method, f.urls[0], req.URL.RawQuery)
+	}
+	if req.Header.Get("Origin") != "https://example.com" || req.Header.Get("Access-Control-Request-Method") != "GET" ||
+		req.Header.Get("Access-Control-Request-Headers") != "range" {
+		t.Errorf("PreflightCORS() headers = %v", req.Header)
+	}
+	if res.Status != http.StatusOK || len(res.Headers) != 2 {
+		t.Errorf("PreflightCORS() = %+v", res)
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var transitionClasses = []string{
+	string(types.TransitionStorageClassStandardIa),
+	string(types.TransitionStorageClassOnezoneIa),
+	string(types.TransitionStorageClassIntelligentTiering),
+	string(types.TransitionStorageClassGlacierIr),
+	string(types.TransitionStorageClassGlacier),
+	string(types.TransitionStorageClassDeepArchive),
+}
+
+// Transition moves objects to StorageClass Days after their creation
+type Transition struct {
+	Days         int
+	StorageClass string
+}
+
+// LifecycleRule is the editable part of a bucket lifecycle rule, days of 0
+// are unset. Settings the editor doesn't show, like dates and size
+// filters, are kept from the rule that was read.
+type LifecycleRule struct {
+	ID          string
+	Enabled     bool
+	Prefix      string
+	Tags        map[string]string
+	Transitions []Transition
+
+	ExpirationDays           int
+	NoncurrentExpirationDays int
+	AbortMultipartDays       int
+
+	raw types.LifecycleRule
+}
+
+func lifecycleRuleFromAWS(r types.LifecycleRule) LifecycleRule {
+	rule := LifecycleRule{
+		ID:      aws.ToString(r.ID),
+		Enabled: r.Status == types.ExpirationStatusEnabled,
+		Prefix:  aws.ToString(r.Prefix),
+		Tags:    map[string]string{},
+		raw:     r,
+	}
+	if f := r.Filter; f != nil {
+		rule.Prefix = aws.ToString(f.Prefix)
+		if f.Tag != nil {
+			rule.Tags[aws.ToString(f.Tag.Key)] = aws.ToString(f.Tag.Value)
+		}
+		if f.And != nil {
+			rule.Prefix = aws.ToString(f.And.Prefix)
+			for _, t := range f.And.Tags {
+				rule.Tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+			}
+		}
+	}
+	for _, t := range r.Transitions {
+		if t.Days != nil {
+			rule.Transitions = append(rule.Transitions, Transition{Days: int(*t.Days), StorageClass: string(t.StorageClass)})
+		}
+	}
+	if r.Expiration != nil {
+		rule.ExpirationDays = int(aws.ToInt32(r.Expiration.Days))
+	}
+	if r.NoncurrentVersionExpiration != nil {
+		rule.NoncurrentExpirationDays = int(aws.ToInt32(r.NoncurrentVersionExpiration.NoncurrentDays))
+	}
+	if r.AbortIncompleteMultipartUpload != nil {
+		rule.AbortMultipartDays = int(aws.ToInt32(r.AbortIncompleteMultipartUpload.DaysAfterInitiation))
+	}
+	return rule
+}
+
+// sizeFilter returns the object size limits of the rule that was read
+func (r *LifecycleRule) sizeFilter() (greater, less *int64) {
+	if f := r.raw.Filter; f != nil {
+		if f.And != nil {
+			return f.And.ObjectSizeGreaterThan, f.And.ObjectSizeLessThan
+		}
+		return f.ObjectSizeGreaterThan, f.ObjectSizeLessThan
+	}
+	return nil, nil
+}
+
+func (r *LifecycleRule) toAWS() types.LifecycleRule {
+	out := r.raw
+	out.ID = aws.String(r.ID)
+	out.Status = types.ExpirationStatusDisabled
+	if r.Enabled {
+		out.Status = types.ExpirationStatusEnabled
+	}
+
+	// a single condition stands alone, more need an And
+	out.Prefix = nil
+	greater, less := r.sizeFilter()
+	var tags []types.Tag
+	for _, k := range slices.Sorted(maps.Keys(r.Tags)) {
+		tags = append(tags, types.Tag{Key: aws.String(k), Value: aws.String(r.Tags[k])})
+	}
+	conditions := len(tags)
+	for _, set := range []bool{r.Prefix != "", greater != nil, less != nil} {
+		if set {
+			conditions++
+		}
+	}
+	switch {
+	case conditions > 1:
+		out.Filter = &types.LifecycleRuleFilter{And: &types.LifecycleRuleAndOperator{
+			Tags:                  tags,
+			ObjectSizeGreaterThan: greater,
+			ObjectSizeLessThan:    less,
+		}}
+		if r.Prefix != "" {
+			out.Filter.And.Prefix = aws.String(r.Prefix)
+		}
+	case len(tags) == 1:
+		out.Filter = &types.LifecycleRuleFilter{Tag: &tags[0]}
+	case greater != nil || less != nil:
+		out.Filter = &types.LifecycleRuleFilter{ObjectSizeGreaterThan: greater, ObjectSizeLessThan: less}
+	default:
+		out.Filter = &types.LifecycleRuleFilter{Prefix: aws.String(r.Prefix)}
+	}
+
+	out.Transitions = nil
+	for _, t := range r.raw.Transitions {
+		if t.Days == nil {
+			out.Transitions = append(out.Transitions, t)
+		}
+	}
+	for _, t := range r.Transitions {
+		out.Transitions = append(out.Transitions, types.Transition{
+			Days:         aws.Int32(int32(t.Days)),
+			StorageClass: types.TransitionStorageClass(t.StorageClass),
+		})
+	}
+
+	switch e := r.raw.Expiration; {
+	case r.ExpirationDays > 0:
+		out.Expiration = &types.LifecycleExpiration{Days: aws.Int32(int32(r.ExpirationDays))}
+	case e != nil && (e.Date != nil || e.ExpiredObjectDeleteMarker != nil):
+		out.Expiration = &types.LifecycleExpiration{Date: e.Date, ExpiredObjectDeleteMarker: e.ExpiredObjectDeleteMarker}
+	default:
+		out.Expiration = nil
+	}
+	out.NoncurrentVersionExpiration = nil
+	if r.NoncurrentExpirationDays > 0 {
+		out.NoncurrentVersionExpiration = &types.NoncurrentVersionExpiration{
+			NoncurrentDays: aws.Int32(int32(r.NoncurrentExpirationDays)),
+		}
+		if e := r.raw.NoncurrentVersionExpiration; e != nil {
+			out.NoncurrentVersionExpiration.NewerNoncurrentVersions = e.NewerNoncurrentVersions
+		}
+	}
+	out.AbortIncompleteMultipartUpload = nil
+	if r.AbortMultipartDays > 0 {
+		out.AbortIncompleteMultipartUpload = &types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int32(int32(r.AbortMultipartDays)),
+		}
+	}
+	return out
+}
+
+// hasAction reports whether the rule does anything, including the actions
+// kept from the rule that was read
+func (r *LifecycleRule) hasAction() bool {
+	out := r.toAWS()
+	return len(out.Transitions) > 0 || out.Expiration != nil || out.NoncurrentVersionExpiration != nil ||
+		len(out.NoncurrentVersionTransitions) > 0 || out.AbortIncompleteMultipartUpload != nil
+}
+
+// overlaps reports whether an object can match both filters
+func (r *LifecycleRule) overlaps(o *LifecycleRule) bool {
+	if !strings.HasPrefix(r.Prefix, o.Prefix) && !strings.HasPrefix(o.Prefix, r.Prefix) {
+		return false
+	}
+	for k, v := range r.Tags {
+		if ov, ok := o.Tags[k]; ok && ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// validateLifecycle checks each rule and reports enabled rules that match
+// the same objects with different days for the same action
+func validateLifecycle(rules []LifecycleRule) error {
+	ids := map[string]bool{}
+	for i, r := range rules {
+		if r.ID == "" {
+			return fmt.Errorf("rule %d: id required", i+1)
+		}
+		if len(r.ID) > 255 {
+			return fmt.Errorf("rule %s: id longer than 255 characters", r.ID)
+		}
+		if ids[r.ID] {
+			return fmt.Errorf("rule %s: duplicate id", r.ID)
+		}
+		ids[r.ID] = true
+		if !r.hasAction() {
+			return fmt.Errorf("rule %s: no action", r.ID)
+		}
+		if r.AbortMultipartDays > 0 && len(r.Tags) > 0 {
+			return fmt.Errorf("rule %s: aborting multipart uploads can't be combined with a tag filter", r.ID)
+		}
+		for k := range r.Tags {
+			if k == "" {
+				return fmt.Errorf("rule %s: empty tag key", r.ID)
+			}
+		}
+		classes := map[string]bool{}
+		for _, t := range r.Transitions {
+			if !slices.Contains(transitionClasses, t.StorageClass) {
+				return fmt.Errorf("rule %s: can't transition to %s", r.ID, t.StorageClass)
+			}
+			if classes[t.StorageClass] {
+				return fmt.Errorf("rule %s: more than one transition to %s", r.ID, t.StorageClass)
+			}
+			classes[t.StorageClass] = true
+			if t.Days < 30 && (t.StorageClass == string(types.TransitionStorageClassStandardIa) || t.StorageClass == string(types.TransitionStorageClassOnezoneIa)) {
+				return fmt.Errorf("rule %s: transition to %s needs at least 30 days", r.ID, t.StorageClass)
+			}
+			if r.ExpirationDays > 0 && t.Days >= r.ExpirationDays {
+				return fmt.Errorf("rule %s: transition to %s after the expiration", r.ID, t.StorageClass)
+			}
+		}
+	}
+
+	for i := range rules {
+		for j := i + 1; j < len(rules); j++ {
+			a, b := &rules[i], &rules[j]
+			if !a.Enabled || !b.Enabled || !a.overlaps(b) {
+				continue
+			}
+			if conflict := lifecycleConflict(a, b); conflict != "" {
+				return fmt.Errorf("rules %s and %s match the same objects with different %s", a.ID, b.ID, conflict)
+			}
+		}
+	}
+	return nil
+}
+
+// lifecycleConflict names the action a and b both set with different days
+func lifecycleConflict(a, b *LifecycleRule) string {
+	differ := func(x, y int) bool {
+		return x > 0 && y > 0 && x != y
+	}
+	switch {
+	case differ(a.ExpirationDays, b.ExpirationDays):
+		return "expiration days"
+	case differ(a.NoncurrentExpirationDays, b.NoncurrentExpirationDays):
+		return "noncurrent expiration days"
+	case differ(a.AbortMultipartDays, b.AbortMultipartDays):
+		return "multipart abort days"
+	}
+	for _, ta := range a.Transitions {
+		for _, tb := range b.Transitions {
+			if ta.StorageClass == tb.StorageClass && ta.Days != tb.Days {
+				return "days for the transition to " + ta.StorageClass
+			}
+		}
+	}
+	return ""
+}
+
+// formatTransitions writes one "days class" line per transition
+func formatTransitions(ts []Transition) string {
+	var b strings.Builder
+	for _, t := range ts {
+		fmt.Fprintf(&b, "%d %s\n", t.Days, t.StorageClass)
+	}
+	return b.String()
+}
+
+// parseTransitions reads "days class" lines, blank lines are skipped
+func parseTransitions(s string) (ts []Transition, err error) {
+	for i, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want days and storage class", i+1)
+		}
+		days, err := strconv.Atoi(fields[0])
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("line %d: invalid days %q", i+1, fields[0])
+		}
+		class := strings.ToUpper(fields[1])
+		if !slices.Contains(transitionClasses, class) {
+			return nil, fmt.Errorf("line %d: use one of %s", i+1, strings.Join(transitionClasses, ", "))
+		}
+		ts = append(ts, Transition{Days: days, StorageClass: class})
+	}
+	return
+}
+
+// parseDays reads a day count, empty is 0 for unset
+func parseDays(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(s)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("invalid days %q", s)
+	}
+	return days, nil
+}
+
+// Lifecycle reads the lifecycle rules of the bucket, none if it has no
+// configuration
+func (c *S3Client) Lifecycle(ctx context.Context) (rules []LifecycleRule, err error) {
+	var out *s3.GetBucketLifecycleConfigurationOutput
+	err = c.followRedirect(func() (err error) {
+		out, err = c.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+			Bucket: aws.String(c.Bucket),
+		}, c.withRegion)
+		return
+	})
+	if apiErrorCode(err) == "NoSuchLifecycleConfiguration" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get lifecycle of %s error %w", c.Bucket, err)
+	}
+	for _, r := range out.Rules {
+		rules = append(rules, lifecycleRuleFromAWS(r))
+	}
+	return
+}
+
+// SetLifecycle validates and replaces the lifecycle rules of the bucket,
+// no rules delete the configuration
+func (c *S3Client) SetLifecycle(ctx context.Context, rules []LifecycleRule) (err error) {
+	if err = validateLifecycle(rules); err != nil {
+		return
+	}
+	if len(rules) == 0 {
+		err = c.followRedirect(func() (err error) {
+			_, err = c.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+				Bucket: aws.String(c.Bucket),
+			}, c.withRegion)
+			return
+		})
+	} else {
+		cfg := &types.BucketLifecycleConfiguration{}
+		for i := range rules {
+			cfg.Rules = append(cfg.Rules, rules[i].toAWS())
+		}
+		err = c.followRedirect(func() (err error) {
+			_, err = c.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+				Bucket:                 aws.String(c.Bucket),
+				LifecycleConfiguration: cfg,
+			}, c.withRegion)
+			return
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("set lifecycle of %s error %w", c.Bucket, err)
+	}
+	return
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestLifecycleRule_RoundTrip(t *testing.T) {
+	date := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	in := types.LifecycleRule{
+		ID:     aws.String("logs"),
+		Status: types.ExpirationStatusEnabled,
+		Filter: &types.LifecycleRuleFilter{And: &types.LifecycleRuleAndOperator{
+			Prefix:                aws.String("logs/"),
+			ObjectSizeGreaterThan: aws.Int64(1024),
+		}},
+		Transitions: []types.Transition{
+			{Days: aws.Int32(30), StorageClass: types.TransitionStorageClassStandardIa},
+			{Date: &date, StorageClass: types.TransitionStorageClassGlacier},
+		},
+		Expiration: &types.LifecycleExpiration{Days: aws.Int32(365)},
+	}
+	r := lifecycleRuleFromAWS(in)
+	if r.Prefix != "logs/" || r.ExpirationDays != 365 || len(r.Transitions) != 1 || r.Transitions[0].Days != 30 {
+		t.Fatalf("lifecycleRuleFromAWS() = %+v", r)
+	}
+
+	r.ExpirationDays = 400
+	r.Tags = map[string]string{"env": "prod"}
+	out := r.toAWS()
+	and := out.Filter.And
+	if and == nil || aws.ToString(and.Prefix) != "logs/" || aws.ToInt64(and.ObjectSizeGreaterThan) != 1024 || len(and.Tags) != 1 {
+		t.Errorf("toAWS() filter = %+v, want prefix, size and tag", out.Filter)
+	}
+	if len(out.Transitions) != 2 || out.Transitions[0].Date == nil {
+		t.Errorf("toAWS() transitions = %+v, want the dated one kept", out.Transitions)
+	}
+	if aws.ToInt32(out.Expiration.Days) != 400 {
+		t.Errorf("toAWS() expiration = %+v, want 400 days", out.Expiration)
+	}
+
+	r = LifecycleRule{ID: "tmp", Prefix: "tmp/", AbortMultipartDays: 7}
+	out = r.toAWS()
+	if out.Filter.And != nil || aws.ToString(out.Filter.Prefix) != "tmp/" || out.Status != types.ExpirationStatusDisabled {
+		t.Errorf("toAWS() = %+v, want a plain prefix filter", out)
+	}
+}
+
+func TestValidateLifecycle(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []LifecycleRule
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"ok", []LifecycleRule{
+			{ID: "a", Enabled: true, Prefix: "logs/", ExpirationDays: 90, Transitions: []Transition{{30, "STANDARD_IA"}}},
+			{ID: "b", Enabled: true, Prefix: "tmp/", ExpirationDays: 1},
+		}, false},
+		{"no id", []LifecycleRule{{ExpirationDays: 1}}, true},
+		{"duplicate id", []LifecycleRule{{ID: "a", ExpirationDays: 1}, {ID: "a", ExpirationDays: 2}}, true},
+		{"no action", []LifecycleRule{{ID: "a"}}, true},
+		{"ia too early", []LifecycleRule{{ID: "a", Transitions: []Transition{{10, "STANDARD_IA"}}}}, true},
+		{"transition after expiration", []LifecycleRule{{ID: "a", ExpirationDays: 60, Transitions: []Transition{{90, "GLACIER"}}}}, true},
+		{"abort with tags", []LifecycleRule{{ID: "a", AbortMultipartDays: 7, Tags: map[string]string{"k": "v"}}}, true},
+		{"overlapping conflict", []LifecycleRule{
+			{ID: "a", Enabled: true, Prefix: "logs/", ExpirationDays: 30},
+			{ID: "b", Enabled: true, Prefix: "logs/app/", ExpirationDays: 60},
+		}, true},
+		{"disjoint tags", []LifecycleRule{
+			{ID: "a", Enabled: true, Tags: map[string]string{"env": "dev"}, ExpirationDays: 30},
+			{ID: "b", Enabled: true, Tags: map[string]string{"env": "prod"}, ExpirationDays: 60},
+		}, false},
+		{"disabled overlap", []LifecycleRule{
+			{ID: "a", Enabled: true, ExpirationDays: 30},
+			{ID: "b", ExpirationDays: 60},
+		}, false},
+	}
+	for _, tt := range tests {
+		if err := validateLifecycle(tt.rules); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateLifecycle() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseTransitions(t *testing.T) {
+	ts, err := parseTransitions("30 standard_ia\n\n90 GLACIER\n")
+	if err != nil || len(ts) != 2 || ts[0] != (Transition{30, "STANDARD_IA"}) || ts[1] != (Transition{90, "GLACIER"}) {
+		t.Errorf("parseTransitions() = %+v, %v", ts, err)
+	}
+	if got := formatTransitions(ts); got != "30 STANDARD_IA\n90 GLACIER\n" {
+		t.Errorf("formatTransitions() = %q", got)
+	}
+	for _, s := range []string{"30", "x GLACIER", "30 STANDARD"} {
+		if _, err := parseTransitions(s); err == nil {
+			t.Errorf("parseTransitions(%q) error = nil", s)
+		}
+	}
+}
+
+func TestS3Client_Lifecycle(t *testing.T) {
+	withFakeS3(t, xmlResponse(http.StatusNotFound, `<Error><Code>NoSuchLifecycleConfiguration</Code></Error>`))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	rules, err := c.Lifecycle(context.Background())
+	if err != nil || len(rules) != 0 {
+		t.Errorf("Lifecycle() = %v, %v, want no rules", rules, err)
+	}
+
+	f := withFakeS3(t, xmlResponse(http.StatusNoContent, ""))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	if err = c.SetLifecycle(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if f.requests[0].Method != http.MethodDelete {
+		t.Errorf("SetLifecycle(nil) method = %s, want DELETE", f.requests[0].Method)
+	}
+	if err = c.SetLifecycle(context.Background(), []LifecycleRule{{ID: "a"}}); err == nil {
+		t.Error("SetLifecycle() without action error = nil")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+var lockModes = []string{
+	string(types.ObjectLockRetentionModeGovernance),
+	string(types.ObjectLockRetentionModeCompliance),
+}
+
+var errLocked = errors.New("object is protected by object lock, see Properties for its retention and legal hold")
+
+// isLockedError reports whether an S3 error code and message come from a
+// retention or legal hold
+func isLockedError(code, message string) bool {
+	return code == "AccessDenied" && strings.Contains(strings.ToLower(message), "object lock")
+}
+
+// lockedError explains the AccessDenied error of deleting a locked object
+func lockedError(err error) error {
+	var ae smithy.APIError
+	if errors.As(err, &ae) && isLockedError(ae.ErrorCode(), ae.ErrorMessage()) {
+		return fmt.Errorf("%w: %w", errLocked, err)
+	}
+	return err
+}
+
+// ObjectLock is the object lock configuration of a bucket
+type ObjectLock struct {
+	Enabled bool
+	// Mode is the default retention of new objects, empty for none, kept
+	// for Days or Years
+	Mode  string
+	Days  int
+	Years int
+}
+
+func (l ObjectLock) String() string {
+	if !l.Enabled {
+		return "disabled"
+	}
+	if l.Mode == "" {
+		return "enabled, no default retention"
+	}
+	period := fmt.Sprintf("%d days", l.Days)
+	if l.Years > 0 {
+		period = fmt.Sprintf("%d years", l.Years)
+	}
+	return fmt.Sprintf("enabled, %s for %s", l.Mode, period)
+}
+
+// Retention is the lock of an object version, Mode is empty for none
+type Retention struct {
+	Mode      string
+	Until     time.Time
+	LegalHold bool
+}
+
+// Locked reports whether the version can't be deleted at now
+func (r Retention) Locked(now time.Time) bool {
+	return r.LegalHold || (r.Mode != "" && r.Until.After(now))
+}
+
+// checkRetention tells whether old can be changed to r: compliance can only
+// be extended, governance needs bypass to be shortened or removed
+func checkRetention(old, r Retention, bypass bool, now time.Time) error {
+	if r.Mode != "" && !r.Until.After(now) {
+		return errors.New("retain until must be in the future")
+	}
+	if old.Mode == "" || !old.Until.After(now) {
+		return nil
+	}
+	compliance := string(types.ObjectLockRetentionModeCompliance)
+	if r.Mode != "" && !r.Until.Before(old.Until) && (old.Mode != compliance || r.Mode == compliance) {
+		return nil
+	}
+	if old.Mode == compliance {
+		return fmt.Errorf("compliance retention until %s can only be extended", old.Until.Local().Format(time.DateTime))
+	}
+	if !bypass {
+		return errors.New("shortening or removing governance retention needs bypass governance")
+	}
+	return nil
+}
+
+// ObjectLock reads the object lock configuration of the bucket, disabled if
+// it has none
+func (c *S3Client) ObjectLock(ctx context.Context) (l ObjectLock, err error) {
+	var out *s3.GetObjectLockConfigurationOutput
+	err = c.followRedirect(func() (err error) {
+		out, err = c.GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{Bucket: aws.String(c.Bucket)}, c.withRegion)
+		return
+	})
+	if apiErrorCode(err) == "ObjectLockConfigurationNotFoundError" {
+		return l, nil
+	}
+	if err != nil {
+		return l, fmt.Errorf("get object lock of %s error %w", c.Bucket, err)
+	}
+	cfg := out.ObjectLockConfiguration
+	if cfg == nil {
+		return
+	}
+	l.Enabled = cfg.ObjectLockEnabled == types.ObjectLockEnabledEnabled
+	if cfg.Rule != nil && cfg.Rule.DefaultRetention != nil {
+		d := cfg.Rule.DefaultRetention
+		l.Mode = string(d.Mode)
+		l.Days = int(aws.ToInt32(d.Days))
+		l.Years = int(aws.ToInt32(d.Years))
+	}
+	return
+}
+
+// SetRetention changes the retention and legal hold of the current version
+// of key from old to r, bypass allows shortening governance retention
+func (c *S3Client) SetRetention(ctx context.Context, key string, old, r Retention, bypass bool) (err error) {
+	if err = checkRetention(old, r, bypass, time.Now()); err != nil {
+		return
+	}
+	if c.Prefix != "" {
+		key = c.Prefix + key
+	}
+	if r.Mode != old.Mode || !r.Until.Equal(old.Until) {
+		input := &s3.PutObjectRetentionInput{
+			Bucket: aws.String(c.Bucket),
+			Key:    aws.String(key),
+			// an empty retention removes it
+			Retention: &types.ObjectLockRetention{},
+		}
+		if r.Mode != "" {
+			input.Retention.Mode = types.ObjectLockRetentionMode(r.Mode)
+			input.Retention.RetainUntilDate = aws.Time(r.Until)
+		}
+		if bypass {
+			input.BypassGovernanceRetention = aws.Bool(true)
+		}
+		err = c.followRedirect(func() (err error) {
+			_, err = c.PutObjectRetention(ctx, input, c.withRegion)
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("set retention of %s error %w", key, err)
+		}
+	}
+	if r.LegalHold != old.LegalHold {
+		status := types.ObjectLockLegalHoldStatusOff
+		if r.LegalHold {
+			status = types.ObjectLockLegalHoldStatusOn
+		}
+		err = c.followRedirect(func() (err error) {
+			_, err = c.PutObjectLegalHold(ctx, &s3.PutObjectLegalHoldInput{
+				Bucket:    aws.String(c.Bucket),
+				Key:       aws.String(key),
+				LegalHold: &types.ObjectLockLegalHold{Status: status},
+			}, c.withRegion)
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("set legal hold of %s error %w", key, err)
+		}
+	}
+	return
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckRetention(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	soon, later := now.AddDate(0, 1, 0), now.AddDate(1, 0, 0)
+	gov := func(until time.Time) Retention { return Retention{Mode: "GOVERNANCE", Until: until} }
+	comp := func(until time.Time) Retention { return Retention{Mode: "COMPLIANCE", Until: until} }
+	tests := []struct {
+		name   string
+		old, r Retention
+		bypass bool
+		ok     bool
+	}{
+		{"set", Retention{}, gov(soon), false, true},
+		{"past", Retention{}, gov(now.Add(-time.Hour)), false, false},
+		{"extend governance", gov(soon), gov(later), false, true},
+		{"shorten governance", gov(later), gov(soon), false, false},
+		{"shorten governance bypass", gov(later), gov(soon), true, true},
+		{"remove governance bypass", gov(later), Retention{}, true, true},
+		{"governance to compliance", gov(soon), comp(soon), false, true},
+		{"extend compliance", comp(soon), comp(later), false, true},
+		{"shorten compliance", comp(later), comp(soon), true, false},
+		{"compliance to governance", comp(soon), gov(later), true, false},
+		{"remove compliance", comp(soon), Retention{}, true, false},
+		{"expired compliance", comp(now.Add(-time.Hour)), Retention{}, false, true},
+		{"legal hold only", comp(soon), Retention{Mode: "COMPLIANCE", Until: soon, LegalHold: true}, false, true},
+	}
+	for _, tt := range tests {
+		if err := checkRetention(tt.old, tt.r, tt.bypass, now); (err == nil) != tt.ok {
+			t.Errorf("checkRetention(%s) error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestS3Client_ObjectLock(t *testing.T) {
+	withFakeS3(t, xmlResponse(http.StatusNotFound, `<Error><Code>ObjectLockConfigurationNotFoundError</Code></Error>`))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	l, err := c.ObjectLock(context.Background())
+	if err != nil || l.Enabled {
+		t.Errorf("ObjectLock() = %+v, %v, want disabled", l, err)
+	}
+
+	withFakeS3(t, xmlResponse(http.StatusOK, `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>`+
+		`<Rule><DefaultRetention><Mode>COMPLIANCE</Mode><Days>30</Days></DefaultRetention></Rule></ObjectLockConfiguration>`))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	l, err = c.ObjectLock(context.Background())
+	if err != nil || l != (ObjectLock{Enabled: true, Mode: "COMPLIANCE", Days: 30}) {
+		t.Errorf("ObjectLock() = %+v, %v", l, err)
+	}
+	if got := l.String(); got != "enabled, COMPLIANCE for 30 days" {
+		t.Errorf("ObjectLock.String() = %q", got)
+	}
+}
+
+func TestS3Client_SetRetention(t *testing.T) {
+	f := withFakeS3(t, xmlResponse(http.StatusOK, ""))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	err := c.SetRetention(context.Background(), "a.txt", Retention{}, Retention{Mode: "GOVERNANCE", Until: until, LegalHold: true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.requests) != 2 || !f.requests[0].URL.Query().Has("retention") || !f.requests[1].URL.Query().Has("legal-hold") {
+		t.Fatalf("SetRetention() requests = %v", f.requests)
+	}
+	if body := string(f.bodies[0]); !strings.Contains(body, "<Mode>GOVERNANCE</Mode>") {
+		t.Errorf("SetRetention() retention = %s", body)
+	}
+	if body := string(f.bodies[1]); !strings.Contains(body, "<Status>ON</Status>") {
+		t.Errorf("SetRetention() legal hold = %s", body)
+	}
+
+	err = c.SetRetention(context.Background(), "a.txt", Retention{Mode: "GOVERNANCE", Until: until}, Retention{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := f.requests[2]; req.Header.Get("X-Amz-Bypass-Governance-Retention") != "true" {
+		t.Errorf("SetRetention() remove headers = %v", req.Header)
+	}
+}
+
+func TestS3Client_DeleteLocked(t *testing.T) {
+	withFakeS3(t, xmlResponse(http.StatusForbidden,
+		`<Error><Code>AccessDenied</Code><Message>Access Denied because object protected by object lock.</Message></Error>`))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	if err := c.Delete(context.Background(), "a.txt"); !errors.Is(err, errLocked) {
+		t.Errorf("Delete() error = %v, want errLocked", err)
+	}
+
+	withFakeS3(t, xmlResponse(http.StatusForbidden, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	if err := c.Delete(context.Background(), "a.txt"); err == nil || errors.Is(err, errLocked) {
+		t.Errorf("Delete() error = %v, want a plain access error", err)
+	}
+}
+
+func TestS3Client_RemoveBucketLocked(t *testing.T) {
+	withFakeS3(t,
+		xmlResponse(http.StatusOK, `<ListVersionsResult><IsTruncated>false</IsTruncated>`+
+			`<Version><Key>a</Key><VersionId>1</VersionId></Version></ListVersionsResult>`),
+		xmlResponse(http.StatusOK, `<DeleteResult><Error><Key>a</Key><VersionId>1</VersionId><Code>AccessDenied</Code>`+
+			`<Message>Access Denied because object protected by object lock.</Message></Error></DeleteResult>`),
+	)
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	err := c.RemoveBucket(context.Background(), "old", "", true, nil)
+	if !errors.Is(err, errLocked) || !strings.Contains(err.Error(), "1 versions are locked") {
+		t.Errorf("RemoveBucket() error = %v, want errLocked", err)
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/widget"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultMaxBackoff  = 20 * time.Second
+)
+
+// retryAfterBackoff waits as long as a Retry-After header asks, capped at
+// max, and falls back to exponential backoff with jitter
+type retryAfterBackoff struct {
+	max    time.Duration
+	jitter retry.BackoffDelayer
+}
+
+func (b *retryAfterBackoff) BackoffDelay(attempt int, err error) (time.Duration, error) {
+	if d, ok := retryAfter(err, time.Now()); ok {
+		return min(d, b.max), nil
+	}
+	return b.jitter.BackoffDelay(attempt, err)
+}
+
+// retryAfter parses the Retry-After header of a failed response, in seconds
+// or as an HTTP date
+func retryAfter(err error, now time.Time) (time.Duration, bool) {
+	var re *awshttp.ResponseError
+	if !errors.As(err, &re) || re.Response == nil {
+		return 0, false
+	}
+	v := re.Response.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// newRetryer retries throttling, 5xx and connection errors up to
+// maxAttempts times, 1 disables retries
+func newRetryer(maxAttempts int) aws.Retryer {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if maxAttempts == 1 {
+		return aws.NopRetryer{}
+	}
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = maxAttempts
+		o.MaxBackoff = defaultMaxBackoff
+		o.Backoff = &retryAfterBackoff{
+			max:    defaultMaxBackoff,
+			jitter: retry.NewExponentialJitterBackoff(defaultMaxBackoff),
+		}
+		// an interactive client should not give up because of a quota
+		o.RateLimiter = ratelimit.None
+	})
+}
+
+// deadlineConn fails a read that stalls longer than timeout
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// tunedTransport returns base adjusted for the timeouts and pool limits of
+// opt, or base itself if opt keeps the defaults
+func tunedTransport(base http.RoundTripper, opt S3Options) http.RoundTripper {
+	t, ok := base.(*http.Transport)
+	if !ok || (opt.ConnectTimeout == 0 && opt.ReadTimeout == 0 && opt.MaxConns == 0 && opt.TLS == nil && opt.Proxy == nil && !opt.NoProxy) {
+		return base
+	}
+	t = t.Clone()
+	if opt.TLS != nil {
+		t.TLSClientConfig = opt.TLS
+	}
+	if opt.Proxy != nil {
+		t.Proxy = http.ProxyURL(opt.Proxy)
+	} else if opt.NoProxy {
+		t.Proxy = nil
+	}
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if opt.ConnectTimeout > 0 {
+		dialer.Timeout = opt.ConnectTimeout
+		t.TLSHandshakeTimeout = opt.ConnectTimeout
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil || opt.ReadTimeout <= 0 {
+			return conn, err
+		}
+		return &deadlineConn{Conn: conn, timeout: opt.ReadTimeout}, nil
+	}
+	if opt.ReadTimeout > 0 {
+		t.ResponseHeaderTimeout = opt.ReadTimeout
+	}
+	if opt.MaxConns > 0 {
+		t.MaxConnsPerHost = opt.MaxConns
+		t.MaxIdleConnsPerHost = opt.MaxConns
+	}
+	return t
+}
+
+// tlsVersions maps the names offered for the minimum TLS version
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// loadCertPool returns the system roots plus the certificates of a PEM
+// file, or of every .pem, .crt and .cer file in a directory
+func loadCertPool(caPath string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	files := []string{caPath}
+	if fi, err := os.Stat(caPath); err != nil {
+		return nil, fmt.Errorf("read ca bundle error %w", err)
+	} else if fi.IsDir() {
+		entries, err := os.ReadDir(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("read ca bundle error %w", err)
+		}
+		files = files[:0]
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".pem", ".crt", ".cer":
+				files = append(files, filepath.Join(caPath, e.Name()))
+			}
+		}
+	}
+	added := 0
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("read ca bundle error %w", err)
+		}
+		if pool.AppendCertsFromPEM(data) {
+			added++
+		}
+	}
+	if added == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", caPath)
+	}
+	return pool, nil
+}
+
+// spkiPin returns the base64 SHA-256 hash of the public key of cert, the
+// format used by HPKP and curl --pinnedpubkey
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyPins accepts a connection if the leaf certificate, or any
+// certificate of a verified chain, matches one of pins. Without
+// verification the other certificates sent are unchecked extras anyone
+// can add
+func verifyPins(pins []string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				if slices.Contains(pins, spkiPin(cert)) {
+					return nil
+				}
+			}
+		}
+		if len(cs.PeerCertificates) > 0 && slices.Contains(pins, spkiPin(cs.PeerCertificates[0])) {
+			return nil
+		}
+		return errors.New("tls: no certificate matches the pinned public keys")
+	}
+}
+
+// tlsConfig builds the TLS settings of cfg, nil keeps the defaults
+func (cfg *connConfig) tlsConfig() (*tls.Config, error) {
+	if cfg.CAFile == "" && cfg.ClientCert == "" && cfg.MinTLS == "" && len(cfg.Pins) == 0 && !cfg.SkipVerify {
+		return nil, nil
+	}
+	tc := &tls.Config{
+		InsecureSkipVerify: cfg.SkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.RootCAs = pool
+	}
+	if cfg.ClientCert != "" {
+		keyFile := cfg.ClientKey
+		if keyFile == "" {
+			keyFile = cfg.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate error %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.MinTLS != "" {
+		v, ok := tlsVersions[cfg.MinTLS]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls version %q", cfg.MinTLS)
+		}
+		tc.MinVersion = v
+	}
+	if len(cfg.Pins) > 0 {
+		tc.VerifyConnection = verifyPins(cfg.Pins)
+	}
+	return tc, nil
+}
+
+// s3Tuning edits the retry, timeout, pool and TLS settings of an S3
+// connection
+type s3Tuning struct {
+	maxAttempts    *widget.Entry
+	connectTimeout *widget.Entry
+	readTimeout    *widget.Entry
+	maxConns       *widget.Entry
+	checksum       *widget.Select
+	caFile         *widget.Entry
+	clientCert     *widget.Entry
+	clientKey      *widget.Entry
+	minTLS         *widget.Select
+	pins           *widget.Entry
+	skipVerify     *widget.Check
+	skipWarning    *widget.Label
+}
+
+// newS3Tuning creates the entries, bound to the cred.s3_* preferences if
+// prefs is set
+func newS3Tuning(prefs fyne.Preferences) *s3Tuning {
+	entry := func(key, placeHolder string) *widget.Entry {
+		e := widget.NewEntry()
+		if prefs != nil {
+			e.Bind(binding.BindPreferenceString(key, prefs))
+		}
+		e.SetPlaceHolder(placeHolder)
+		e.Validator = validation.NewRegexp(`^\d*$`, "not a number")
+		return e
+	}
+	t := &s3Tuning{
+		maxAttempts:    entry("cred.s3_max_attempts", strconv.Itoa(defaultMaxAttempts)),
+		connectTimeout: entry("cred.s3_connect_timeout", "10"),
+		readTimeout:    entry("cred.s3_read_timeout", "no limit"),
+		maxConns:       entry("cred.s3_max_conns", "no limit"),
+		caFile:         entry("cred.s3_ca_file", "/etc/ssl/private-ca.pem"),
+		clientCert:     entry("cred.s3_client_cert", "client.pem"),
+		clientKey:      entry("cred.s3_client_key", "client-key.pem"),
+		pins:           entry("cred.s3_pins", "base64 SHA-256 of the public key, comma separated"),
+		minTLS:         widget.NewSelect([]string{"", "1.2", "1.3"}, nil),
+		checksum:       widget.NewSelect(checksumAlgorithms, nil),
+		skipVerify:     widget.NewCheck("Skip certificate verification", nil),
+		skipWarning:    widget.NewLabel("Anyone on the network can intercept this connection"),
+	}
+	for _, e := range []*widget.Entry{t.caFile, t.clientCert, t.clientKey, t.pins} {
+		e.Validator = nil
+	}
+	t.checksum.PlaceHolder = checksumMD5
+	if prefs != nil {
+		t.minTLS.Bind(binding.BindPreferenceString("cred.s3_min_tls", prefs))
+		t.checksum.Bind(binding.BindPreferenceString("cred.s3_checksum", prefs))
+		t.skipVerify.Bind(binding.BindPreferenceBool("cred.s3_skip_verify", prefs))
+	}
+	t.skipWarning.Importance = widget.DangerImportance
+	t.skipVerify.OnChanged = func(b bool) {
+		t.skipWarning.Hidden = !b
+		t.skipWarning.Refresh()
+	}
+	t.skipVerify.OnChanged(t.skipVerify.Checked)
+	return t
+}
+
+func (t *s3Tuning) items() []*widget.FormItem {
+	return []*widget.FormItem{
+		widget.NewFormItem("Max attempts", t.maxAttempts),
+		widget.NewFormItem("Connect timeout (s)", t.connectTimeout),
+		widget.NewFormItem("Read timeout (s)", t.readTimeout),
+		widget.NewFormItem("Max connections", t.maxConns),
+		widget.NewFormItem("Checksum", t.checksum),
+	}
+}
+
+func (t *s3Tuning) tlsItems() []*widget.FormItem {
+	return []*widget.FormItem{
+		widget.NewFormItem("CA bundle", t.caFile),
+		widget.NewFormItem("Client cert", t.clientCert),
+		widget.NewFormItem("Client key", t.clientKey),
+		widget.NewFormItem("Min TLS", t.minTLS),
+		widget.NewFormItem("Pinned keys", t.pins),
+		widget.NewFormItem("", t.skipVerify),
+		widget.NewFormItem("", t.skipWarning),
+	}
+}
+
+// accordion groups the tuning and TLS items, extra items go first
+func (t *s3Tuning) accordion(extra ...*widget.FormItem) *widget.Accordion {
+	return widget.NewAccordion(
+		widget.NewAccordionItem("Advanced", widget.NewForm(append(extra, t.items()...)...)),
+		widget.NewAccordionItem("TLS", widget.NewForm(t.tlsItems()...)),
+	)
+}
+
+// set shows the settings of cfg
+func (t *s3Tuning) set(cfg connConfig) {
+	itoa := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	t.maxAttempts.SetText(itoa(cfg.MaxAttempts))
+	t.connectTimeout.SetText(itoa(cfg.ConnectTimeout))
+	t.readTimeout.SetText(itoa(cfg.ReadTimeout))
+	t.maxConns.SetText(itoa(cfg.MaxConns))
+	if cfg.Checksum == "" {
+		t.checksum.ClearSelected()
+	} else {
+		t.checksum.SetSelected(cfg.Checksum)
+	}
+	t.caFile.SetText(cfg.CAFile)
+	t.clientCert.SetText(cfg.ClientCert)
+	t.clientKey.SetText(cfg.ClientKey)
+	t.minTLS.SetSelected(cfg.MinTLS)
+	t.pins.SetText(strings.Join(cfg.Pins, ", "))
+	t.skipVerify.SetChecked(cfg.SkipVerify)
+}
+
+// apply copies the entered settings into cfg, empty or invalid entries
+// keep the defaults
+func (t *s3Tuning) apply(cfg *connConfig) {
+	cfg.MaxAttempts, _ = strconv.Atoi(t.maxAttempts.Text)
+	cfg.ConnectTimeout, _ = strconv.Atoi(t.connectTimeout.Text)
+	cfg.ReadTimeout, _ = strconv.Atoi(t.readTimeout.Text)
+	cfg.MaxConns, _ = strconv.Atoi(t.maxConns.Text)
+	cfg.Checksum = t.checksum.Selected
+	cfg.CAFile = strings.TrimSpace(t.caFile.Text)
+	cfg.ClientCert = strings.TrimSpace(t.clientCert.Text)
+	cfg.ClientKey = strings.TrimSpace(t.clientKey.Text)
+	cfg.MinTLS = t.minTLS.Selected
+	cfg.Pins = parseTags(t.pins.Text)
+	cfg.SkipVerify = t.skipVerify.Checked
+}
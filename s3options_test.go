@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	respErr := func(v string) error {
+		h := http.Header{}
+		if v != "" {
+			h.Set("Retry-After", v)
+		}
+		return &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 503, Header: h}},
+			},
+		}
+	}
+	tests := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "seconds", err: respErr("5"), want: 5 * time.Second, wantOK: true},
+		{name: "date", err: respErr("Mon, 15 Jan 2024 10:30:07 GMT"), want: 7 * time.Second, wantOK: true},
+		{name: "past date", err: respErr("Mon, 15 Jan 2024 10:00:00 GMT"), want: 0, wantOK: true},
+		{name: "missing", err: respErr(""), wantOK: false},
+		{name: "invalid", err: respErr("soon"), wantOK: false},
+		{name: "not a response", err: context.Canceled, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.err, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestS3Client_Retry(t *testing.T) {
+	slowDown := func() *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Retry-After": {"0"}},
+		}
+	}
+	ok := &http.Response{StatusCode: http.StatusNoContent, Header: http.Header{}}
+
+	f := withFakeS3(t, slowDown(), slowDown(), ok)
+	c := NewClientWithOptions("ak", "sk", "", "http://minio.local:9000", S3Options{})
+	c.Bucket = "bucket"
+	if err := c.Delete(context.Background(), "key"); err != nil {
+		t.Errorf("Delete() error = %v, want nil after retries", err)
+	}
+	if len(f.urls) != 3 {
+		t.Errorf("Delete() requests = %v, want 3", len(f.urls))
+	}
+
+	f = withFakeS3(t, slowDown(), ok)
+	c = NewClientWithOptions("ak", "sk", "", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	if err := c.Delete(context.Background(), "key"); err == nil {
+		t.Errorf("Delete() error = nil, want error without retries")
+	}
+	if len(f.urls) != 1 {
+		t.Errorf("Delete() requests = %v, want 1", len(f.urls))
+	}
+}
+
+func TestTunedTransport(t *testing.T) {
+	if got := tunedTransport(transport, S3Options{}); got != transport {
+		t.Errorf("tunedTransport(defaults) should return the shared transport")
+	}
+	got, ok := tunedTransport(transport, S3Options{ConnectTimeout: 3 * time.Second, MaxConns: 4}).(*http.Transport)
+	if !ok {
+		t.Fatalf("tunedTransport() is not an *http.Transport")
+	}
+	if got == transport || got.MaxConnsPerHost != 4 || got.TLSHandshakeTimeout != 3*time.Second {
+		t.Errorf("tunedTransport() = %+v", got)
+	}
+
+	u, _ := parseProxy("socks5://127.0.0.1:1080")
+	got = tunedTransport(transport, S3Options{Proxy: u}).(*http.Transport)
+	req, _ := http.NewRequest(http.MethodGet, "https://s3.amazonaws.com/", nil)
+	if p, _ := got.Proxy(req); p == nil || p.String() != u.String() {
+		t.Errorf("tunedTransport(proxy) proxy = %v", p)
+	}
+	got = tunedTransport(transport, S3Options{NoProxy: true}).(*http.Transport)
+	if got.Proxy != nil {
+		t.Errorf("tunedTransport(direct) should not use a proxy")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	cert := srv.Certificate()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     connConfig
+		wantErr bool
+	}{
+		{name: "system roots", cfg: connConfig{MinTLS: "1.2"}, wantErr: true},
+		{name: "ca file", cfg: connConfig{CAFile: caFile}},
+		{name: "ca dir", cfg: connConfig{CAFile: filepath.Dir(caFile)}},
+		{name: "pin", cfg: connConfig{SkipVerify: true, Pins: []string{spkiPin(cert)}}},
+		{name: "wrong pin", cfg: connConfig{CAFile: caFile, Pins: []string{"AAAA"}}, wantErr: true},
+		{name: "skip verify", cfg: connConfig{SkipVerify: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := tt.cfg.tlsConfig()
+			if err != nil {
+				t.Fatalf("tlsConfig() error = %v", err)
+			}
+			client := &http.Client{Transport: tunedTransport(transport, S3Options{TLS: tc})}
+			resp, err := client.Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := (&connConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).tlsConfig(); err == nil {
+		t.Errorf("tlsConfig(missing ca) error = nil")
+	}
+	if _, err := (&connConfig{MinTLS: "2.0"}).tlsConfig(); err == nil {
+		t.Errorf("tlsConfig(min tls 2.0) error = nil")
+	}
+	if tc, _ := (&connConfig{}).tlsConfig(); tc != nil {
+		t.Errorf("tlsConfig(defaults) = %v, want nil", tc)
+	}
+}
+
+func TestVerifyPins_ExtraCert(t *testing.T) {
+	pinned := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer pinned.Close()
+	pin := spkiPin(pinned.Certificate())
+
+	// the attacker serves its own leaf with the pinned certificate as an
+	// unused extra in the chain
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "attacker"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	leaf, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{leaf, pinned.Certificate().Raw},
+		PrivateKey:  key,
+	}}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	tc, err := (&connConfig{SkipVerify: true, Pins: []string{pin}}).tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: tunedTransport(transport, S3Options{TLS: tc})}
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Error("Get() with the pinned certificate as an extra = nil error, want rejected")
+	}
+	resp, err = client.Get(pinned.URL)
+	if err != nil {
+		t.Errorf("Get() pinned leaf error = %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ObjectProps are the details of an object beyond its File entry, the
+// storage class, headers, Metadata and Tags can be changed with
+// SetProperties
+type ObjectProps struct {
+	ETag         string
+	StorageClass string
+	VersionID    string
+	SSE          string
+	SSEKeyID     string
+	// SSECustomer is the algorithm of an SSE-C object
+	SSECustomer string
+	// Restore is set for archived objects with a restore requested
+	Restore *RestoreStatus
+	// Retention is changed with SetRetention, not SetProperties
+	Retention Retention
+
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	// Metadata holds the x-amz-meta-* headers without the prefix
+	Metadata map[string]string
+	Tags     map[string]string
+	// TagsErr is set if the tags could not be read, they are then read-only
+	TagsErr error
+}
+
+// headersEqual reports whether p and o have the same replaceable headers
+// and metadata
+func (p *ObjectProps) headersEqual(o ObjectProps) bool {
+	return p.ContentType == o.ContentType &&
+		p.CacheControl == o.CacheControl &&
+		p.ContentDisposition == o.ContentDisposition &&
+		p.ContentEncoding == o.ContentEncoding &&
+		p.ContentLanguage == o.ContentLanguage &&
+		maps.Equal(p.Metadata, o.Metadata)
+}
+
+// copies reports whether applying p over o copies the object onto itself,
+// which makes a new version
+func (p *ObjectProps) copies(o ObjectProps) bool {
+	return !p.headersEqual(o) || p.StorageClass != o.StorageClass
+}
+
+// copySource escapes bucket/key for the x-amz-copy-source header
+func copySource(bucket, key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return bucket + "/" + strings.Join(parts, "/")
+}
+
+// Properties reads the headers and tags of key
+func (c *S3Client) Properties(ctx context.Context, key string) (p ObjectProps, err error) {
+	if c.Prefix != "" {
+		key = c.Prefix + key
+	}
+	var resp *s3.HeadObjectOutput
+	err = c.followRedirect(func() (err error) {
+		input := &s3.HeadObjectInput{
+			Bucket: aws.String(c.Bucket),
+			Key:    aws.String(key),
+		}
+		c.Encryption().applyHead(input)
+		resp, err = c.HeadObject(ctx, input, c.withRegion)
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("head %s error %w", key, err)
+		return
+	}
+	p = ObjectProps{
+		ETag:               aws.ToString(resp.ETag),
+		StorageClass:       string(resp.StorageClass),
+		VersionID:          aws.ToString(resp.VersionId),
+		SSE:                string(resp.ServerSideEncryption),
+		SSEKeyID:           aws.ToString(resp.SSEKMSKeyId),
+		SSECustomer:        aws.ToString(resp.SSECustomerAlgorithm),
+		ContentType:        aws.ToString(resp.ContentType),
+		CacheControl:       aws.ToString(resp.CacheControl),
+		ContentDisposition: aws.ToString(resp.ContentDisposition),
+		ContentEncoding:    aws.ToString(resp.ContentEncoding),
+		ContentLanguage:    aws.ToString(resp.ContentLanguage),
+		Metadata:           resp.Metadata,
+	}
+	if p.StorageClass == "" {
+		// HeadObject leaves out the default class
+		p.StorageClass = string(types.StorageClassStandard)
+	}
+	if rs, ok := parseRestore(aws.ToString(resp.Restore)); ok {
+		p.Restore = &rs
+	}
+	p.Retention = Retention{
+		Mode:      string(resp.ObjectLockMode),
+		Until:     aws.ToTime(resp.ObjectLockRetainUntilDate),
+		LegalHold: resp.ObjectLockLegalHoldStatus == types.ObjectLockLegalHoldStatusOn,
+	}
+
+	var tags *s3.GetObjectTaggingOutput
+	p.TagsErr = c.followRedirect(func() (err error) {
+		tags, err = c.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+			Bucket: aws.String(c.Bucket),
+			Key:    aws.String(key),
+		}, c.withRegion)
+		return
+	})
+	if p.TagsErr == nil {
+		p.Tags = map[string]string{}
+		for _, t := range tags.TagSet {
+			p.Tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+		}
+	}
+	return
+}
+
+// SetProperties applies the changes from old to p, the storage class,
+// headers and metadata are changed by copying the object onto itself, which
+// keeps its encryption and gets the retention and legal hold of p
+func (c *S3Client) SetProperties(ctx context.Context, key string, old, p ObjectProps) (err error) {
+	if c.Prefix != "" {
+		key = c.Prefix + key
+	}
+	if p.copies(old) {
+		input := &s3.CopyObjectInput{
+			Bucket:            aws.String(c.Bucket),
+			Key:               aws.String(key),
+			CopySource:        aws.String(copySource(c.Bucket, key)),
+			MetadataDirective: types.MetadataDirectiveCopy,
+		}
+		if !p.headersEqual(old) {
+			input.MetadataDirective = types.MetadataDirectiveReplace
+			input.Metadata = p.Metadata
+			for _, h := range []struct {
+				dst **string
+				v   string
+			}{
+				{&input.ContentType, p.ContentType},
+				{&input.CacheControl, p.CacheControl},
+				{&input.ContentDisposition, p.ContentDisposition},
+				{&input.ContentEncoding, p.ContentEncoding},
+				{&input.ContentLanguage, p.ContentLanguage},
+			} {
+				if h.v != "" {
+					*h.dst = aws.String(h.v)
+				}
+			}
+		}
+		// the copy would fall back to STANDARD without it
+		if p.StorageClass != "" {
+			input.StorageClass = types.StorageClass(p.StorageClass)
+		}
+		if old.SSE != "" {
+			input.ServerSideEncryption = types.ServerSideEncryption(old.SSE)
+		}
+		if old.SSEKeyID != "" {
+			input.SSEKMSKeyId = aws.String(old.SSEKeyID)
+		}
+		// the copy is a new version, it would only get the bucket default
+		// lock and leave the retention on the old one
+		if r := p.Retention; r.Mode != "" && r.Until.After(time.Now()) {
+			input.ObjectLockMode = types.ObjectLockMode(r.Mode)
+			input.ObjectLockRetainUntilDate = aws.Time(r.Until)
+		}
+		if p.Retention.LegalHold {
+			input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+		}
+		c.Encryption().applyCopy(input)
+		err = c.followRedirect(func() (err error) {
+			_, err = c.CopyObject(ctx, input, c.withRegion)
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("copy %s error %w", key, err)
+		}
+	}
+
+	if maps.Equal(p.Tags, old.Tags) {
+		return nil
+	}
+	if len(p.Tags) == 0 {
+		err = c.followRedirect(func() (err error) {
+			_, err = c.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{
+				Bucket: aws.String(c.Bucket),
+				Key:    aws.String(key),
+			}, c.withRegion)
+			return
+		})
+	} else {
+		tagging := &types.Tagging{}
+		for _, k := range slices.Sorted(maps.Keys(p.Tags)) {
+			tagging.TagSet = append(tagging.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(p.Tags[k])})
+		}
+		err = c.followRedirect(func() (err error) {
+			_, err = c.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+				Bucket:  aws.String(c.Bucket),
+				Key:     aws.String(key),
+				Tagging: tagging,
+			}, c.withRegion)
+			return
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("tag %s error %w", key, err)
+	}
+	return nil
+}
+
+// formatKeyValues writes m as sorted key=value lines
+func formatKeyValues(m map[string]string) string {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		b.WriteString(k + "=" + m[k] + "\n")
+	}
+	return b.String()
+}
+
+// parseKeyValues reads key=value lines, blank lines are skipped
+func parseKeyValues(s string) (m map[string]string, err error) {
+	m = map[string]string{}
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		k, v := splitKeyValue(line, "=")
+		k = strings.TrimSpace(k)
+		if k == "" || !strings.Contains(line, "=") {
+			return nil, fmt.Errorf("line %d: want key=value", i+1)
+		}
+		m[k] = strings.TrimSpace(v)
+	}
+	return
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	got, err := parseKeyValues("a=1\n\n b = two words \nurl=http://x/?q=1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got["a"] != "1" || got["b"] != "two words" || got["url"] != "http://x/?q=1" {
+		t.Errorf("parseKeyValues() = %v", got)
+	}
+	if formatKeyValues(got) != "a=1\nb=two words\nurl=http://x/?q=1\n" {
+		t.Errorf("formatKeyValues() = %q", formatKeyValues(got))
+	}
+	for _, bad := range []string{"novalue", "=1"} {
+		if _, err := parseKeyValues(bad); err == nil {
+			t.Errorf("parseKeyValues(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCopySource(t *testing.T) {
+	if got := copySource("bucket", "dir/a b+c.txt"); got != "bucket/dir/a%20b+c.txt" {
+		t.Errorf("copySource() = %v", got)
+	}
+}
+
+func TestS3Client_Properties(t *testing.T) {
+	head := &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Etag":                                {`"abc"`},
+			"Content-Type":                        {"text/plain"},
+			"Cache-Control":                       {"max-age=60"},
+			"X-Amz-Meta-Owner":                    {"ops"},
+			"X-Amz-Storage-Class":                 {"STANDARD_IA"},
+			"X-Amz-Server-Side-Encryption":        {"AES256"},
+			"X-Amz-Object-Lock-Mode":              {"GOVERNANCE"},
+			"X-Amz-Object-Lock-Retain-Until-Date": {"2030-01-02T03:04:05Z"},
+			"X-Amz-Object-Lock-Legal-Hold":        {"ON"},
+		},
+	}
+	tagging := &http.Response{
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(strings.NewReader(`<Tagging><TagSet>` +
+			`<Tag><Key>env</Key><Value>prod</Value></Tag></TagSet></Tagging>`)),
+	}
+	withFakeS3(t, head, tagging)
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{})
+	c.Bucket = "bucket"
+	p, err := c.Properties(context.Background(), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ETag != `"abc"` || p.StorageClass != "STANDARD_IA" || p.SSE != "AES256" || p.CacheControl != "max-age=60" {
+		t.Errorf("Properties() = %+v", p)
+	}
+	if p.Metadata["owner"] != "ops" || p.Tags["env"] != "prod" || p.TagsErr != nil {
+		t.Errorf("Properties() metadata = %v, tags = %v, %v", p.Metadata, p.Tags, p.TagsErr)
+	}
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if r := p.Retention; r.Mode != "GOVERNANCE" || !r.Until.Equal(until) || !r.LegalHold {
+		t.Errorf("Properties() retention = %+v", r)
+	}
+}
+
+func TestS3Client_SetProperties(t *testing.T) {
+	ok := func() *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`<CopyObjectResult><ETag>"x"</ETag></CopyObjectResult>`)),
+		}
+	}
+	old := ObjectProps{
+		ContentType:  "text/plain",
+		StorageClass: "STANDARD_IA",
+		Metadata:     map[string]string{"owner": "ops"},
+		Tags:         map[string]string{"env": "prod"},
+	}
+
+	t.Run("tags only", func(t *testing.T) {
+		f := withFakeS3(t, ok())
+		c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{})
+		c.Bucket = "bucket"
+		p := old
+		p.Tags = map[string]string{"env": "dev"}
+		if err := c.SetProperties(context.Background(), "a.txt", old, p); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.requests) != 1 || f.requests[0].Method != http.MethodPut || !f.requests[0].URL.Query().Has("tagging") {
+			t.Errorf("SetProperties() requests = %v", f.urls)
+		}
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		f := withFakeS3(t, ok())
+		c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{})
+		c.Bucket = "bucket"
+		p := old
+		p.CacheControl = "no-cache"
+		if err := c.SetProperties(context.Background(), "dir/a.txt", old, p); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.requests) != 1 {
+			t.Fatalf("SetProperties() requests = %v", f.urls)
+		}
+		h := f.requests[0].Header
+		if h.Get("X-Amz-Copy-Source") != "bucket/dir/a.txt" || h.Get("X-Amz-Metadata-Directive") != "REPLACE" ||
+			h.Get("Cache-Control") != "no-cache" || h.Get("X-Amz-Meta-Owner") != "ops" || h.Get("X-Amz-Storage-Class") != "STANDARD_IA" {
+			t.Errorf("SetProperties() copy headers = %v", h)
+		}
+	})
+
+	t.Run("locked copy", func(t *testing.T) {
+		f := withFakeS3(t, ok())
+		c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{})
+		c.Bucket = "bucket"
+		until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		p := old
+		p.StorageClass = "STANDARD"
+		p.Retention = Retention{Mode: "GOVERNANCE", Until: until, LegalHold: true}
+		if err := c.SetProperties(context.Background(), "a.txt", old, p); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.requests) != 1 {
+			t.Fatalf("SetProperties() requests = %v", f.urls)
+		}
+		h := f.requests[0].Header
+		if h.Get("X-Amz-Object-Lock-Mode") != "GOVERNANCE" || h.Get("X-Amz-Object-Lock-Legal-Hold") != "ON" ||
+			h.Get("X-Amz-Object-Lock-Retain-Until-Date") != until.Format(time.RFC3339) {
+			t.Errorf("SetProperties() copy lock headers = %v", h)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// server-side encryption modes, sseNone uses the bucket default
+const (
+	sseNone = ""
+	sseS3   = "AES256"
+	sseKMS  = "aws:kms"
+	sseC    = "SSE-C"
+)
+
+// sseNoneLabel shows sseNone in selects
+const sseNoneLabel = "None"
+
+var sseModes = []string{sseNoneLabel, sseS3, sseKMS, sseC}
+
+// Encryption is the server-side encryption of uploads, SSE-C also needs the
+// customer key on every read and copy
+type Encryption struct {
+	Mode      string
+	KMSKeyID  string
+	BucketKey bool
+	// CustomerKey is the 256 bit SSE-C key
+	CustomerKey []byte
+}
+
+// loadKeyFile reads a 256 bit key file, either the 32 raw bytes or their
+// base64 encoding
+func loadKeyFile(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read key %s error %w", file, err)
+	}
+	if len(data) == 32 {
+		return data, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("key %s is not 32 bytes, raw or base64", file)
+	}
+	return key, nil
+}
+
+// encryption returns the encryption settings of cfg, nil for none
+func (cfg *connConfig) encryption() (*Encryption, error) {
+	switch cfg.SSE {
+	case sseNone, sseNoneLabel:
+		return nil, nil
+	case sseS3:
+		return &Encryption{Mode: sseS3}, nil
+	case sseKMS:
+		return &Encryption{Mode: sseKMS, KMSKeyID: cfg.SSEKMSKeyID, BucketKey: cfg.SSEBucketKey}, nil
+	case sseC:
+		if cfg.SSECKeyFile == "" {
+			return nil, fmt.Errorf("sse-c needs a key file")
+		}
+		key, err := loadKeyFile(cfg.SSECKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &Encryption{Mode: sseC, CustomerKey: key}, nil
+	}
+	return nil, fmt.Errorf("unknown encryption %q, use %s, %s or %s", cfg.SSE, sseS3, sseKMS, sseC)
+}
+
+// customerKey returns the SSE-C headers, all nil unless the mode is SSE-C
+func (e *Encryption) customerKey() (alg, key, keyMD5 *string) {
+	if e == nil || e.Mode != sseC {
+		return
+	}
+	sum := md5.Sum(e.CustomerKey)
+	return aws.String("AES256"),
+		aws.String(base64.StdEncoding.EncodeToString(e.CustomerKey)),
+		aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+func (e *Encryption) applyPut(in *s3.PutObjectInput) {
+	if e == nil {
+		return
+	}
+	switch e.Mode {
+	case sseS3:
+		in.ServerSideEncryption = types.ServerSideEncryptionAes256
+	case sseKMS:
+		in.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		if e.KMSKeyID != "" {
+			in.SSEKMSKeyId = aws.String(e.KMSKeyID)
+		}
+		if e.BucketKey {
+			in.BucketKeyEnabled = aws.Bool(true)
+		}
+	case sseC:
+		in.SSECustomerAlgorithm, in.SSECustomerKey, in.SSECustomerKeyMD5 = e.customerKey()
+	}
+}
+
+func (e *Encryption) applyGet(in *s3.GetObjectInput) {
+	in.SSECustomerAlgorithm, in.SSECustomerKey, in.SSECustomerKeyMD5 = e.customerKey()
+}
+
+func (e *Encryption) applyHead(in *s3.HeadObjectInput) {
+	in.SSECustomerAlgorithm, in.SSECustomerKey, in.SSECustomerKeyMD5 = e.customerKey()
+}
+
+// applyCopy sets the key to read an SSE-C source and to write the copy
+// with it again
+func (e *Encryption) applyCopy(in *s3.CopyObjectInput) {
+	if e == nil || e.Mode != sseC {
+		return
+	}
+	in.ServerSideEncryption = ""
+	in.SSEKMSKeyId = nil
+	in.CopySourceSSECustomerAlgorithm, in.CopySourceSSECustomerKey, in.CopySourceSSECustomerKeyMD5 = e.customerKey()
+	in.SSECustomerAlgorithm, in.SSECustomerKey, in.SSECustomerKeyMD5 = e.customerKey()
+}
+
+// SetEncryption changes the encryption of the next uploads and the SSE-C
+// key of reads, nil for none
+func (c *S3Client) SetEncryption(e *Encryption) {
+	c.sse.Store(e)
+}
+
+// Encryption returns the current encryption, nil for none
+func (c *S3Client) Encryption() *Encryption {
+	return c.sse.Load()
+}
+
+// sseSettings edits the encryption of a connection
+type sseSettings struct {
+	mode      *widget.Select
+	kmsKeyID  *widget.Entry
+	bucketKey *widget.Check
+	keyFile   *widget.Entry
+}
+
+// newSSESettings creates the widgets, bound to the cred.s3_sse preferences
+// if prefs is set
+func newSSESettings(prefs fyne.Preferences) *sseSettings {
+	s := &sseSettings{
+		mode:      widget.NewSelect(sseModes, nil),
+		kmsKeyID:  widget.NewEntry(),
+		bucketKey: widget.NewCheck("S3 Bucket Key", nil),
+		keyFile:   widget.NewEntry(),
+	}
+	if prefs != nil {
+		s.mode.Bind(binding.BindPreferenceString("cred.s3_sse", prefs))
+		s.kmsKeyID.Bind(binding.BindPreferenceString("cred.s3_sse_kms_key_id", prefs))
+		s.bucketKey.Bind(binding.BindPreferenceBool("cred.s3_sse_bucket_key", prefs))
+		s.keyFile.Bind(binding.BindPreferenceString("cred.s3_sse_c_key_file", prefs))
+	}
+	if s.mode.Selected == "" {
+		s.mode.SetSelected(sseNoneLabel)
+	}
+	s.kmsKeyID.SetPlaceHolder("key ID or ARN, empty for the AWS managed key")
+	s.keyFile.SetPlaceHolder("file with a 32 byte key, raw or base64")
+	return s
+}
+
+func (s *sseSettings) items() []*widget.FormItem {
+	return []*widget.FormItem{
+		widget.NewFormItem("Encryption", s.mode),
+		widget.NewFormItem("KMS key", s.kmsKeyID),
+		widget.NewFormItem("", s.bucketKey),
+		widget.NewFormItem("SSE-C key file", s.keyFile),
+	}
+}
+
+func (s *sseSettings) set(cfg connConfig) {
+	mode := cfg.SSE
+	if mode == sseNone {
+		mode = sseNoneLabel
+	}
+	s.mode.SetSelected(mode)
+	s.kmsKeyID.SetText(cfg.SSEKMSKeyID)
+	s.bucketKey.SetChecked(cfg.SSEBucketKey)
+	s.keyFile.SetText(cfg.SSECKeyFile)
+}
+
+func (s *sseSettings) apply(cfg *connConfig) {
+	cfg.SSE = s.mode.Selected
+	if cfg.SSE == sseNoneLabel {
+		cfg.SSE = sseNone
+	}
+	cfg.SSEKMSKeyID = strings.TrimSpace(s.kmsKeyID.Text)
+	cfg.SSEBucketKey = s.bucketKey.Checked
+	cfg.SSECKeyFile = strings.TrimSpace(s.keyFile.Text)
+}
+
+// uploadEncryption changes the encryption of the next uploads in this
+// session
+func (sc *Fone) uploadEncryption() {
+	enc, ok := unwrap(sc.client).(encrypter)
+	if !ok {
+		return
+	}
+	cur := enc.Encryption()
+	s := newSSESettings(nil)
+	if cur != nil {
+		s.set(connConfig{SSE: cur.Mode, SSEKMSKeyID: cur.KMSKeyID, SSEBucketKey: cur.BucketKey})
+		if cur.Mode == sseC {
+			s.keyFile.SetPlaceHolder("empty keeps the current key")
+		}
+	}
+	d := dialog.NewForm("Upload encryption", "OK", "Cancel", s.items(), func(ok bool) {
+		if !ok {
+			return
+		}
+		var cfg connConfig
+		s.apply(&cfg)
+		if cfg.SSE == sseC && cfg.SSECKeyFile == "" && cur != nil && cur.Mode == sseC {
+			enc.SetEncryption(cur)
+			return
+		}
+		e, err := cfg.encryption()
+		if err != nil {
+			dialog.ShowError(err, sc.w)
+			return
+		}
+		enc.SetEncryption(e)
+		slog.Info("upload encryption changed",
+			slog.String("sse", cfg.SSE),
+		)
+	}, sc.w)
+	d.Resize(fyne.NewSize(440, 0))
+	d.Show()
+}
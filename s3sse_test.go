@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKeyFile(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"raw", key, false},
+		{"base64", []byte(base64.StdEncoding.EncodeToString(key) + "\n"), false},
+		{"short", key[:16], true},
+		{"short base64", []byte(base64.StdEncoding.EncodeToString(key[:16])), true},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(file, tt.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := loadKeyFile(file)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("loadKeyFile(%s) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !bytes.Equal(got, key) {
+			t.Errorf("loadKeyFile(%s) = %x, want %x", tt.name, got, key)
+		}
+	}
+	if _, err := loadKeyFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("loadKeyFile(missing) error = nil")
+	}
+}
+
+func TestS3Client_Encryption(t *testing.T) {
+	f := withFakeS3(t, &http.Response{StatusCode: http.StatusOK, Header: http.Header{}})
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{
+		Encryption: &Encryption{Mode: sseKMS, KMSKeyID: "alias/fone", BucketKey: true},
+	})
+	c.Bucket = "bucket"
+	if err := c.Upload(context.Background(), bytes.NewReader([]byte("x")), "a.txt", ""); err != nil {
+		t.Fatal(err)
+	}
+	h := f.requests[0].Header
+	if got := h.Get("X-Amz-Server-Side-Encryption"); got != sseKMS {
+		t.Errorf("Upload() sse = %q, want %s", got, sseKMS)
+	}
+	if got := h.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); got != "alias/fone" {
+		t.Errorf("Upload() kms key = %q, want alias/fone", got)
+	}
+	if got := h.Get("X-Amz-Server-Side-Encryption-Bucket-Key-Enabled"); got != "true" {
+		t.Errorf("Upload() bucket key = %q, want true", got)
+	}
+
+	key := bytes.Repeat([]byte{7}, 32)
+	c.SetEncryption(&Encryption{Mode: sseC, CustomerKey: key})
+	ctx := context.Background()
+	if err := c.Upload(ctx, bytes.NewReader([]byte("x")), "b.txt", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Download(ctx, io.Discard, "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Stat(ctx, "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	wantKey := base64.StdEncoding.EncodeToString(key)
+	for i, method := range []string{http.MethodPut, http.MethodGet, http.MethodHead} {
+		req := f.requests[i+1]
+		if req.Method != method {
+			t.Fatalf("request %d method = %s, want %s", i+1, req.Method, method)
+		}
+		if got := req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key"); got != wantKey {
+			t.Errorf("%s customer key = %q, want %q", method, got, wantKey)
+		}
+		if got := req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"); got != "AES256" {
+			t.Errorf("%s customer algorithm = %q, want AES256", method, got)
+		}
+		if req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") == "" {
+			t.Errorf("%s customer key md5 missing", method)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Website is the static website hosting of a bucket, hosting is off if
+// neither IndexDocument nor RedirectAllHost is set
+type Website struct {
+	IndexDocument string
+	ErrorDocument string
+	// RedirectAllHost sends every request to another host, the documents
+	// and routing rules are then unused
+	RedirectAllHost     string
+	RedirectAllProtocol string
+	RoutingRules        []RoutingRule
+}
+
+// RoutingRule redirects requests matching its conditions
+type RoutingRule struct {
+	KeyPrefixEquals             string
+	HTTPErrorCodeReturnedEquals string
+
+	HostName             string
+	Protocol             string
+	ReplaceKeyPrefixWith string
+	ReplaceKeyWith       string
+	HTTPRedirectCode     string
+}
+
+// Enabled reports whether w turns hosting on
+func (w *Website) Enabled() bool {
+	return w.IndexDocument != "" || w.RedirectAllHost != ""
+}
+
+// routingRuleKeys are the fields of a routing rule line, conditions first
+var routingRuleKeys = []string{"prefix", "error", "host", "protocol", "replace_prefix", "replace_key", "code"}
+
+func (r *RoutingRule) fields() []*string {
+	return []*string{
+		&r.KeyPrefixEquals, &r.HTTPErrorCodeReturnedEquals,
+		&r.HostName, &r.Protocol, &r.ReplaceKeyPrefixWith, &r.ReplaceKeyWith, &r.HTTPRedirectCode,
+	}
+}
+
+// formatRoutingRules writes a line of key=value fields per rule
+func formatRoutingRules(rules []RoutingRule) string {
+	var b strings.Builder
+	for _, r := range rules {
+		var fields []string
+		for i, v := range r.fields() {
+			if *v != "" {
+				fields = append(fields, routingRuleKeys[i]+"="+*v)
+			}
+		}
+		b.WriteString(strings.Join(fields, " ") + "\n")
+	}
+	return b.String()
+}
+
+// parseRoutingRules reads lines like
+// prefix=docs/ replace_prefix=documents/ code=301, blank lines are skipped
+func parseRoutingRules(s string) (rules []RoutingRule, err error) {
+	for i, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var r RoutingRule
+		fields := r.fields()
+		for _, f := range strings.Fields(line) {
+			k, v, found := strings.Cut(f, "=")
+			n := slices.Index(routingRuleKeys, k)
+			if !found || n < 0 {
+				return nil, fmt.Errorf("line %d: want key=value with a key of %s", i+1, strings.Join(routingRuleKeys, ", "))
+			}
+			*fields[n] = v
+		}
+		rules = append(rules, r)
+	}
+	return
+}
+
+// validateWebsite checks w the way S3 does before saving
+func validateWebsite(w *Website) error {
+	if w.RedirectAllHost != "" {
+		if w.RedirectAllProtocol != "" && w.RedirectAllProtocol != "http" && w.RedirectAllProtocol != "https" {
+			return fmt.Errorf("unknown protocol %s", w.RedirectAllProtocol)
+		}
+		return nil
+	}
+	if w.IndexDocument == "" {
+		if w.ErrorDocument != "" || len(w.RoutingRules) > 0 {
+			return errors.New("index document required")
+		}
+		return nil
+	}
+	if strings.Contains(w.IndexDocument, "/") {
+		return errors.New("index document is a suffix like index.html, without a /")
+	}
+	for i, r := range w.RoutingRules {
+		if r.HostName == "" && r.Protocol == "" && r.ReplaceKeyPrefixWith == "" && r.ReplaceKeyWith == "" && r.HTTPRedirectCode == "" {
+			return fmt.Errorf("routing rule %d: redirect required", i+1)
+		}
+		if r.ReplaceKeyPrefixWith != "" && r.ReplaceKeyWith != "" {
+			return fmt.Errorf("routing rule %d: replace_prefix and replace_key exclude each other", i+1)
+		}
+		if r.Protocol != "" && r.Protocol != "http" && r.Protocol != "https" {
+			return fmt.Errorf("routing rule %d: unknown protocol %s", i+1, r.Protocol)
+		}
+		if r.HTTPRedirectCode != "" && (len(r.HTTPRedirectCode) != 3 || r.HTTPRedirectCode[0] != '3') {
+			return fmt.Errorf("routing rule %d: redirect code %s is not 3xx", i+1, r.HTTPRedirectCode)
+		}
+	}
+	return nil
+}
+
+// optString returns nil for an empty s
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// Website reads the website hosting of the bucket, a zero Website if it is
+// off
+func (c *S3Client) Website(ctx context.Context) (w Website, err error) {
+	var out *s3.GetBucketWebsiteOutput
+	err = c.followRedirect(func() (err error) {
+		out, err = c.GetBucketWebsite(ctx, &s3.GetBucketWebsiteInput{Bucket: aws.String(c.Bucket)}, c.withRegion)
+		return
+	})
+	if apiErrorCode(err) == "NoSuchWebsiteConfiguration" {
+		return w, nil
+	}
+	if err != nil {
+		return w, fmt.Errorf("get website of %s error %w", c.Bucket, err)
+	}
+	if out.IndexDocument != nil {
+		w.IndexDocument = aws.ToString(out.IndexDocument.Suffix)
+	}
+	if out.ErrorDocument != nil {
+		w.ErrorDocument = aws.ToString(out.ErrorDocument.Key)
+	}
+	if out.RedirectAllRequestsTo != nil {
+		w.RedirectAllHost = aws.ToString(out.RedirectAllRequestsTo.HostName)
+		w.RedirectAllProtocol = string(out.RedirectAllRequestsTo.Protocol)
+	}
+	for _, r := range out.RoutingRules {
+		var rule RoutingRule
+		if cond := r.Condition; cond != nil {
+			rule.KeyPrefixEquals = aws.ToString(cond.KeyPrefixEquals)
+			rule.HTTPErrorCodeReturnedEquals = aws.ToString(cond.HttpErrorCodeReturnedEquals)
+		}
+		if rd := r.Redirect; rd != nil {
+			rule.HostName = aws.ToString(rd.HostName)
+			rule.Protocol = string(rd.Protocol)
+			rule.ReplaceKeyPrefixWith = aws.ToString(rd.ReplaceKeyPrefixWith)
+			rule.ReplaceKeyWith = aws.ToString(rd.ReplaceKeyWith)
+			rule.HTTPRedirectCode = aws.ToString(rd.HttpRedirectCode)
+		}
+		w.RoutingRules = append(w.RoutingRules, rule)
+	}
+	return
+}
+
+// SetWebsite validates and saves the website hosting of the bucket, a
+// Website that isn't Enabled turns hosting off
+func (c *S3Client) SetWebsite(ctx context.Context, w Website) (err error) {
+	if err = validateWebsite(&w); err != nil {
+		return
+	}
+	if !w.Enabled() {
+		err = c.followRedirect(func() (err error) {
+			_, err = c.DeleteBucketWebsite(ctx, &s3.DeleteBucketWebsiteInput{Bucket: aws.String(c.Bucket)}, c.withRegion)
+			return
+		})
+	} else {
+		cfg := &types.WebsiteConfiguration{}
+		if w.RedirectAllHost != "" {
+			cfg.RedirectAllRequestsTo = &types.RedirectAllRequestsTo{
+				HostName: aws.String(w.RedirectAllHost),
+				Protocol: types.Protocol(w.RedirectAllProtocol),
+			}
+		} else {
+			cfg.IndexDocument = &types.IndexDocument{Suffix: aws.String(w.IndexDocument)}
+			if w.ErrorDocument != "" {
+				cfg.ErrorDocument = &types.ErrorDocument{Key: aws.String(w.ErrorDocument)}
+			}
+			for _, r := range w.RoutingRules {
+				rule := types.RoutingRule{Redirect: &types.Redirect{
+					HostName:             optString(r.HostName),
+					Protocol:             types.Protocol(r.Protocol),
+					ReplaceKeyPrefixWith: optString(r.ReplaceKeyPrefixWith),
+					ReplaceKeyWith:       optString(r.ReplaceKeyWith),
+					HttpRedirectCode:     optString(r.HTTPRedirectCode),
+				}}
+				if r.KeyPrefixEquals != "" || r.HTTPErrorCodeReturnedEquals != "" {
+					rule.Condition = &types.Condition{
+						KeyPrefixEquals:             optString(r.KeyPrefixEquals),
+						HttpErrorCodeReturnedEquals: optString(r.HTTPErrorCodeReturnedEquals),
+					}
+				}
+				cfg.RoutingRules = append(cfg.RoutingRules, rule)
+			}
+		}
+		err = c.followRedirect(func() (err error) {
+			_, err = c.PutBucketWebsite(ctx, &s3.PutBucketWebsiteInput{
+				Bucket:               aws.String(c.Bucket),
+				WebsiteConfiguration: cfg,
+			}, c.withRegion)
+			return
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("set website of %s error %w", c.Bucket, err)
+	}
+	return
+}
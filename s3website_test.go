@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoutingRules_RoundTrip(t *testing.T) {
+	rules := []RoutingRule{
+		{KeyPrefixEquals: "docs/", ReplaceKeyPrefixWith: "documents/"},
+		{HTTPErrorCodeReturnedEquals: "404", HostName: "example.com", Protocol: "https", ReplaceKeyWith: "404.html", HTTPRedirectCode: "302"},
+	}
+	s := formatRoutingRules(rules)
+	got, err := parseRoutingRules(s + "\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rules) {
+		t.Errorf("parseRoutingRules(%q) = %+v, want %+v", s, got, rules)
+	}
+	for _, s := range []string{"prefix", "unknown=1", "prefix=a b"} {
+		if _, err := parseRoutingRules(s); err == nil {
+			t.Errorf("parseRoutingRules(%q) error = nil", s)
+		}
+	}
+}
+
+func TestValidateWebsite(t *testing.T) {
+	tests := []struct {
+		name string
+		w    Website
+		ok   bool
+	}{
+		{"off", Website{}, true},
+		{"index", Website{IndexDocument: "index.html", ErrorDocument: "error.html"}, true},
+		{"redirect all", Website{RedirectAllHost: "example.com", RedirectAllProtocol: "https"}, true},
+		{"bad protocol", Website{RedirectAllHost: "example.com", RedirectAllProtocol: "ftp"}, false},
+		{"error without index", Website{ErrorDocument: "error.html"}, false},
+		{"index with slash", Website{IndexDocument: "docs/index.html"}, false},
+		{"rule without redirect", Website{IndexDocument: "index.html", RoutingRules: []RoutingRule{{KeyPrefixEquals: "a/"}}}, false},
+		{"both replaces", Website{IndexDocument: "index.html", RoutingRules: []RoutingRule{{ReplaceKeyPrefixWith: "a/", ReplaceKeyWith: "b"}}}, false},
+		{"bad code", Website{IndexDocument: "index.html", RoutingRules: []RoutingRule{{HTTPRedirectCode: "200"}}}, false},
+	}
+	for _, tt := range tests {
+		if err := validateWebsite(&tt.w); (err == nil) != tt.ok {
+			t.Errorf("validateWebsite(%s) error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestS3Client_Website(t *testing.T) {
+	withFakeS3(t, xmlResponse(http.StatusNotFound, `<Error><Code>NoSuchWebsiteConfiguration</Code></Error>`))
+	c := NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	w, err := c.Website(context.Background())
+	if err != nil || w.Enabled() {
+		t.Errorf("Website() = %+v, %v, want off", w, err)
+	}
+
+	withFakeS3(t, xmlResponse(http.StatusOK, `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument>`+
+		`<RoutingRules><RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>`+
+		`<Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule></RoutingRules>`+
+		`</WebsiteConfiguration>`))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	w, err = c.Website(context.Background())
+	want := Website{IndexDocument: "index.html", RoutingRules: []RoutingRule{{KeyPrefixEquals: "docs/", ReplaceKeyPrefixWith: "documents/"}}}
+	if err != nil || !reflect.DeepEqual(w, want) {
+		t.Errorf("Website() = %+v, %v, want %+v", w, err, want)
+	}
+
+	f := withFakeS3(t, xmlResponse(http.StatusOK, ""))
+	c = NewClientWithOptions("ak", "sk", "us-east-1", "http://minio.local:9000", S3Options{MaxAttempts: 1})
+	c.Bucket = "bucket"
+	if err = c.SetWebsite(context.Background(), want); err != nil {
+		t.Fatal(err)
+	}
+	body := f.bodies[0]
+	if f.requests[0].Method != http.MethodPut || !strings.Contains(string(body), "<ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith>") {
+		t.Errorf("SetWebsite() = %s %s", f.requests[0].Method, body)
+	}
+	if err = c.SetWebsite(context.Background(), Website{}); err != nil {
+		t.Fatal(err)
+	}
+	if f.requests[1].Method != http.MethodDelete {
+		t.Errorf("SetWebsite(off) method = %s, want DELETE", f.requests[1].Method)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// makeSearch returns the header search box. It filters the loaded files as
+// you type, or searches the server on Enter when "Server" is checked.
+func (sc *Fone) makeSearch() fyne.CanvasObject {
+	sc.searchEntry = widget.NewEntry()
+	sc.searchEntry.SetPlaceHolder("Filter (substring or glob)")
+	serverCheck := widget.NewCheck("Server", func(b bool) {
+		if b {
+			sc.searchEntry.SetPlaceHolder("Search server (Enter)")
+			sc.body.SetFilter("")
+		} else {
+			sc.searchEntry.SetPlaceHolder("Filter (substring or glob)")
+			sc.body.SetFilter(sc.searchEntry.Text)
+		}
+	})
+	sc.searchEntry.OnChanged = func(s string) {
+		if !serverCheck.Checked {
+			sc.body.SetFilter(s)
+		}
+	}
+	sc.searchEntry.OnSubmitted = func(s string) {
+		if serverCheck.Checked {
+			sc.searchServer(s)
+		}
+	}
+
+	return container.NewBorder(nil, nil, nil, serverCheck, sc.searchEntry)
+}
+
+// searchServer replaces the list with the server-side matches of pattern
+// under the current path, streaming them in as they arrive
+func (sc *Fone) searchServer(pattern string) {
+	s, ok := sc.client.(searcher)
+	// the encryption wrapper always has Search, ask what it wraps
+	if _, inner := unwrap(sc.client).(searcher); !ok || !inner {
+		sc.infoLabel.SetText("Warn: server search not supported!")
+		return
+	}
+	if sc.refreshLock {
+		d := dialog.NewConfirm("Cancel", "Cancel current Refreshing?", func(b bool) {
+			if b && sc.refreshCancel != nil {
+				sc.refreshCancel()
+			}
+		}, sc.w)
+		d.Show()
+		return
+	}
+	if pattern == "" {
+		sc.btnRefresh.OnTapped()
+		return
+	}
+
+	sc.refreshCtx, sc.refreshCancel = context.WithCancel(context.Background())
+	sc.lockRefresh()
+	ctx := sc.refreshCtx
+	prefix := sc.pathLabel.Text
+	sc.body.Update(prefix, []File{})
+	showLabelMsg(sc.infoLabel, fmt.Sprintf("Searching %q ...", pattern))
+	go func() {
+		defer sc.unlockRefresh()
+		count := 0
+		err := s.Search(ctx, prefix, pattern, func(data []File) error {
+			count += len(data)
+			sc.body.Append(data)
+			showLabelMsg(sc.infoLabel, fmt.Sprintf("Searching %q: %d found", pattern, count))
+			return nil
+		})
+		if err != nil {
+			slog.Warn("search failed",
+				slog.String("prefix", prefix),
+				slog.String("pattern", pattern),
+				slog.String("error", err.Error()),
+			)
+			showLabelMsg(sc.infoLabel, err.Error())
+			return
+		}
+		showLabelMsg(sc.infoLabel, fmt.Sprintf("Search %q: %d found", pattern, count))
+	}()
+}
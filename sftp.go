@@ -2,10 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
+	"os"
+	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/sftp"
@@ -13,59 +20,342 @@ import (
 )
 
 func NewSftpClient(server, user, pass, dir string) (*SftpClient, string, error) {
+	return NewSftpClientWithAuth(server, user, dir, passwordAuth(pass)...)
+}
+
+func passwordAuth(pass string) []ssh.AuthMethod {
+	return []ssh.AuthMethod{
+		ssh.Password(pass),
+		ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			// Just send the password back for all questions
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = pass
+			}
+			return answers, nil
+		}),
+	}
+}
+
+// keyFileAuth loads a private key file, passphrase is only used if the key
+// is encrypted
+func keyFileAuth(keyFile, passphrase string) (ssh.AuthMethod, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read key %s error %w", keyFile, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse key %s error %w", keyFile, err)
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+const (
+	sftpDialTimeout      = 10 * time.Second
+	sftpKeepAlive        = 30 * time.Second
+	sftpKeepAliveTimeout = 15 * time.Second
+)
+
+func NewSftpClientWithAuth(server, user, dir string, auth ...ssh.AuthMethod) (*SftpClient, string, error) {
+	return NewSftpClientWithDialer(server, user, dir, nil, auth...)
+}
+
+// NewSftpClientWithDialer connects through dial, nil dials directly
+func NewSftpClientWithDialer(server, user, dir string, dial dialFunc, auth ...ssh.AuthMethod) (*SftpClient, string, error) {
 	if !strings.HasSuffix(server, ":22") && !strings.Contains(server, ":") {
 		server = server + ":22"
 	}
 
 	config := &ssh.ClientConfig{
-		Timeout: 10 * time.Second,
-		User:    user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(pass),
-			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
-				// Just send the password back for all questions
-				answers := make([]string, len(questions))
-				for i := range answers {
-					answers[i] = pass
-				}
-				return answers, nil
-			}),
-		},
+		Timeout:         sftpDialTimeout,
+		User:            user,
+		Auth:            auth,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 		//HostKeyCallback: ssh.FixedHostKey(hostKey),
 	}
 
-	sshClient, err := ssh.Dial("tcp", server, config)
-	if err != nil {
-		return nil, "", fmt.Errorf("dial %s error %w", server, err)
+	c := &SftpClient{
+		server: server,
+		dial: func() (*ssh.Client, error) {
+			if dial == nil {
+				return ssh.Dial("tcp", server, config)
+			}
+			return dialSSH(dial, server, config)
+		},
 	}
-
-	sftpClient, err := sftp.NewClient(sshClient)
-	if err != nil {
-		return nil, "", fmt.Errorf("sftp %s error %w", server, err)
+	if err := c.connect(); err != nil {
+		return nil, "", err
 	}
+
+	var err error
 	if dir == "" {
-		dir, err = sftpClient.Getwd()
+		dir, err = c.Client.Getwd()
 		if err != nil {
+			c.Close(context.Background())
 			return nil, "", fmt.Errorf("getwd error %w", err)
 		}
 	}
 
-	pwd, err := sftpClient.RealPath(dir)
+	pwd, err := c.Client.RealPath(dir)
 	if err != nil {
+		c.Close(context.Background())
 		return nil, "", fmt.Errorf("realpath %s error %w", dir, err)
 	}
+	c.Pwd = pwd
+
+	return c, pwd, nil
+
+}
+
+// dialSSH opens an SSH connection over a custom dialer
+func dialSSH(dial dialFunc, server string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+	conn, err := dial(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, server, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// connState is the health of a provider connection
+type connState int
 
-	return &SftpClient{
-		Pwd:    pwd,
-		Client: sftpClient,
-	}, pwd, nil
+const (
+	connConnected connState = iota
+	connReconnecting
+	connLost
+)
 
+func (s connState) String() string {
+	switch s {
+	case connConnected:
+		return "connected"
+	case connReconnecting:
+		return "reconnecting"
+	default:
+		return "disconnected"
+	}
 }
 
+// SftpClient redials with the original parameters when the SSH connection
+// breaks, the embedded Client is replaced on every redial
 type SftpClient struct {
 	Pwd string
 	*sftp.Client
+
+	server   string
+	dial     func() (*ssh.Client, error)
+	redialMu sync.Mutex
+	mu       sync.Mutex
+	conn     *ssh.Client
+	gen      int
+	state    connState
+	closed   bool
+	onState  func(connState)
+	// noVerify skips comparing transfers with a hash made by the server,
+	// noRemoteHash is set once the server turned out unable to hash
+	noVerify     atomic.Bool
+	noRemoteHash atomic.Bool
+}
+
+// OnStateChange sets fn to be called when the connection drops or comes back
+func (c *SftpClient) OnStateChange(fn func(connState)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onState = fn
+}
+
+func (c *SftpClient) setState(gen int, state connState) {
+	c.mu.Lock()
+	if c.closed || gen != c.gen || c.state == state {
+		c.mu.Unlock()
+		return
+	}
+	c.state = state
+	fn := c.onState
+	c.mu.Unlock()
+	slog.Info("sftp connection state",
+		slog.String("server", c.server),
+		slog.String("state", state.String()),
+	)
+	if fn != nil {
+		fn(state)
+	}
+}
+
+// connect dials a new session and starts watching it
+func (c *SftpClient) connect() error {
+	sshClient, err := c.dial()
+	if err != nil {
+		return fmt.Errorf("dial %s error %w", c.server, err)
+	}
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return fmt.Errorf("sftp %s error %w", c.server, err)
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		sftpClient.Close()
+		sshClient.Close()
+		return net.ErrClosed
+	}
+	c.Client = sftpClient
+	c.conn = sshClient
+	c.gen++
+	gen := c.gen
+	c.mu.Unlock()
+
+	c.setState(gen, connConnected)
+	go c.keepAlive(sshClient, gen)
+	go func() {
+		sshClient.Wait()
+		c.setState(gen, connLost)
+	}()
+	return nil
+}
+
+// keepAlive pings the server so NAT and firewalls keep the session open,
+// and closes the connection if the server stops answering
+func (c *SftpClient) keepAlive(conn *ssh.Client, gen int) {
+	t := time.NewTicker(sftpKeepAlive)
+	defer t.Stop()
+	for range t.C {
+		c.mu.Lock()
+		current := !c.closed && gen == c.gen
+		c.mu.Unlock()
+		if !current {
+			return
+		}
+
+		errc := make(chan error, 1)
+		go func() {
+			// servers reject the unknown request, any reply proves the link
+			_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+			errc <- err
+		}()
+		var err error
+		select {
+		case err = <-errc:
+		case <-time.After(sftpKeepAliveTimeout):
+			err = errors.New("timeout")
+		}
+		if err != nil {
+			slog.Warn("sftp keepalive failed",
+				slog.String("server", c.server),
+				slog.String("error", err.Error()),
+			)
+			conn.Close()
+			return
+		}
+	}
+}
+
+// session returns the current client, redialing first if the connection
+// is known to be lost
+func (c *SftpClient) session() (client *sftp.Client, gen int, err error) {
+	c.mu.Lock()
+	client, gen, state := c.Client, c.gen, c.state
+	c.mu.Unlock()
+	if state != connLost {
+		return
+	}
+	if err = c.reconnect(gen); err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Client, c.gen, nil
+}
+
+// reconnect replaces the session of gen, unless another caller already did
+func (c *SftpClient) reconnect(gen int) error {
+	c.redialMu.Lock()
+	defer c.redialMu.Unlock()
+	c.mu.Lock()
+	if gen != c.gen {
+		c.mu.Unlock()
+		return nil
+	}
+	old, oldConn := c.Client, c.conn
+	c.mu.Unlock()
+
+	c.setState(gen, connReconnecting)
+	err := c.connect()
+	// after connect the events of the old session no longer count
+	if old != nil {
+		old.Close()
+	}
+	if oldConn != nil {
+		oldConn.Close()
+	}
+	if err != nil {
+		c.setState(gen, connLost)
+	}
+	return err
+}
+
+// broken reports whether err came from losing the session of gen
+func (c *SftpClient) broken(gen int, err error) bool {
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return gen == c.gen && c.state == connLost
+}
+
+// retry runs an idempotent op, redialing and running it once more if the
+// connection broke underneath it
+func (c *SftpClient) retry(ctx context.Context, name string, op func(client *sftp.Client) error) error {
+	client, gen, err := c.session()
+	if err != nil {
+		return err
+	}
+	err = op(client)
+	if err == nil || ctx.Err() != nil || !c.broken(gen, err) {
+		return err
+	}
+	slog.Warn("sftp connection broken, retrying",
+		slog.String("server", c.server),
+		slog.String("op", name),
+		slog.String("error", err.Error()),
+	)
+	if rerr := c.reconnect(gen); rerr != nil {
+		return fmt.Errorf("%w, reconnect error %v", err, rerr)
+	}
+	client, _, err = c.session()
+	if err != nil {
+		return err
+	}
+	return op(client)
+}
+
+// countWriter counts the bytes written so a retried download resumes
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	return
 }
 
 func (c *SftpClient) List(ctx context.Context, prefix, marker string) (data []File, nextMarker string, err error) {
@@ -76,72 +366,273 @@ func (c *SftpClient) List(ctx context.Context, prefix, marker string) (data []Fi
 	if prefix == "" {
 		prefix = c.Pwd
 	}
-	fis, err := c.ReadDir(prefix)
+	err = c.retry(ctx, "list", func(client *sftp.Client) (err error) {
+		fis, err := client.ReadDir(prefix)
+		if err != nil {
+			return
+		}
+		data = make([]File, len(fis))
+		for i, v := range fis {
+			f := fileFromInfo(v)
+			if f.IsLink() {
+				resolveLink(client, path.Join(prefix, v.Name()), &f)
+			}
+			if f.IsDir() && !strings.HasSuffix(f.Name, "/") {
+				f.Name += "/"
+			}
+			data[i] = f
+		}
+		return
+	})
 	if err != nil {
 		err = fmt.Errorf("readDir %s error %w", prefix, err)
 		return
 	}
 
-	data = make([]File, len(fis))
-	for i, v := range fis {
-		f := File{
-			Name: v.Name(),
-			Type: FileRegular,
-			Size: v.Size(),
-			Time: v.ModTime(),
+	return
+}
+
+// fileFromInfo converts fi, keeping the unix mode and owner of sftp stats
+func fileFromInfo(fi os.FileInfo) File {
+	f := File{
+		Name: fi.Name(),
+		Type: FileRegular,
+		Size: fi.Size(),
+		Time: fi.ModTime(),
+		Mode: fi.Mode(),
+	}
+	if fi.IsDir() {
+		f.Type = FileDir
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		f.LinkMode = fi.Mode()
+	}
+	if st, ok := fi.Sys().(*sftp.FileStat); ok {
+		f.Uid = int(st.UID)
+		f.Gid = int(st.GID)
+	}
+	return f
+}
+
+// resolveLink sets the target of the symlink at p, and its type so links to
+// directories can be browsed. Links to files take the size, time and mode
+// of the target, dangling links stay regular files
+func resolveLink(client *sftp.Client, p string, f *File) {
+	target, err := client.ReadLink(p)
+	if err != nil {
+		slog.Debug("sftp readlink failed",
+			slog.String("path", p),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	f.Link = target
+	fi, err := client.Stat(p)
+	switch {
+	case err != nil:
+	case fi.IsDir():
+		f.Type = FileDir
+	default:
+		f.Size = fi.Size()
+		f.Time = fi.ModTime()
+		f.Mode = fi.Mode()
+	}
+}
+
+// Search walks prefix recursively, streaming files whose name matches
+// pattern to fn in batches
+func (c *SftpClient) Search(ctx context.Context, prefix, pattern string, fn func(data []File) error) (err error) {
+	slog.Debug("sftp search",
+		slog.String("prefix", prefix),
+		slog.String("pattern", pattern),
+	)
+	const batchSize = 100
+	if prefix == "" {
+		prefix = c.Pwd
+	}
+	root := strings.TrimSuffix(prefix, "/")
+	client, _, err := c.session()
+	if err != nil {
+		return
+	}
+	data := []File{}
+	walker := client.Walk(root)
+	for walker.Step() {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if walker.Err() != nil {
+			slog.Debug("sftp search skip",
+				slog.String("path", walker.Path()),
+				slog.String("error", walker.Err().Error()),
+			)
+			continue
 		}
-		if v.IsDir() {
+		if walker.Path() == root {
+			continue
+		}
+		fi := walker.Stat()
+		if !matchFilter(fi.Name(), pattern) {
+			continue
+		}
+		f := fileFromInfo(fi)
+		f.Name = strings.TrimPrefix(walker.Path(), root+"/")
+		if fi.IsDir() {
 			f.Type = FileDir
-			if !strings.HasSuffix(f.Name, "/") {
-				f.Name += "/"
+			f.Name += "/"
+		}
+		data = append(data, f)
+		if len(data) >= batchSize {
+			if err = fn(data); err != nil {
+				return
 			}
+			data = []File{}
 		}
-
-		data[i] = f
 	}
-
+	if len(data) > 0 {
+		err = fn(data)
+	}
 	return
 }
 
 func (c *SftpClient) Upload(ctx context.Context, rs io.ReadSeeker, key, contentType string) (err error) {
-	f, err := c.Create(key)
+	client, _, err := c.session()
+	if err != nil {
+		return
+	}
+	f, err := client.Create(key)
 	if err != nil {
 		err = fmt.Errorf("create %s error %w", key, err)
 		return
 	}
-	_, err = io.Copy(f, rs)
-	return
+	h := sha256.New()
+	_, err = io.Copy(f, io.TeeReader(rs, h))
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("close %s error %w", key, cerr)
+	}
+	if err != nil || c.noVerify.Load() {
+		return
+	}
+	return c.verifyRemote(key, h.Sum(nil), true)
 }
 
 func (c *SftpClient) Download(ctx context.Context, w io.Writer, key string) (err error) {
-	f, err := c.Open(key)
-	if err != nil {
-		err = fmt.Errorf("open %s error %w", key, err)
-		return err
+	h := sha256.New()
+	cw := &countWriter{w: io.MultiWriter(w, h)}
+	err = c.retry(ctx, "download", func(client *sftp.Client) (err error) {
+		f, err := client.Open(key)
+		if err != nil {
+			err = fmt.Errorf("open %s error %w", key, err)
+			return err
+		}
+		defer f.Close()
+		if cw.n > 0 {
+			if _, err = f.Seek(cw.n, io.SeekStart); err != nil {
+				err = fmt.Errorf("seek %s error %w", key, err)
+				return
+			}
+		}
+		_, err = io.Copy(cw, f)
+		return
+	})
+	if err != nil || c.noVerify.Load() {
+		return
 	}
-	_, err = io.Copy(w, f)
-	return
+	return c.verifyRemote(key, h.Sum(nil), false)
+}
+
+func (c *SftpClient) DownloadRange(ctx context.Context, w io.Writer, key string, offset, length int64) (err error) {
+	cw := &countWriter{w: w}
+	return c.retry(ctx, "download", func(client *sftp.Client) (err error) {
+		f, err := client.Open(key)
+		if err != nil {
+			err = fmt.Errorf("open %s error %w", key, err)
+			return
+		}
+		defer f.Close()
+		if _, err = f.Seek(offset+cw.n, io.SeekStart); err != nil {
+			err = fmt.Errorf("seek %s error %w", key, err)
+			return
+		}
+		_, err = io.CopyN(cw, f, length-cw.n)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		return
+	})
 }
 
 func (c *SftpClient) Delete(ctx context.Context, key string) (err error) {
-	return c.Remove(key)
+	client, _, err := c.session()
+	if err != nil {
+		return
+	}
+	return client.Remove(key)
 }
 
 func (c *SftpClient) Stat(ctx context.Context, key string) (f File, err error) {
-	fi, err := c.Client.Stat(key)
+	err = c.retry(ctx, "stat", func(client *sftp.Client) (err error) {
+		fi, err := client.Lstat(key)
+		if err != nil {
+			return
+		}
+		f = fileFromInfo(fi)
+		if f.IsLink() {
+			resolveLink(client, key, &f)
+		}
+		return
+	})
 	if err != nil {
 		err = fmt.Errorf("stat %s error %w", key, err)
+	}
+	return
+}
+
+// Chmod sets the permission bits of key, setuid, setgid and sticky included
+func (c *SftpClient) Chmod(ctx context.Context, key string, mode os.FileMode) (err error) {
+	client, _, err := c.session()
+	if err != nil {
 		return
 	}
-	f.Name = fi.Name()
-	if fi.IsDir() {
-		f.Type = FileDir
+	if err = client.Chmod(key, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		err = fmt.Errorf("chmod %s error %w", key, err)
+	}
+	return
+}
+
+// Chown sets the owner and group of key, use the current ids to change
+// only one of them
+func (c *SftpClient) Chown(ctx context.Context, key string, uid, gid int) (err error) {
+	client, _, err := c.session()
+	if err != nil {
+		return
+	}
+	if err = client.Chown(key, uid, gid); err != nil {
+		err = fmt.Errorf("chown %s error %w", key, err)
+	}
+	return
+}
+
+// Symlink creates key pointing to target
+func (c *SftpClient) Symlink(ctx context.Context, target, key string) (err error) {
+	client, _, err := c.session()
+	if err != nil {
+		return
+	}
+	if err = client.Symlink(target, key); err != nil {
+		err = fmt.Errorf("symlink %s error %w", key, err)
 	}
-	f.Size = fi.Size()
-	f.Time = fi.ModTime()
 	return
 }
 
 func (c *SftpClient) Close(ctx context.Context) error {
-	return c.Client.Close()
+	c.mu.Lock()
+	c.closed = true
+	client, conn := c.Client, c.conn
+	c.mu.Unlock()
+	err := client.Close()
+	if conn != nil {
+		conn.Close()
+	}
+	return err
 }
@@ -1,9 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
 )
 
 // mockFileInfo implements os.FileInfo for testing
@@ -94,3 +108,330 @@ func TestSftpClient_Close(t *testing.T) {
 		t.Errorf("SftpClient Pwd = %v, want /home/test", client.Pwd)
 	}
 }
+
+// sftpTestServer serves the local filesystem over sftp, drop closes all
+// open connections
+type sftpTestServer struct {
+	addr  string
+	mu    sync.Mutex
+	conns []net.Conn
+	// exec answers exec requests if set, they are refused otherwise
+	exec func(cmd string) (out string, status uint32)
+}
+
+func newSftpTestServer(t *testing.T) *sftpTestServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &sftpTestServer{addr: ln.Addr().String()}
+	t.Cleanup(func() {
+		ln.Close()
+		srv.drop()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.conns = append(srv.conns, conn)
+			srv.mu.Unlock()
+			go srv.serve(conn, config)
+		}
+	}()
+	return srv
+}
+
+func (srv *sftpTestServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				if req.Type == "exec" && srv.exec != nil {
+					req.Reply(true, nil)
+					cmd, _, _ := readSftpString(req.Payload)
+					out, status := srv.exec(cmd)
+					ch.Write([]byte(out))
+					ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+					ch.Close()
+					continue
+				}
+				req.Reply(req.Type == "subsystem", nil)
+				if req.Type == "subsystem" {
+					s, err := sftp.NewServer(ch)
+					if err != nil {
+						return
+					}
+					s.Serve()
+					ch.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (srv *sftpTestServer) drop() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, c := range srv.conns {
+		c.Close()
+	}
+	srv.conns = nil
+}
+
+func TestSftpClient_Reconnect(t *testing.T) {
+	srv := newSftpTestServer(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/a.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, pwd, err := NewSftpClient(srv.addr, "test", "pass", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(context.Background())
+
+	var mu sync.Mutex
+	var states []connState
+	c.OnStateChange(func(state connState) {
+		mu.Lock()
+		states = append(states, state)
+		mu.Unlock()
+	})
+
+	srv.drop()
+	data, _, err := c.List(context.Background(), pwd, "")
+	if err != nil {
+		t.Fatalf("List() after drop error = %v", err)
+	}
+	if len(data) != 1 || data[0].Name != "a.txt" {
+		t.Errorf("List() = %v", data)
+	}
+	f, err := c.Stat(context.Background(), pwd+"/a.txt")
+	if err != nil || f.Size != 5 {
+		t.Errorf("Stat() = %v, %v", f, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(states) == 0 || states[len(states)-1] != connConnected {
+		t.Errorf("states = %v, want ending with connected", states)
+	}
+}
+
+func TestSftpClient_Links(t *testing.T) {
+	srv := newSftpTestServer(t)
+	dir := t.TempDir()
+	if err := os.Mkdir(dir+"/releases", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/run.sh", []byte("#!/bin/sh"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, pwd, err := NewSftpClient(srv.addr, "test", "pass", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(context.Background())
+	ctx := context.Background()
+
+	if err = c.Symlink(ctx, "releases", pwd+"/current"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	if err = c.Chmod(ctx, pwd+"/run.sh", 0o755); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	shared := os.ModeDir | os.ModeSetgid | os.ModeSticky | 0o775
+	if err = c.Chmod(ctx, pwd+"/releases", shared); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+
+	data, _, err := c.List(ctx, pwd, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]File{}
+	for _, f := range data {
+		files[f.Name] = f
+	}
+	link, ok := files["current/"]
+	if !ok || !link.IsDir() || !link.IsLink() || link.Link != "releases" {
+		t.Errorf("List() link = %+v, want a browsable link to releases", link)
+	}
+	if got := files["run.sh"].Mode.Perm(); got != 0o755 {
+		t.Errorf("List() run.sh mode = %v, want 0755", got)
+	}
+	if got := files["releases/"].Mode; got != shared {
+		t.Errorf("List() releases mode = %v, want %v", got, shared)
+	}
+	if f, err := c.Stat(ctx, pwd+"/current"); err != nil || !f.IsLink() || !f.IsDir() {
+		t.Errorf("Stat() link = %+v, %v", f, err)
+	}
+	sub, _, err := c.List(ctx, pwd+"/current/", "")
+	if err != nil || len(sub) != 0 {
+		t.Errorf("List() through link = %v, %v", sub, err)
+	}
+}
+
+func TestParseSha256sum(t *testing.T) {
+	sum := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	tests := []struct {
+		out     string
+		wantErr bool
+	}{
+		{sum + " *a.txt\n", false},
+		{sum + "  a.txt\n", false},
+		{"\\" + sum + " *a\\nb.txt\n", false},
+		{"sha256sum: a.txt: No such file or directory\n", true},
+		{"", true},
+		{sum[:32] + " *a.txt\n", true},
+	}
+	for _, tt := range tests {
+		got, err := parseSha256sum([]byte(tt.out))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSha256sum(%q) error = %v, wantErr %v", tt.out, err, tt.wantErr)
+			continue
+		}
+		if err == nil && hex.EncodeToString(got) != sum {
+			t.Errorf("parseSha256sum(%q) = %x", tt.out, got)
+		}
+	}
+}
+
+func TestParseCheckFileReply(t *testing.T) {
+	sum := bytes.Repeat([]byte{1}, 32)
+	reply := binary.BigEndian.AppendUint32(nil, 1)
+	reply = appendSftpString(reply, "check-file")
+	reply = appendSftpString(reply, "sha256")
+	got, err := parseCheckFileReply(sshFxpExtendedReply, append(reply, sum...))
+	if err != nil || !bytes.Equal(got, sum) {
+		t.Errorf("parseCheckFileReply() = %x, %v", got, err)
+	}
+	if _, err = parseCheckFileReply(sshFxpExtendedReply, append(reply, sum[:20]...)); err == nil {
+		t.Error("parseCheckFileReply(short hash) error = nil")
+	}
+	status := binary.BigEndian.AppendUint32(nil, 1)
+	status = binary.BigEndian.AppendUint32(status, 8)
+	status = appendSftpString(status, "unsupported")
+	if _, err = parseCheckFileReply(sshFxpStatus, status); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("parseCheckFileReply(status) error = %v", err)
+	}
+}
+
+func TestSftpClient_Verify(t *testing.T) {
+	srv := newSftpTestServer(t)
+	dir := t.TempDir()
+	sum := sha256.Sum256([]byte("hello"))
+	forced := 0
+	tests := []struct {
+		name     string
+		exec     func(cmd string) (string, uint32)
+		mismatch bool
+	}{
+		{"no exec", nil, false},
+		{"no sha256sum", func(string) (string, uint32) { return "sh: sha256sum: not found\n", 127 }, false},
+		{"forced internal-sftp", func(string) (string, uint32) { forced++; return "", 0 }, false},
+		{"match", func(string) (string, uint32) { return hex.EncodeToString(sum[:]) + " *a.txt\n", 0 }, false},
+		{"mismatch", func(string) (string, uint32) { return strings.Repeat("0", 64) + " *a.txt\n", 0 }, true},
+	}
+	for _, tt := range tests {
+		srv.exec = tt.exec
+		c, pwd, err := NewSftpClient(srv.addr, "test", "pass", dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		key := pwd + "/a.txt"
+		err = c.Upload(ctx, strings.NewReader("hello"), key, "")
+		if errors.Is(err, errChecksumMismatch) != tt.mismatch || (err != nil && !tt.mismatch) {
+			t.Errorf("Upload(%s) error = %v, want mismatch %v", tt.name, err, tt.mismatch)
+		}
+		var buf bytes.Buffer
+		err = c.Download(ctx, &buf, key)
+		if errors.Is(err, errChecksumMismatch) != tt.mismatch || (err != nil && !tt.mismatch) {
+			t.Errorf("Download(%s) error = %v, want mismatch %v", tt.name, err, tt.mismatch)
+		}
+		if buf.String() != "hello" {
+			t.Errorf("Download(%s) = %q, want hello", tt.name, buf.String())
+		}
+		c.SetVerify(false)
+		if err = c.Download(ctx, &buf, key); err != nil {
+			t.Errorf("Download(%s) without verify error = %v", tt.name, err)
+		}
+		c.Close(ctx)
+	}
+	if forced != 1 {
+		t.Errorf("sha256sum ran %d times with empty output, want 1", forced)
+	}
+}
+
+func TestSftpClient_FileLink(t *testing.T) {
+	srv := newSftpTestServer(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/app.log", []byte("0123456789"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(dir+"/app.log", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("app.log", dir+"/latest.log"); err != nil {
+		t.Fatal(err)
+	}
+	c, pwd, err := NewSftpClient(srv.addr, "test", "pass", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(context.Background())
+	ctx := context.Background()
+
+	check := func(name string, f File) {
+		if !f.IsLink() || f.IsDir() || f.Link != "app.log" {
+			t.Errorf("%s link = %+v, want a link to app.log", name, f)
+		}
+		if f.Size != 10 || !f.Time.Equal(old) || f.Mode.Perm() != 0o600 {
+			t.Errorf("%s size, time, mode = %d, %v, %v, want those of app.log", name, f.Size, f.Time, f.Mode)
+		}
+		if f.LinkMode&os.ModeSymlink == 0 {
+			t.Errorf("%s link mode = %v, want a symlink", name, f.LinkMode)
+		}
+	}
+	data, _, err := c.List(ctx, pwd, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range data {
+		if f.Name == "latest.log" {
+			check("List()", f)
+		}
+	}
+	f, err := c.Stat(ctx, pwd+"/latest.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("Stat()", f)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sftp packets of the check-file extension, pkg/sftp sends no extended
+// requests of its own
+const (
+	sshFxpInit          = 1
+	sshFxpVersion       = 2
+	sshFxpStatus        = 101
+	sshFxpExtended      = 200
+	sshFxpExtendedReply = 201
+
+	maxSftpPacket = 256 << 10
+)
+
+// errNoRemoteHash means the server can hash neither with check-file nor
+// with sha256sum, the transfer is then only hashed locally
+var errNoRemoteHash = errors.New("server cannot hash files")
+
+func appendSftpString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func writeSftpPacket(w io.Writer, typ byte, payload []byte) error {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	b = append(b, typ)
+	_, err := w.Write(append(b, payload...))
+	return err
+}
+
+func readSftpPacket(r io.Reader) (typ byte, payload []byte, err error) {
+	var l [4]byte
+	if _, err = io.ReadFull(r, l[:]); err != nil {
+		return
+	}
+	n := binary.BigEndian.Uint32(l[:])
+	if n == 0 || n > maxSftpPacket {
+		return 0, nil, fmt.Errorf("sftp packet length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return
+	}
+	return b[0], b[1:], nil
+}
+
+// readSftpString splits a length prefixed string off b
+func readSftpString(b []byte) (s string, rest []byte, err error) {
+	if len(b) < 4 {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	return string(b[4 : 4+n]), b[4+n:], nil
+}
+
+// checkFileRequest asks for the sha256 of the whole file name
+func checkFileRequest(id uint32, name string) []byte {
+	b := binary.BigEndian.AppendUint32(nil, id)
+	b = appendSftpString(b, "check-file-name")
+	b = appendSftpString(b, name)
+	b = appendSftpString(b, checksumSHA256)
+	b = binary.BigEndian.AppendUint64(b, 0)    // start offset
+	b = binary.BigEndian.AppendUint64(b, 0)    // length, 0 to the end
+	return binary.BigEndian.AppendUint32(b, 0) // block size, 0 for one hash
+}
+
+// parseCheckFileReply returns the hash of a check-file reply
+func parseCheckFileReply(typ byte, b []byte) (sum []byte, err error) {
+	if len(b) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b = b[4:]
+	switch typ {
+	case sshFxpStatus:
+		if len(b) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		msg, _, _ := readSftpString(b[4:])
+		return nil, fmt.Errorf("check-file status %d %s", binary.BigEndian.Uint32(b), msg)
+	case sshFxpExtendedReply:
+	default:
+		return nil, fmt.Errorf("check-file reply type %d", typ)
+	}
+	if _, b, err = readSftpString(b); err != nil {
+		return
+	}
+	alg, b, err := readSftpString(b)
+	if err != nil {
+		return
+	}
+	if alg != checksumSHA256 || len(b) != 32 {
+		return nil, fmt.Errorf("check-file answered %s with %d bytes", alg, len(b))
+	}
+	return b, nil
+}
+
+// checkFile hashes name with the check-file extension on an sftp channel
+// of its own
+func checkFile(conn *ssh.Client, name string) (sum []byte, err error) {
+	s, err := conn.NewSession()
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	w, err := s.StdinPipe()
+	if err != nil {
+		return
+	}
+	r, err := s.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err = s.RequestSubsystem("sftp"); err != nil {
+		return
+	}
+	if err = writeSftpPacket(w, sshFxpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return
+	}
+	typ, _, err := readSftpPacket(r)
+	if err != nil {
+		return
+	}
+	if typ != sshFxpVersion {
+		return nil, fmt.Errorf("sftp init reply type %d", typ)
+	}
+	if err = writeSftpPacket(w, sshFxpExtended, checkFileRequest(1, name)); err != nil {
+		return
+	}
+	typ, b, err := readSftpPacket(r)
+	if err != nil {
+		return
+	}
+	return parseCheckFileReply(typ, b)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// parseSha256sum returns the hash of a sha256sum output line
+func parseSha256sum(out []byte) (sum []byte, err error) {
+	field, _, _ := bytes.Cut(bytes.TrimSpace(out), []byte(" "))
+	// names with a backslash or newline are escaped with a leading \
+	sum, err = hex.DecodeString(strings.TrimPrefix(string(field), `\`))
+	if err == nil && len(sum) != 32 {
+		err = fmt.Errorf("sha256sum printed %q", out)
+	}
+	return
+}
+
+// remoteSHA256 hashes key on the server, with check-file if it has the
+// extension and sha256sum otherwise
+func (c *SftpClient) remoteSHA256(key string) (sum []byte, err error) {
+	if c.noRemoteHash.Load() {
+		return nil, errNoRemoteHash
+	}
+	c.mu.Lock()
+	conn, client := c.conn, c.Client
+	c.mu.Unlock()
+	if _, ok := client.HasExtension("check-file"); ok {
+		if sum, err = checkFile(conn, key); err == nil {
+			return
+		}
+		slog.Debug("sftp check-file failed",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+	}
+	s, err := conn.NewSession()
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	var out bytes.Buffer
+	s.Stdout = &out
+	if err = s.Run("sha256sum -b -- " + shellQuote(key)); err != nil {
+		slog.Debug("sftp sha256sum failed",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+		var exit *ssh.ExitError
+		if !errors.As(err, &exit) || exit.ExitStatus() >= 126 {
+			// exec is refused or there is no sha256sum, don't ask again
+			c.noRemoteHash.Store(true)
+		}
+		// a shell outside the sftp chroot may not see the file either
+		return nil, errNoRemoteHash
+	}
+	if sum, err = parseSha256sum(out.Bytes()); err != nil {
+		slog.Debug("sftp sha256sum unusable",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+		// a ForceCommand like internal-sftp runs instead and prints nothing
+		c.noRemoteHash.Store(true)
+		return nil, errNoRemoteHash
+	}
+	return
+}
+
+// verifyRemote compares the local sum of key with the one computed on the
+// server after an upload or download, a server that cannot hash leaves the
+// transfer unverified
+func (c *SftpClient) verifyRemote(key string, local []byte, upload bool) error {
+	remote, err := c.remoteSHA256(key)
+	if errors.Is(err, errNoRemoteHash) {
+		slog.Debug("sftp transfer not verified",
+			slog.String("key", key),
+			slog.String("sha256", hex.EncodeToString(local)),
+		)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("verify %s error %w", key, err)
+	}
+	if bytes.Equal(remote, local) {
+		return nil
+	}
+	want, got := hex.EncodeToString(remote), hex.EncodeToString(local)
+	if upload {
+		want, got = got, want
+	}
+	return mismatchError(key, checksumSHA256, want, got)
+}
+
+// SetVerify turns comparing the sha256 of transfers with the server on or
+// off, it is on by default
+func (c *SftpClient) SetVerify(on bool) {
+	c.noVerify.Store(!on)
+}
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	folderNodePrefix  = "folder:"
+	profileNodePrefix = "profile:"
+)
+
+// profileItem is a tree leaf that connects on double tap
+type profileItem struct {
+	widget.Label
+	onTapped       func()
+	onDoubleTapped func()
+}
+
+func newProfileItem() *profileItem {
+	i := &profileItem{}
+	i.Truncation = fyne.TextTruncateEllipsis
+	i.ExtendBaseWidget(i)
+	return i
+}
+
+func (i *profileItem) Tapped(*fyne.PointEvent) {
+	if i.onTapped != nil {
+		i.onTapped()
+	}
+}
+
+func (i *profileItem) DoubleTapped(*fyne.PointEvent) {
+	if i.onDoubleTapped != nil {
+		i.onDoubleTapped()
+	}
+}
+
+type siteManager struct {
+	sc       *Fone
+	profiles []Profile
+	selected string
+	filter   string
+	tree     *widget.Tree
+}
+
+func (sm *siteManager) load() {
+	profiles, err := decodeProfiles([]byte(sm.sc.a.Preferences().String(profilesKey)), false)
+	if err != nil {
+		slog.Warn("load profiles failed",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	sortProfiles(profiles)
+	sm.profiles = profiles
+}
+
+// save stores the profiles without their secrets, plaintext secrets from
+// before the vault are kept until the vault is unlocked and migrates them
+func (sm *siteManager) save() {
+	var legacy []Profile
+	if sm.sc.vault.Locked() {
+		legacy, _ = decodeProfiles([]byte(sm.sc.a.Preferences().String(profilesKey)), false)
+	}
+	out := make([]Profile, len(sm.profiles))
+	for i := range sm.profiles {
+		out[i] = sm.profiles[i].withoutSecrets()
+		if old := findProfile(legacy, out[i].Name); old != nil {
+			out[i].SecretKey = old.SecretKey
+			out[i].Password = old.Password
+		}
+	}
+	var buf bytes.Buffer
+	if err := encodeProfiles(&buf, out, false); err != nil {
+		slog.Warn("save profiles failed",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	sm.sc.a.Preferences().SetString(profilesKey, buf.String())
+	sm.tree.Refresh()
+}
+
+// saveSecrets copies the secrets of changed profiles into the vault,
+// unlocking it first if any secret has to be kept
+func (sm *siteManager) saveSecrets(profiles ...Profile) {
+	keep := false
+	for _, p := range profiles {
+		for _, v := range p.secrets() {
+			if !p.Forget && *v != "" {
+				keep = true
+			}
+		}
+	}
+	if !keep && sm.sc.vault.Locked() {
+		return
+	}
+	sm.sc.unlockVault(func() {
+		for _, p := range profiles {
+			if p.Forget {
+				p = p.withoutSecrets()
+			}
+			for field, v := range p.secrets() {
+				if err := sm.sc.vault.Set(profileSecretKey(p.Name, field), *v); err != nil {
+					slog.Warn("save profile secret failed",
+						slog.String("name", p.Name),
+						slog.String("error", err.Error()),
+					)
+				}
+			}
+		}
+		sm.fillSecrets()
+	})
+}
+
+// removeSecrets drops the vault entries of a deleted or renamed profile
+func (sm *siteManager) removeSecrets(name string) {
+	if sm.sc.vault.Locked() {
+		return
+	}
+	for field := range (&connConfig{}).secrets() {
+		sm.sc.vault.Set(profileSecretKey(name, field), "")
+	}
+}
+
+// fillSecrets loads the profile secrets from the unlocked vault
+func (sm *siteManager) fillSecrets() {
+	for i := range sm.profiles {
+		p := &sm.profiles[i]
+		if p.Forget {
+			continue
+		}
+		for field, v := range p.secrets() {
+			*v = sm.sc.vault.Get(profileSecretKey(p.Name, field))
+		}
+	}
+}
+
+func (sm *siteManager) clearSecrets() {
+	for i := range sm.profiles {
+		sm.profiles[i] = sm.profiles[i].withoutSecrets()
+	}
+}
+
+func (sm *siteManager) visible(p *Profile) bool {
+	if sm.filter == "" {
+		return true
+	}
+	f := strings.ToLower(sm.filter)
+	if strings.Contains(strings.ToLower(p.Name), f) || strings.Contains(strings.ToLower(p.address()), f) {
+		return true
+	}
+	for _, t := range p.Tags {
+		if strings.Contains(strings.ToLower(t), f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sm *siteManager) childUIDs(uid widget.TreeNodeID) []widget.TreeNodeID {
+	var ids []widget.TreeNodeID
+	if uid == "" {
+		for _, folder := range profileFolders(sm.profiles) {
+			ids = append(ids, folderNodePrefix+folder)
+		}
+		return ids
+	}
+	folder := strings.TrimPrefix(uid, folderNodePrefix)
+	for i := range sm.profiles {
+		if sm.profiles[i].Folder == folder && sm.visible(&sm.profiles[i]) {
+			ids = append(ids, profileNodePrefix+sm.profiles[i].Name)
+		}
+	}
+	return ids
+}
+
+func (sm *siteManager) updateNode(uid widget.TreeNodeID, branch bool, o fyne.CanvasObject) {
+	item := o.(*profileItem)
+	if branch {
+		folder := strings.TrimPrefix(uid, folderNodePrefix)
+		if folder == "" {
+			folder = "Ungrouped"
+		}
+		item.SetText(folder)
+		item.TextStyle.Bold = true
+		item.onTapped = func() {
+			sm.tree.ToggleBranch(uid)
+		}
+		item.onDoubleTapped = nil
+		return
+	}
+
+	name := strings.TrimPrefix(uid, profileNodePrefix)
+	p := findProfile(sm.profiles, name)
+	if p == nil {
+		return
+	}
+	text := p.Name + "  (" + p.Type + " " + p.address() + ")"
+	if len(p.Tags) > 0 {
+		text += "  [" + strings.Join(p.Tags, ", ") + "]"
+	}
+	item.TextStyle.Bold = false
+	item.SetText(text)
+	item.onTapped = func() {
+		sm.tree.Select(uid)
+	}
+	item.onDoubleTapped = func() {
+		sm.tree.Select(uid)
+		sm.connect()
+	}
+}
+
+func (sm *siteManager) current() *Profile {
+	return findProfile(sm.profiles, sm.selected)
+}
+
+func (sm *siteManager) connect() {
+	p := sm.current()
+	if p == nil {
+		return
+	}
+	if p.Forget {
+		sm.askSecret(p)
+		return
+	}
+	if sm.sc.vault.Locked() && sm.sc.vault.Exists() {
+		sm.sc.unlockVault(sm.connect)
+		return
+	}
+	slog.Info("connect profile",
+		slog.String("name", p.Name),
+	)
+	sm.sc.connect(p.Name, p.connConfig)
+}
+
+// askSecret prompts for the secret of a profile that does not save it
+func (sm *siteManager) askSecret(p *Profile) {
+	cfg := p.connConfig
+	label := "Password"
+	if cfg.Type == "s3" {
+		label = "SecretKey"
+	} else if cfg.Auth == authKey {
+		label = "Passphrase"
+	}
+	secret := widget.NewPasswordEntry()
+	name := p.Name
+	dialog.ShowForm(name, "Connect", "Cancel", []*widget.FormItem{
+		widget.NewFormItem(label, secret),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		if cfg.Type == "s3" {
+			cfg.SecretKey = secret.Text
+		} else {
+			cfg.Password = secret.Text
+		}
+		slog.Info("connect profile",
+			slog.String("name", name),
+		)
+		sm.sc.connect(name, cfg)
+	}, sm.sc.w)
+}
+
+// edit shows the profile form, p is nil for a new profile
+func (sm *siteManager) edit(p *Profile) {
+	var cur Profile
+	if p != nil {
+		cur = *p
+	} else {
+		cur.Type = "s3"
+		cur.Auth = authPassword
+	}
+
+	name := widget.NewEntry()
+	name.SetText(cur.Name)
+	folder := widget.NewSelectEntry(profileFolders(sm.profiles))
+	folder.SetText(cur.Folder)
+	tags := widget.NewEntry()
+	tags.SetText(strings.Join(cur.Tags, ", "))
+	tags.SetPlaceHolder("prod, backup")
+	typ := widget.NewSelect([]string{"s3", "sftp"}, nil)
+	typ.SetSelected(cur.Type)
+	endpoint := widget.NewEntry()
+	endpoint.SetText(cur.Endpoint)
+	endpoint.SetPlaceHolder("http://192.168.0.8:9000")
+	region := widget.NewEntry()
+	region.SetText(cur.Region)
+	bucket := widget.NewEntry()
+	bucket.SetText(cur.Bucket)
+	bucket.SetPlaceHolder("bucket/prefix")
+	addressing := widget.NewSelect([]string{addressingAuto, addressingPath, addressingVirtual}, nil)
+	addressing.SetSelected(cur.Addressing)
+	if addressing.Selected == "" {
+		addressing.SetSelected(addressingAuto)
+	}
+	accessKey := widget.NewEntry()
+	accessKey.SetText(cur.AccessKey)
+	secretKey := widget.NewPasswordEntry()
+	secretKey.SetText(cur.SecretKey)
+	server := widget.NewEntry()
+	server.SetText(cur.Server)
+	server.SetPlaceHolder("192.168.0.8:22")
+	dir := widget.NewEntry()
+	dir.SetText(cur.Dir)
+	user := widget.NewEntry()
+	user.SetText(cur.User)
+	auth := widget.NewSelect([]string{authPassword, authKey}, nil)
+	auth.SetSelected(cur.Auth)
+	password := widget.NewPasswordEntry()
+	password.SetText(cur.Password)
+	keyFile := widget.NewEntry()
+	keyFile.SetText(cur.KeyFile)
+	keyFile.SetPlaceHolder("~/.ssh/id_ed25519")
+	forget := widget.NewCheck("Don't remember password", nil)
+	forget.SetChecked(cur.Forget)
+	noChecksum := widget.NewCheck("Skip checksum verification", nil)
+	noChecksum.SetChecked(cur.Type == "sftp" && cur.Checksum == checksumOff)
+
+	tuning := newS3Tuning(nil)
+	tuning.set(cur.connConfig)
+	ps := newProxySettings(nil, "")
+	ps.set(cur.connConfig)
+	sse := newSSESettings(nil)
+	sse.set(cur.connConfig)
+	cs := newCryptSettings(nil, "")
+	cs.set(cur.connConfig)
+	// only one of the item lists is in the form at a time
+	proxyItem := widget.NewFormItem("", widget.NewAccordion(widget.NewAccordionItem("Proxy", widget.NewForm(ps.items()...))))
+	cryptItem := widget.NewFormItem("", widget.NewAccordion(widget.NewAccordionItem("Client-side encryption", widget.NewForm(cs.items()...))))
+
+	s3Items := []*widget.FormItem{
+		widget.NewFormItem("Endpoint", endpoint),
+		widget.NewFormItem("Region", region),
+		widget.NewFormItem("Bucket", bucket),
+		widget.NewFormItem("Addressing", addressing),
+		widget.NewFormItem("AccessKey", accessKey),
+		widget.NewFormItem("SecretKey", secretKey),
+		widget.NewFormItem("", tuning.accordion()),
+		widget.NewFormItem("", widget.NewAccordion(widget.NewAccordionItem("Encryption", widget.NewForm(sse.items()...)))),
+		proxyItem,
+		cryptItem,
+	}
+	sftpItems := []*widget.FormItem{
+		widget.NewFormItem("Server", server),
+		widget.NewFormItem("Directory", dir),
+		widget.NewFormItem("User", user),
+		widget.NewFormItem("Auth", auth),
+		widget.NewFormItem("Password", password),
+		widget.NewFormItem("Key file", keyFile),
+		widget.NewFormItem("", noChecksum),
+		proxyItem,
+		cryptItem,
+	}
+	form := widget.NewForm(
+		widget.NewFormItem("Name", name),
+		widget.NewFormItem("Folder", folder),
+		widget.NewFormItem("Tags", tags),
+		widget.NewFormItem("Type", typ),
+		widget.NewFormItem("", forget),
+	)
+	typ.OnChanged = func(s string) {
+		form.Items = form.Items[:5]
+		if s == "sftp" {
+			form.Items = append(form.Items, sftpItems...)
+		} else {
+			form.Items = append(form.Items, s3Items...)
+		}
+		form.Refresh()
+	}
+	typ.OnChanged(typ.Selected)
+
+	d := dialog.NewCustomConfirm("Profile", "Save", "Cancel", form, func(ok bool) {
+		if !ok {
+			return
+		}
+		np := Profile{
+			Name:   strings.TrimSpace(name.Text),
+			Folder: strings.TrimSpace(folder.Text),
+			Tags:   parseTags(tags.Text),
+			Forget: forget.Checked,
+			connConfig: connConfig{
+				Type: typ.Selected,
+			},
+		}
+		ps.apply(&np.connConfig)
+		cs.apply(&np.connConfig)
+		if np.Type == "sftp" {
+			np.Server = server.Text
+			np.Dir = dir.Text
+			np.User = user.Text
+			np.Auth = auth.Selected
+			np.Password = password.Text
+			np.KeyFile = keyFile.Text
+			if noChecksum.Checked {
+				np.Checksum = checksumOff
+			}
+		} else {
+			np.Endpoint = endpoint.Text
+			np.Region = region.Text
+			np.Bucket = bucket.Text
+			np.Addressing = addressing.Selected
+			tuning.apply(&np.connConfig)
+			sse.apply(&np.connConfig)
+			np.AccessKey = accessKey.Text
+			np.SecretKey = secretKey.Text
+		}
+		if np.Name == "" {
+			dialog.ShowError(errors.New("profile name required"), sm.sc.w)
+			return
+		}
+		if other := findProfile(sm.profiles, np.Name); other != nil && (p == nil || p.Name != np.Name) {
+			dialog.ShowError(errors.New("profile "+np.Name+" already exists"), sm.sc.w)
+			return
+		}
+		if p != nil {
+			if p.Name != np.Name {
+				sm.removeSecrets(p.Name)
+			}
+			*p = np
+		} else {
+			sm.profiles = append(sm.profiles, np)
+		}
+		sortProfiles(sm.profiles)
+		sm.selected = np.Name
+		sm.save()
+		sm.saveSecrets(np)
+		sm.tree.OpenBranch(folderNodePrefix + np.Folder)
+		sm.tree.Select(profileNodePrefix + np.Name)
+	}, sm.sc.w)
+	d.Resize(fyne.NewSize(480, 0))
+	d.Show()
+}
+
+func (sm *siteManager) clone() {
+	p := sm.current()
+	if p == nil {
+		return
+	}
+	np := *p
+	np.Tags = append([]string(nil), p.Tags...)
+	np.Name = cloneName(sm.profiles, p.Name)
+	sm.profiles = append(sm.profiles, np)
+	sortProfiles(sm.profiles)
+	sm.save()
+	sm.saveSecrets(np)
+	sm.tree.Select(profileNodePrefix + np.Name)
+}
+
+func (sm *siteManager) remove() {
+	p := sm.current()
+	if p == nil {
+		return
+	}
+	name := p.Name
+	dialog.NewConfirm("Delete", "Delete profile "+name+"?", func(ok bool) {
+		if !ok {
+			return
+		}
+		for i := range sm.profiles {
+			if sm.profiles[i].Name == name {
+				sm.profiles = append(sm.profiles[:i], sm.profiles[i+1:]...)
+				break
+			}
+		}
+		sm.removeSecrets(name)
+		sm.selected = ""
+		sm.tree.UnselectAll()
+		sm.save()
+	}, sm.sc.w).Show()
+}
+
+// importFile reads a fone profile file, or the remotes of rclone, s3cmd or
+// the AWS CLI after showing a preview
+func (sm *siteManager) importFile() {
+	dialog.NewFileOpen(func(uc fyne.URIReadCloser, e error) {
+		if e != nil || uc == nil {
+			return
+		}
+		defer uc.Close()
+		data, err := io.ReadAll(uc)
+		if err != nil {
+			dialog.ShowError(err, sm.sc.w)
+			return
+		}
+		ext := strings.ToLower(uc.URI().Extension())
+		if ext != ".json" && ext != ".toml" {
+			sm.importConfig(uc.URI(), data)
+			return
+		}
+		in, err := decodeProfiles(data, ext == ".toml")
+		if err != nil {
+			dialog.ShowError(err, sm.sc.w)
+			return
+		}
+		sm.addProfiles(uc.URI(), in)
+	}, sm.sc.w).Show()
+}
+
+func (sm *siteManager) addProfiles(uri fyne.URI, in []Profile) {
+	sm.profiles = mergeProfiles(sm.profiles, in)
+	sm.save()
+	merged := make([]Profile, 0, len(in))
+	for _, p := range in {
+		merged = append(merged, *findProfile(sm.profiles, p.Name))
+	}
+	sm.saveSecrets(merged...)
+	for _, folder := range profileFolders(in) {
+		sm.tree.OpenBranch(folderNodePrefix + folder)
+	}
+	slog.Info("import profiles success",
+		slog.String("file", uri.String()),
+		slog.Int("count", len(in)),
+	)
+}
+
+// importConfig converts a foreign config file and asks before adding it
+func (sm *siteManager) importConfig(uri fyne.URI, data []byte) {
+	var r importResult
+	switch format := detectConfigFormat(uri.Name(), data); format {
+	case formatRclone:
+		r = importRclone(data)
+	case formatS3cmd:
+		r = importS3cmd(data)
+	case formatAWS:
+		// the keys usually live next to the config in the credentials file
+		config, credentials := data, []byte(nil)
+		sibling := "credentials"
+		if uri.Name() == "credentials" {
+			sibling = "config"
+		}
+		if parent, err := storage.Parent(uri); err == nil {
+			if other, err := storage.Child(parent, sibling); err == nil {
+				if rc, err := storage.Reader(other); err == nil {
+					credentials, _ = io.ReadAll(rc)
+					rc.Close()
+				}
+			}
+		}
+		if sibling == "config" {
+			config, credentials = credentials, data
+		}
+		r = importAWS(config, credentials)
+	default:
+		dialog.ShowError(errors.New("unknown config format, expected rclone, s3cmd or AWS CLI"), sm.sc.w)
+		return
+	}
+	if len(r.profiles) == 0 {
+		dialog.ShowError(errors.New("no s3 or sftp connections found\n"+strings.Join(r.notes, "\n")), sm.sc.w)
+		return
+	}
+
+	var lines []string
+	for _, p := range r.profiles {
+		line := p.Name + "  " + p.Type + "  " + p.address()
+		if findProfile(sm.profiles, p.Name) != nil {
+			line += "  (replaces existing)"
+		}
+		lines = append(lines, line)
+	}
+	content := container.NewVBox(widget.NewLabel(strings.Join(lines, "\n")))
+	if len(r.notes) > 0 {
+		notes := widget.NewLabel(strings.Join(r.notes, "\n"))
+		notes.Importance = widget.WarningImportance
+		content.Add(widget.NewSeparator())
+		content.Add(notes)
+	}
+	d := dialog.NewCustomConfirm("Import "+uri.Name(), "Import", "Cancel", container.NewVScroll(content), func(ok bool) {
+		if ok {
+			sm.addProfiles(uri, r.profiles)
+		}
+	}, sm.sc.w)
+	d.Resize(fyne.NewSize(560, 400))
+	d.Show()
+}
+
+// exportFile writes all profiles without their secrets
+func (sm *siteManager) exportFile() {
+	d := dialog.NewFileSave(func(uc fyne.URIWriteCloser, e error) {
+		if e != nil || uc == nil {
+			return
+		}
+		defer uc.Close()
+		out := make([]Profile, len(sm.profiles))
+		for i := range sm.profiles {
+			out[i] = sm.profiles[i].withoutSecrets()
+		}
+		if err := encodeProfiles(uc, out, strings.EqualFold(uc.URI().Extension(), ".toml")); err != nil {
+			dialog.ShowError(err, sm.sc.w)
+			return
+		}
+		slog.Info("export profiles success",
+			slog.String("file", uc.URI().String()),
+		)
+	}, sm.sc.w)
+	d.SetFileName("fone-profiles.json")
+	d.Show()
+}
+
+func (sc *Fone) createSiteManager() fyne.CanvasObject {
+	sm := &siteManager{sc: sc}
+	sc.sites = sm
+	sm.load()
+
+	sm.tree = widget.NewTree(
+		sm.childUIDs,
+		func(uid widget.TreeNodeID) bool {
+			return uid == "" || strings.HasPrefix(uid, folderNodePrefix)
+		},
+		func(branch bool) fyne.CanvasObject {
+			return newProfileItem()
+		},
+		sm.updateNode,
+	)
+	sm.tree.OnSelected = func(uid widget.TreeNodeID) {
+		if strings.HasPrefix(uid, profileNodePrefix) {
+			sm.selected = strings.TrimPrefix(uid, profileNodePrefix)
+		}
+	}
+	for _, folder := range profileFolders(sm.profiles) {
+		sm.tree.OpenBranch(folderNodePrefix + folder)
+	}
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Filter by name, address or tag")
+	search.OnChanged = func(s string) {
+		sm.filter = s
+		sm.tree.Refresh()
+	}
+
+	toolbar := widget.NewToolbar(
+		widget.NewToolbarAction(theme.ContentAddIcon(), func() {
+			sm.edit(nil)
+		}),
+		widget.NewToolbarAction(theme.DocumentCreateIcon(), func() {
+			if p := sm.current(); p != nil {
+				sm.edit(p)
+			}
+		}),
+		widget.NewToolbarAction(theme.ContentCopyIcon(), sm.clone),
+		widget.NewToolbarAction(theme.DeleteIcon(), sm.remove),
+		widget.NewToolbarSeparator(),
+		widget.NewToolbarAction(theme.FolderOpenIcon(), sm.importFile),
+		widget.NewToolbarAction(theme.DocumentSaveIcon(), sm.exportFile),
+		widget.NewToolbarSpacer(),
+		widget.NewToolbarAction(theme.VisibilityOffIcon(), func() {
+			sm.sc.vault.Lock()
+		}),
+		widget.NewToolbarAction(theme.LoginIcon(), sm.connect),
+	)
+
+	return container.NewBorder(container.NewBorder(nil, nil, nil, toolbar, search), nil, nil, nil, sm.tree)
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	vaultKey         = "vault"
+	vaultIdleKey     = "vault.idle_minutes"
+	vaultIdleDefault = 10
+	vaultAAD         = "fone-vault-v1"
+)
+
+var (
+	errVaultLocked   = errors.New("vault is locked")
+	errWrongPassword = errors.New("wrong master password")
+)
+
+// plaintextSecretKeys are the preferences that used to hold secrets
+var plaintextSecretKeys = []string{"cred.s3_pass", "cred.sftp_password"}
+
+// vaultFile is the persisted form of a Vault, secrets are sealed with
+// XChaCha20-Poly1305 under an Argon2id key derived from the master password
+type vaultFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Vault keeps secrets encrypted at rest and in memory only while unlocked
+type Vault struct {
+	mu      sync.Mutex
+	file    *vaultFile
+	key     []byte
+	secrets map[string]string
+	store   func(data string)
+	idle    time.Duration
+	timer   *time.Timer
+	onLock  func()
+}
+
+// newVault loads a vault from its stored form, store persists it after
+// every change and may be nil for a read-only vault
+func newVault(stored string, store func(data string)) (*Vault, error) {
+	v := &Vault{store: store}
+	if stored == "" {
+		return v, nil
+	}
+	v.file = &vaultFile{}
+	if err := json.Unmarshal([]byte(stored), v.file); err != nil {
+		return v, fmt.Errorf("parse vault error %w", err)
+	}
+	return v, v.file.check()
+}
+
+// check rejects a hand-edited or truncated vault before its parameters reach
+// Argon2id or the cipher, both panic or exhaust memory on bad values
+func (f *vaultFile) check() error {
+	switch {
+	case f.KDF != "argon2id":
+		return fmt.Errorf("unknown vault kdf %q", f.KDF)
+	case len(f.Nonce) != chacha20poly1305.NonceSizeX:
+		return fmt.Errorf("vault nonce is %d bytes, want %d", len(f.Nonce), chacha20poly1305.NonceSizeX)
+	case f.Time < 1 || f.Time > 64, f.Threads < 1, f.Memory < 8*uint32(f.Threads) || f.Memory > 4<<20:
+		return fmt.Errorf("vault kdf parameters out of range, time %d memory %d threads %d", f.Time, f.Memory, f.Threads)
+	}
+	return nil
+}
+
+func (v *Vault) Exists() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.file != nil
+}
+
+func (v *Vault) Locked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key == nil
+}
+
+func deriveVaultKey(password string, f *vaultFile) []byte {
+	return argon2.IDKey([]byte(password), f.Salt, f.Time, f.Memory, f.Threads, chacha20poly1305.KeySize)
+}
+
+// Create initializes an empty vault protected by password and unlocks it
+func (v *Vault) Create(password string) error {
+	if password == "" {
+		return errors.New("master password required")
+	}
+	f := &vaultFile{
+		Version: 1,
+		KDF:     "argon2id",
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+		Salt:    make([]byte, 16),
+	}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.file = f
+	v.key = deriveVaultKey(password, f)
+	v.secrets = map[string]string{}
+	v.resetTimer()
+	return v.persist()
+}
+
+// Unlock decrypts the vault with password
+func (v *Vault) Unlock(password string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.file == nil {
+		return errors.New("vault does not exist")
+	}
+	if err := v.file.check(); err != nil {
+		return err
+	}
+	key := deriveVaultKey(password, v.file)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return err
+	}
+	plain, err := aead.Open(nil, v.file.Nonce, v.file.Data, []byte(vaultAAD))
+	if err != nil {
+		return errWrongPassword
+	}
+	secrets := map[string]string{}
+	if err = json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("parse vault secrets error %w", err)
+	}
+	v.key = key
+	v.secrets = secrets
+	v.resetTimer()
+	return nil
+}
+
+// Lock forgets the key and the decrypted secrets
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	wasUnlocked := v.key != nil
+	v.lock()
+	onLock := v.onLock
+	v.mu.Unlock()
+	if wasUnlocked && onLock != nil {
+		onLock()
+	}
+}
+
+func (v *Vault) lock() {
+	clear(v.key)
+	v.key = nil
+	v.secrets = nil
+	if v.timer != nil {
+		v.timer.Stop()
+	}
+}
+
+// SetIdleTimeout locks the vault after d without any Get or Set, onLock is
+// called after an idle lock or an explicit Lock
+func (v *Vault) SetIdleTimeout(d time.Duration, onLock func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.idle = d
+	v.onLock = onLock
+	if v.key != nil {
+		v.resetTimer()
+	}
+}
+
+func (v *Vault) resetTimer() {
+	if v.idle <= 0 {
+		return
+	}
+	if v.timer != nil {
+		v.timer.Stop()
+	}
+	v.timer = time.AfterFunc(v.idle, func() {
+		slog.Info("vault locked after idle timeout")
+		v.Lock()
+	})
+}
+
+// Get returns a secret, or "" if the vault is locked or has no such secret
+func (v *Vault) Get(name string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ""
+	}
+	v.resetTimer()
+	return v.secrets[name]
+}
+
+// Set stores a secret, an empty value removes it
+func (v *Vault) Set(name, value string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return errVaultLocked
+	}
+	v.resetTimer()
+	if v.secrets[name] == value {
+		return nil
+	}
+	if value == "" {
+		delete(v.secrets, name)
+	} else {
+		v.secrets[name] = value
+	}
+	return v.persist()
+}
+
+// persist seals the secrets with a fresh nonce, must hold mu
+func (v *Vault) persist() error {
+	aead, err := chacha20poly1305.NewX(v.key)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	v.file.Nonce = nonce
+	v.file.Data = aead.Seal(nil, nonce, plain, []byte(vaultAAD))
+	clear(plain)
+
+	data, err := json.Marshal(v.file)
+	if err != nil {
+		return err
+	}
+	if v.store != nil {
+		v.store(string(data))
+	}
+	return nil
+}
+
+// profileSecretKey names the vault entry of a profile secret field
+func profileSecretKey(profile, field string) string {
+	return "profile." + profile + "." + field
+}
+
+// hasPlaintextSecrets reports whether prefs still hold secrets from before
+// the vault
+func hasPlaintextSecrets(prefs fyne.Preferences) bool {
+	for _, key := range plaintextSecretKeys {
+		if prefs.String(key) != "" {
+			return true
+		}
+	}
+	profiles, _ := decodeProfiles([]byte(prefs.String(profilesKey)), false)
+	for _, p := range profiles {
+		if p.SecretKey != "" || p.Password != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// migratePlaintextSecrets moves secrets stored in plain preferences into the
+// unlocked vault and wipes the old values
+func migratePlaintextSecrets(prefs fyne.Preferences, v *Vault) (n int, err error) {
+	for _, key := range plaintextSecretKeys {
+		value := prefs.String(key)
+		if value == "" {
+			continue
+		}
+		if err = v.Set(key, value); err != nil {
+			return
+		}
+		prefs.RemoveValue(key)
+		n++
+	}
+
+	profiles, err := decodeProfiles([]byte(prefs.String(profilesKey)), false)
+	if err != nil {
+		return
+	}
+	changed := false
+	for i := range profiles {
+		p := &profiles[i]
+		if p.SecretKey != "" {
+			if err = v.Set(profileSecretKey(p.Name, "secret_key"), p.SecretKey); err != nil {
+				return
+			}
+			p.SecretKey = ""
+			changed = true
+			n++
+		}
+		if p.Password != "" {
+			if err = v.Set(profileSecretKey(p.Name, "password"), p.Password); err != nil {
+				return
+			}
+			p.Password = ""
+			changed = true
+			n++
+		}
+	}
+	if changed {
+		var buf strings.Builder
+		if err = encodeProfiles(&buf, profiles, false); err != nil {
+			return
+		}
+		prefs.SetString(profilesKey, buf.String())
+	}
+	return
+}
+
+// unlockVault asks for the master password, creating the vault on first
+// use, then runs fn
+func (sc *Fone) unlockVault(fn func()) {
+	if !sc.vault.Locked() {
+		fn()
+		return
+	}
+
+	password := widget.NewPasswordEntry()
+	items := []*widget.FormItem{
+		widget.NewFormItem("Master password", password),
+	}
+	title := "Unlock vault"
+	var confirm *widget.Entry
+	if !sc.vault.Exists() {
+		title = "Create vault"
+		confirm = widget.NewPasswordEntry()
+		items = append(items, widget.NewFormItem("Confirm", confirm))
+		if hasPlaintextSecrets(sc.a.Preferences()) {
+			items = append(items, widget.NewFormItem("", widget.NewLabel("Saved passwords will be moved into the vault.")))
+		}
+	}
+	d := dialog.NewForm(title, "OK", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		var err error
+		if confirm != nil {
+			if password.Text != confirm.Text {
+				err = errors.New("passwords do not match")
+			} else {
+				err = sc.vault.Create(password.Text)
+			}
+		} else {
+			err = sc.vault.Unlock(password.Text)
+		}
+		if err != nil {
+			slog.Warn("unlock vault failed",
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(err, sc.w)
+			return
+		}
+		n, err := migratePlaintextSecrets(sc.a.Preferences(), sc.vault)
+		if err != nil {
+			slog.Warn("migrate secrets failed",
+				slog.String("error", err.Error()),
+			)
+		} else if n > 0 {
+			slog.Info("migrate secrets success",
+				slog.Int("count", n),
+			)
+		}
+		sc.fillSecrets()
+		fn()
+	}, sc.w)
+	d.Resize(fyne.NewSize(400, 0))
+	d.Show()
+}
+
+// secretEntry registers a login form entry that shows the vault secret name
+func (sc *Fone) secretEntry(name string, e *widget.Entry) {
+	if sc.secretEntries == nil {
+		sc.secretEntries = map[string]*widget.Entry{}
+	}
+	sc.secretEntries[name] = e
+	// plaintext from before the vault, until it is migrated
+	e.SetText(sc.a.Preferences().String(name))
+}
+
+// storeSecrets saves the named values in the vault, or removes them if
+// forget is set
+func (sc *Fone) storeSecrets(secrets map[string]string, forget bool) {
+	if forget {
+		if !sc.vault.Locked() {
+			for name := range secrets {
+				sc.vault.Set(name, "")
+			}
+		}
+		return
+	}
+	empty := true
+	for _, value := range secrets {
+		if value != "" {
+			empty = false
+		}
+	}
+	if empty && !sc.vault.Exists() {
+		return
+	}
+	sc.unlockVault(func() {
+		for name, value := range secrets {
+			if err := sc.vault.Set(name, value); err != nil {
+				slog.Warn("store secret failed",
+					slog.String("name", name),
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+	})
+}
+
+// fillSecrets copies the vault secrets into the login forms and profiles
+func (sc *Fone) fillSecrets() {
+	for name, e := range sc.secretEntries {
+		e.SetText(sc.vault.Get(name))
+	}
+	if sc.sites != nil {
+		sc.sites.fillSecrets()
+	}
+}
+
+// clearSecrets wipes the secrets shown in the login forms and profiles
+func (sc *Fone) clearSecrets() {
+	for _, e := range sc.secretEntries {
+		e.SetText("")
+	}
+	if sc.sites != nil {
+		sc.sites.clearSecrets()
+	}
+}
+
+// initVault loads the vault and asks to unlock it, or to create it if old
+// plaintext secrets need to be migrated
+func (sc *Fone) initVault() {
+	prefs := sc.a.Preferences()
+	v, err := newVault(prefs.String(vaultKey), func(data string) {
+		prefs.SetString(vaultKey, data)
+	})
+	if err != nil {
+		slog.Warn("load vault failed",
+			slog.String("error", err.Error()),
+		)
+	}
+	sc.vault = v
+	idle := time.Duration(prefs.IntWithFallback(vaultIdleKey, vaultIdleDefault)) * time.Minute
+	sc.vault.SetIdleTimeout(idle, sc.clearSecrets)
+
+	if sc.vault.Exists() || hasPlaintextSecrets(prefs) {
+		sc.unlockVault(func() {})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	var stored string
+	v, _ := newVault("", func(data string) { stored = data })
+	if v.Exists() || !v.Locked() {
+		t.Fatalf("newVault(empty) should not exist and be locked")
+	}
+	if err := v.Set("a", "b"); !errors.Is(err, errVaultLocked) {
+		t.Errorf("Set() on locked vault error = %v, want %v", err, errVaultLocked)
+	}
+	if err := v.Create("master"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := v.Set("cred.s3_pass", "secret"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if strings.Contains(stored, "secret") {
+		t.Errorf("stored vault contains the plaintext secret")
+	}
+
+	v2, err := newVault(stored, nil)
+	if err != nil {
+		t.Fatalf("newVault() error = %v", err)
+	}
+	if err = v2.Unlock("wrong"); !errors.Is(err, errWrongPassword) {
+		t.Errorf("Unlock(wrong) error = %v, want %v", err, errWrongPassword)
+	}
+	if err = v2.Unlock("master"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if got := v2.Get("cred.s3_pass"); got != "secret" {
+		t.Errorf("Get() = %v, want secret", got)
+	}
+	v2.Lock()
+	if got := v2.Get("cred.s3_pass"); got != "" {
+		t.Errorf("Get() after Lock = %v, want empty", got)
+	}
+}
+
+func TestVaultCorrupt(t *testing.T) {
+	var stored string
+	v, _ := newVault("", func(data string) { stored = data })
+	if err := v.Create("master"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		edit func(f *vaultFile)
+	}{
+		{"short nonce", func(f *vaultFile) { f.Nonce = f.Nonce[:12] }},
+		{"no nonce", func(f *vaultFile) { f.Nonce = nil }},
+		{"zero time", func(f *vaultFile) { f.Time = 0 }},
+		{"zero threads", func(f *vaultFile) { f.Threads = 0 }},
+		{"huge memory", func(f *vaultFile) { f.Memory = 1 << 31 }},
+		{"unknown kdf", func(f *vaultFile) { f.KDF = "scrypt" }},
+	}
+	for _, tt := range tests {
+		var f vaultFile
+		if err := json.Unmarshal([]byte(stored), &f); err != nil {
+			t.Fatal(err)
+		}
+		tt.edit(&f)
+		data, _ := json.Marshal(f)
+		v, err := newVault(string(data), nil)
+		if err == nil {
+			t.Errorf("newVault(%s) error = nil", tt.name)
+		}
+		// the GUI keeps the vault it got, unlocking must fail instead of panic
+		if err = v.Unlock("master"); err == nil || errors.Is(err, errWrongPassword) {
+			t.Errorf("Unlock(%s) error = %v", tt.name, err)
+		}
+	}
+	v, err := newVault(stored[:len(stored)/2], nil)
+	if err == nil {
+		t.Error("newVault(truncated) error = nil")
+	}
+	if err = v.Unlock("master"); err == nil {
+		t.Error("Unlock(truncated) error = nil")
+	}
+}
+
+func TestVaultIdleTimeout(t *testing.T) {
+	v, _ := newVault("", nil)
+	locked := make(chan struct{})
+	v.SetIdleTimeout(50*time.Millisecond, func() { close(locked) })
+	if err := v.Create("master"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("vault not locked after idle timeout")
+	}
+	if !v.Locked() {
+		t.Errorf("Locked() = false after idle timeout")
+	}
+}
+
+func TestMigratePlaintextSecrets(t *testing.T) {
+	prefs := test.NewApp().Preferences()
+	prefs.SetString("cred.s3_pass", "s3secret")
+	var buf strings.Builder
+	if err := encodeProfiles(&buf, testProfiles(), false); err != nil {
+		t.Fatalf("encodeProfiles() error = %v", err)
+	}
+	prefs.SetString(profilesKey, buf.String())
+	if !hasPlaintextSecrets(prefs) {
+		t.Fatalf("hasPlaintextSecrets() = false, want true")
+	}
+
+	v, _ := newVault("", nil)
+	if err := v.Create("master"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	n, err := migratePlaintextSecrets(prefs, v)
+	if err != nil {
+		t.Fatalf("migratePlaintextSecrets() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("migratePlaintextSecrets() = %v, want 3", n)
+	}
+	if hasPlaintextSecrets(prefs) {
+		t.Errorf("hasPlaintextSecrets() after migration = true")
+	}
+	if got := v.Get("cred.s3_pass"); got != "s3secret" {
+		t.Errorf("vault cred.s3_pass = %v, want s3secret", got)
+	}
+	if got := v.Get(profileSecretKey("web", "password")); got != "passphrase" {
+		t.Errorf("vault web password = %v, want passphrase", got)
+	}
+	profiles, _ := decodeProfiles([]byte(prefs.String(profilesKey)), false)
+	if len(profiles) != 2 || profiles[0].Bucket != "data/logs" {
+		t.Errorf("migratePlaintextSecrets() lost profile data: %+v", profiles)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showWebsite edits the static website hosting of the current bucket
+func (sc *Fone) showWebsite(we websiteEditor) {
+	go func() {
+		w, err := we.Website(context.Background())
+		if err != nil {
+			slog.Warn("get website failed",
+				slog.String("error", err.Error()),
+			)
+			dialog.ShowError(unwrapError(err), sc.w)
+			return
+		}
+		index := widget.NewEntry()
+		index.SetPlaceHolder("index.html, empty turns hosting off")
+		index.SetText(w.IndexDocument)
+		errorDoc := widget.NewEntry()
+		errorDoc.SetPlaceHolder("error.html")
+		errorDoc.SetText(w.ErrorDocument)
+		rules := widget.NewMultiLineEntry()
+		rules.SetPlaceHolder("prefix=docs/ replace_prefix=documents/\nerror=404 host=example.com replace_key=404.html code=302")
+		rules.SetMinRowsVisible(4)
+		rules.SetText(formatRoutingRules(w.RoutingRules))
+		rules.Validator = func(s string) error {
+			_, err := parseRoutingRules(s)
+			return err
+		}
+		host := widget.NewEntry()
+		host.SetPlaceHolder("example.com, replaces everything above")
+		host.SetText(w.RedirectAllHost)
+		protocol := widget.NewSelect([]string{"", "http", "https"}, nil)
+		protocol.SetSelected(w.RedirectAllProtocol)
+		items := []*widget.FormItem{
+			widget.NewFormItem("Index document", index),
+			widget.NewFormItem("Error document", errorDoc),
+			widget.NewFormItem("Redirect rules", rules),
+			widget.NewFormItem("Redirect all to host", host),
+			widget.NewFormItem("Protocol", protocol),
+		}
+		d := dialog.NewForm("Static website", "Save", "Cancel", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			nw := Website{
+				IndexDocument:       strings.TrimSpace(index.Text),
+				ErrorDocument:       strings.TrimSpace(errorDoc.Text),
+				RedirectAllHost:     strings.TrimSpace(host.Text),
+				RedirectAllProtocol: protocol.Selected,
+			}
+			nw.RoutingRules, _ = parseRoutingRules(rules.Text)
+			go func() {
+				if err := we.SetWebsite(context.Background(), nw); err != nil {
+					slog.Warn("set website failed",
+						slog.String("error", err.Error()),
+					)
+					dialog.ShowError(unwrapError(err), sc.w)
+					return
+				}
+				slog.Info("set website success",
+					slog.Bool("enabled", nw.Enabled()),
+				)
+			}()
+		}, sc.w)
+		d.Resize(fyne.NewSize(560, 0))
+		d.Show()
+	}()
+}